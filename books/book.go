@@ -0,0 +1,10 @@
+// Package books holds the opening book compiled into the engine
+package books
+
+import _ "embed"
+
+// Book is book.txt: "pos <FEN>" lines, each followed by the moves played from the
+// position as "<UCI move> <count>" lines
+//
+//go:embed book.txt
+var Book []byte
@@ -1,3 +1,5 @@
+//go:build ignore
+
 package main
 
 import (
@@ -11,48 +13,63 @@ import (
 
 // Takes a list of FEN position and their best moves and creates a JSON file
 // with Zobrist hashes as keys and lists of moves as values.
+// Run from the repository root with go run books/parse.go.
 func main() {
-	board := NewBoard()
-	file, _ := os.Open("books/book.txt")
-	defer file.Close()
+	if err := parse("books/book.txt", "books/book.json"); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
 
-	outFile, _ := os.Create("books/book.json")
-	defer outFile.Close()
+func parse(in string, out string) error {
+	file, err := os.Open(in)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
 
+	var positions []BookPosition
+	board := NewBoard()
 	scanner := bufio.NewScanner(file)
-	writer := bufio.NewWriter(outFile)
-
-	writer.WriteString("{\n")
-
-	firstMove := true
-	firstPosition := true
-	for scanner.Scan() {
-		line := scanner.Text()
-		output := ""
-		if strings.HasPrefix(line, "pos ") {
-			if !firstPosition {
-				output += "],\n"
-			}
-			board.FromFEN(line[4:])
-			hash := board.ZobristHashWasm()
-			output += fmt.Sprintf("\t\"%#x\": [", hash)
-			firstMove = true
-			firstPosition = false
-		} else {
-			if !firstMove {
-				output += ", "
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if fen, ok := strings.CutPrefix(line, "pos "); ok {
+			if _, err := board.FromFEN(fen); err != nil {
+				return fmt.Errorf("%s:%d: %w", in, lineNumber, err)
 			}
-			parts := strings.Fields(line)
-			output += fmt.Sprintf("\"%s\"", parts[0])
-			firstMove = false
+			positions = append(positions, BookPosition{FEN: fen})
+			continue
 		}
-		writer.WriteString(output)
-	}
-	writer.WriteString("],\n}\n")
-	if err := writer.Flush(); err != nil {
-		fmt.Fprintf(os.Stderr, "Error flushing to Parsed.txt: %v\n", err)
+		fields := strings.Fields(line)
+		if len(positions) == 0 || len(fields) == 0 {
+			return fmt.Errorf("%s:%d: %w: %q", in, lineNumber, ErrInvalidBook, line)
+		}
+		move := board.ParseUCIMove(fields[0])
+		if move == nil {
+			return fmt.Errorf("%s:%d: %w: %q in %s", in, lineNumber, ErrInvalidBook, line, board.ToFEN())
+		}
+		position := &positions[len(positions)-1]
+		position.Moves = append(position.Moves, BookMoveStats{Move: *move})
 	}
 	if err := scanner.Err(); err != nil {
-		fmt.Fprintf(os.Stderr, "Error reading Book.txt: %v\n", err)
+		return err
+	}
+
+	outFile, err := os.Create(out)
+	if err != nil {
+		return err
+	}
+	writer := bufio.NewWriter(outFile)
+	if err := SaveBookJSON(writer, positions); err != nil {
+		outFile.Close()
+		return err
+	}
+	if err := writer.Flush(); err != nil {
+		outFile.Close()
+		return err
 	}
+	return outFile.Close()
 }
@@ -0,0 +1,50 @@
+//go:build ignore
+
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"os"
+
+	. "github.com/eugenioenko/libra-chess/pkg"
+)
+
+// Converts a book in the book.txt format into a Polyglot book (.bin) usable by
+// other engines and GUIs, or by the BookFile option.
+// Run from the repository root with go run books/polyglot.go.
+func main() {
+	in := flag.String("in", "books/book.txt", "book in the book.txt format")
+	out := flag.String("out", "books/book.bin", "Polyglot book to write")
+	flag.Parse()
+	if err := convert(*in, *out); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func convert(in string, out string) error {
+	book, err := LoadBookFile(in)
+	if err != nil {
+		return fmt.Errorf("%s: %w", in, err)
+	}
+	file, err := os.Create(out)
+	if err != nil {
+		return err
+	}
+	writer := bufio.NewWriter(file)
+	if err := book.SavePolyglot(writer); err != nil {
+		file.Close()
+		return err
+	}
+	if err := writer.Flush(); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	fmt.Printf("%d positions written to %s\n", book.Len(), out)
+	return nil
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/eugenioenko/libra-chess/cmd/internal/cli"
+	. "github.com/eugenioenko/libra-chess/pkg"
+)
+
+// Builds an opening book from the games of PGN files: every game is replayed up
+// to -max-ply plies and the moves played from each position are counted with
+// their results and the ratings of their players. The moves passing the
+// -min-games, -min-score and -min-rating filters are written to -txt (the
+// books/book.txt format, read by the BookFile option), -json (the books/book.json
+// format of the web interface, without the statistics) and -polyglot (a .bin
+// book). Games with an illegal or unreadable move are reported and skipped.
+//
+//	go run ./cmd/bookbuild -max-ply 24 -min-games 5 -txt book.txt -polyglot book.bin games.pgn
+func main() {
+	maxPly := flag.Int("max-ply", 20, "plies of every game recorded, 0 = all")
+	minGames := flag.Int("min-games", 3, "least games a move was played in")
+	minScore := flag.Float64("min-score", 0, "least score of the side playing a move, 0 to 100 (%)")
+	minRating := flag.Int("min-rating", 0, "least average rating of the players of a move, 0 = any")
+	txt := flag.String("txt", "", "book to write in the book.txt format")
+	jsonOut := flag.String("json", "", "book to write in the book.json format of the web interface")
+	polyglot := flag.String("polyglot", "", "book to write in the Polyglot format")
+	flag.Parse()
+
+	if flag.NArg() == 0 {
+		cli.Fail(fmt.Errorf("usage: bookbuild [flags] games.pgn..."))
+	}
+	if *txt == "" && *jsonOut == "" && *polyglot == "" {
+		cli.Fail(fmt.Errorf("no output, set -txt, -json or -polyglot"))
+	}
+	if *minScore < 0 || *minScore > 100 {
+		cli.Fail(fmt.Errorf("-min-score must be 0 to 100"))
+	}
+
+	builder := NewBookBuilder(*maxPly)
+	skipped := 0
+	for _, path := range flag.Args() {
+		n, err := addGames(builder, path)
+		if err != nil {
+			cli.Fail(err)
+		}
+		skipped += n
+	}
+	positions := builder.Positions(BookFilter{MinGames: *minGames, MinScore: *minScore / 100, MinRating: *minRating})
+	fmt.Printf("%d games read, %d skipped, %d positions kept\n", builder.Games+skipped, skipped, len(positions))
+
+	if *txt != "" {
+		if err := writeFile(*txt, func(w io.Writer) error { return SaveBookText(w, positions) }); err != nil {
+			cli.Fail(err)
+		}
+	}
+	if *jsonOut != "" {
+		if err := writeFile(*jsonOut, func(w io.Writer) error { return SaveBookJSON(w, positions) }); err != nil {
+			cli.Fail(err)
+		}
+	}
+	if *polyglot != "" {
+		book, err := NewBookFromPositions(positions)
+		if err != nil {
+			cli.Fail(err)
+		}
+		if err := writeFile(*polyglot, book.SavePolyglot); err != nil {
+			cli.Fail(err)
+		}
+	}
+}
+
+// addGames adds the games of the PGN file at path, returning how many were skipped
+func addGames(builder *BookBuilder, path string) (int, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+	reader := NewPGNReader(bufio.NewReader(file))
+	skipped := 0
+	for {
+		game, err := reader.Next()
+		if err == io.EOF {
+			return skipped, nil
+		}
+		if err != nil {
+			return skipped, fmt.Errorf("%s: %w", path, err)
+		}
+		if err := builder.AddGame(game); err != nil {
+			fmt.Fprintf(os.Stderr, "%s:%d: skipped: %v\n", path, game.Line, err)
+			skipped++
+		}
+	}
+}
+
+// writeFile writes the file at path with write, reporting close errors
+func writeFile(path string, write func(w io.Writer) error) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	writer := bufio.NewWriter(file)
+	if err := write(writer); err != nil {
+		file.Close()
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	if err := writer.Flush(); err != nil {
+		file.Close()
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	fmt.Printf("written to %s\n", path)
+	return nil
+}
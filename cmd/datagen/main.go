@@ -0,0 +1,98 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"flag"
+	"fmt"
+	"math/rand"
+	"os"
+	"os/signal"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/eugenioenko/libra-chess/cmd/internal/cli"
+	. "github.com/eugenioenko/libra-chess/pkg"
+)
+
+// Generates training data by fixed-node self-play from randomized openings. Quiet
+// positions are appended to -out as "<fen> | <score> | <result>" lines, white's
+// perspective, readable by cmd/tune. Every finished game is flushed, so the tool
+// can be stopped with Ctrl-C at any time and restarted on the same file.
+//
+//	go run ./cmd/datagen -nodes 5000 -games 100000 -out data.txt
+func main() {
+	openings := flag.String("openings", "books/chess.epd", "starting positions, one FEN or EPD per line")
+	randomPlies := flag.Int("random-plies", 8, "random legal moves played from the opening")
+	nodes := flag.Uint64("nodes", 5_000, "nodes searched per move")
+	games := flag.Int("games", 0, "games to play, 0 = until interrupted")
+	threads := flag.Int("threads", runtime.NumCPU(), "games played in parallel")
+	out := flag.String("out", "data.txt", "file the positions are appended to")
+	minPly := flag.Int("min-ply", DatagenMinPly, "plies played after the opening before recording")
+	maxScore := flag.Int("max-score", DatagenMaxScore, "largest absolute search score recorded (cp)")
+	winScore := flag.Int("win-score", 2_500, "adjudicate a win when both sides score beyond this (cp), 0 = never")
+	maxPlies := flag.Int("max-plies", SelfPlayMaxPlies, "plies after which a game is drawn")
+	seed := flag.Int64("seed", time.Now().UnixNano(), "random seed for openings")
+	flag.Parse()
+
+	starts, err := LoadOpeningsFile(*openings)
+	if err != nil {
+		cli.Fail(err)
+	}
+	file, err := os.OpenFile(*out, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		cli.Fail(err)
+	}
+	defer file.Close()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	filter := DatagenFilter{MinPly: *minPly, MaxScore: *maxScore}
+	options := SelfPlayOptions{Nodes: *nodes, MaxPlies: *maxPlies, WinScore: *winScore}
+	results := make(chan []TrainingPosition, *threads)
+	var started atomic.Int64
+	var wg sync.WaitGroup
+	for worker := 0; worker < *threads; worker++ {
+		wg.Add(1)
+		go func(worker int) {
+			defer wg.Done()
+			rng := rand.New(rand.NewSource(*seed + int64(worker)))
+			white, black := NewSelfPlayer(DefaultSearchParams()), NewSelfPlayer(DefaultSearchParams())
+			for ctx.Err() == nil && (*games == 0 || started.Add(1) <= int64(*games)) {
+				start := RandomOpening(starts[rng.Intn(len(starts))], *randomPlies, rng)
+				for start == nil {
+					start = RandomOpening(starts[rng.Intn(len(starts))], *randomPlies, rng)
+				}
+				results <- GenerateTrainingGame(white, black, start, options, filter)
+			}
+		}(worker)
+	}
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	fmt.Printf("%d openings, %d threads, %d nodes per move, writing to %s\n", len(starts), *threads, *nodes, *out)
+	writer := bufio.NewWriter(file)
+	begin := time.Now()
+	played, positions := 0, 0
+	for game := range results {
+		for _, position := range game {
+			fmt.Fprintln(writer, position)
+		}
+		if err := writer.Flush(); err != nil {
+			cli.Fail(err)
+		}
+		played++
+		positions += len(game)
+		if played%100 == 0 {
+			elapsed := time.Since(begin)
+			fmt.Printf("%d games, %d positions, %.0f positions/s (%s)\n", played, positions, float64(positions)/elapsed.Seconds(), elapsed.Round(time.Second))
+		}
+	}
+	fmt.Printf("done: %d games, %d positions in %s\n", played, positions, time.Since(begin).Round(time.Second))
+}
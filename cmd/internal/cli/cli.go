@@ -0,0 +1,13 @@
+// Package cli holds the helpers shared by the command line tools
+package cli
+
+import (
+	"fmt"
+	"os"
+)
+
+// Fail prints err to stderr and exits with status 1
+func Fail(err error) {
+	fmt.Fprintln(os.Stderr, err)
+	os.Exit(1)
+}
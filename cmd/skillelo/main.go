@@ -0,0 +1,182 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"math"
+	"math/rand"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/eugenioenko/libra-chess/cmd/internal/cli"
+	. "github.com/eugenioenko/libra-chess/pkg"
+)
+
+// Calibrates the skill levels. Every level plays -games games against the next two
+// levels and, every -anchor-every levels, against full strength searches of fixed
+// node counts, the anchors, from random openings with colors alternating. The games
+// are appended to a CSV file and skipped when the run is resumed. At the end the
+// ratings are fitted by maximum likelihood with level 0 pinned at MinSkillElo, and
+// printed as a table and as the skillLevelElo literal of pkg/skill.go.
+//
+//	go run ./cmd/skillelo -games 16 -out skillelo.csv
+func main() {
+	openings := flag.String("openings", "books/chess.epd", "starting positions, one FEN or EPD per line")
+	games := flag.Int("games", 16, "games played by every pairing")
+	anchorNodes := flag.String("anchors", "1000,8000,64000", "comma separated node counts of the full strength anchors")
+	anchorEvery := flag.Int("anchor-every", 4, "levels between the levels playing the anchors")
+	maxPlies := flag.Int("maxplies", 300, "plies after which a game is drawn")
+	winScore := flag.Int("winscore", 1000, "score (cp) adjudicating a win once held by both sides")
+	out := flag.String("out", "skillelo.csv", "CSV file the game results are appended to")
+	seed := flag.Int64("seed", time.Now().UnixNano(), "random seed for the openings")
+	flag.Parse()
+
+	starts, err := LoadOpeningsFile(*openings)
+	if err != nil {
+		cli.Fail(err)
+	}
+	players, err := newPlayers(*anchorNodes)
+	if err != nil {
+		cli.Fail(err)
+	}
+	pairings := schedule(players, *anchorEvery)
+	played, err := loadResults(*out, players)
+	if err != nil {
+		cli.Fail(err)
+	}
+	log, err := os.OpenFile(*out, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		cli.Fail(err)
+	}
+	defer log.Close()
+
+	rng := rand.New(rand.NewSource(*seed))
+	options := SelfPlayOptions{MaxPlies: *maxPlies, WinScore: *winScore}
+	begin := time.Now()
+	for _, pairing := range pairings {
+		a, b := players[pairing[0]], players[pairing[1]]
+		for game := len(played[pairing]); game < *games; game++ {
+			white, black := a, b
+			if game%2 == 1 {
+				white, black = b, a
+			}
+			start := starts[rng.Intn(len(starts))]
+			score := PlaySelfPlayGame(white.player, black.player, start, options)
+			fmt.Fprintf(log, "%s,%s,%s\n", white.name, black.name, strconv.FormatFloat(score, 'f', -1, 64))
+			if game%2 == 1 {
+				score = 1 - score
+			}
+			played[pairing] = append(played[pairing], score)
+			fmt.Printf("%s vs %s game %d: %.1f (%s)\n", a.name, b.name, game+1, score, time.Since(begin).Round(time.Second))
+		}
+	}
+
+	var results []RatingResult
+	for _, pairing := range pairings {
+		result := RatingResult{A: pairing[0], B: pairing[1]}
+		for _, score := range played[pairing] {
+			result.Score += score
+			result.Games++
+		}
+		results = append(results, result)
+	}
+	ratings, errs, err := FitRatings(len(players), results, 0, MinSkillElo)
+	if err != nil {
+		cli.Fail(err)
+	}
+	fmt.Println("player    elo  error")
+	for i, player := range players {
+		fmt.Printf("%-7s %5.0f %6.0f\n", player.name, ratings[i], errs[i])
+	}
+	fmt.Println("var skillLevelElo = [MaxSkillLevel]int{")
+	for level := 0; level < MaxSkillLevel; level++ {
+		fmt.Printf("\t%d, // level %d, ±%.0f\n", int(math.Round(ratings[level])), level, errs[level])
+	}
+	fmt.Println("}")
+}
+
+type namedPlayer struct {
+	name   string
+	player *SelfPlayer
+}
+
+// newPlayers returns the skill levels 0 to MaxSkillLevel-1, at their index, then the anchors
+func newPlayers(anchorNodes string) ([]namedPlayer, error) {
+	var players []namedPlayer
+	for level := 0; level < MaxSkillLevel; level++ {
+		player := NewSelfPlayer(DefaultSearchParams())
+		player.Skill = NewSkill(level)
+		players = append(players, namedPlayer{fmt.Sprintf("L%d", level), player})
+	}
+	for _, field := range strings.Split(anchorNodes, ",") {
+		nodes, err := strconv.ParseUint(strings.TrimSpace(field), 10, 64)
+		if err != nil || nodes == 0 {
+			return nil, fmt.Errorf("invalid anchor node count %q", field)
+		}
+		player := NewSelfPlayer(DefaultSearchParams())
+		player.Nodes = nodes
+		players = append(players, namedPlayer{fmt.Sprintf("N%d", nodes), player})
+	}
+	return players, nil
+}
+
+// schedule pairs every level with the next two and the levels every anchorEvery,
+// and the last level, with all anchors
+func schedule(players []namedPlayer, anchorEvery int) [][2]int {
+	var pairings [][2]int
+	for level := 0; level < MaxSkillLevel; level++ {
+		for next := level + 1; next <= level+2 && next < MaxSkillLevel; next++ {
+			pairings = append(pairings, [2]int{level, next})
+		}
+	}
+	for anchor := MaxSkillLevel; anchor < len(players); anchor++ {
+		for level := 0; level < MaxSkillLevel; level++ {
+			if level%anchorEvery == 0 || level == MaxSkillLevel-1 {
+				pairings = append(pairings, [2]int{level, anchor})
+			}
+		}
+	}
+	return pairings
+}
+
+// loadResults reads the games of a previous run, keyed by the pairing of the player
+// indices, with the scores of the player listed first in the pairing
+func loadResults(path string, players []namedPlayer) (map[[2]int][]float64, error) {
+	played := map[[2]int][]float64{}
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return played, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	indices := map[string]int{}
+	for i, player := range players {
+		indices[player.name] = i
+	}
+	scanner := bufio.NewScanner(file)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		fields := strings.Split(scanner.Text(), ",")
+		if len(fields) != 3 {
+			return nil, fmt.Errorf("%s line %d: expected white,black,score", path, lineNumber)
+		}
+		white, okWhite := indices[fields[0]]
+		black, okBlack := indices[fields[1]]
+		if !okWhite || !okBlack {
+			return nil, fmt.Errorf("%s line %d: unknown player", path, lineNumber)
+		}
+		score, err := strconv.ParseFloat(fields[2], 64)
+		if err != nil {
+			return nil, fmt.Errorf("%s line %d: %w", path, lineNumber, err)
+		}
+		if white < black {
+			played[[2]int{white, black}] = append(played[[2]int{white, black}], score)
+		} else {
+			played[[2]int{black, white}] = append(played[[2]int{black, white}], 1-score)
+		}
+	}
+	return played, scanner.Err()
+}
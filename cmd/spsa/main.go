@@ -0,0 +1,88 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"math/rand"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/eugenioenko/libra-chess/cmd/internal/cli"
+	. "github.com/eugenioenko/libra-chess/pkg"
+)
+
+// Tunes the search parameters by SPSA self-play. Every iteration plays -pairs game
+// pairs between the two perturbed configurations, from random openings, and appends
+// the parameter values to a CSV trajectory log. The final values are printed as UCI
+// setoption commands.
+//
+//	go run ./cmd/spsa -iterations 2000 -nodes 20000 -out spsa.csv
+func main() {
+	openings := flag.String("openings", "books/chess.epd", "starting positions, one FEN or EPD per line")
+	iterations := flag.Int("iterations", 1000, "SPSA iterations")
+	pairs := flag.Int("pairs", runtime.NumCPU(), "game pairs played in parallel every iteration")
+	nodes := flag.Uint64("nodes", 20_000, "nodes searched per move")
+	out := flag.String("out", "spsa.csv", "CSV file the parameter trajectory is written to")
+	seed := flag.Int64("seed", time.Now().UnixNano(), "random seed for openings and perturbations")
+	flag.Parse()
+
+	starts, err := LoadOpeningsFile(*openings)
+	if err != nil {
+		cli.Fail(err)
+	}
+	log, err := os.Create(*out)
+	if err != nil {
+		cli.Fail(err)
+	}
+	defer log.Close()
+	header := []string{"iteration", "result"}
+	for _, spec := range SearchParamSpecs {
+		header = append(header, spec.Name)
+	}
+	fmt.Fprintln(log, strings.Join(header, ","))
+
+	rng := rand.New(rand.NewSource(*seed))
+	spsa := NewSPSA(DefaultSearchParams(), *iterations)
+	players := make([][2]*SelfPlayer, *pairs)
+	for i := range players {
+		players[i] = [2]*SelfPlayer{NewSelfPlayer(DefaultSearchParams()), NewSelfPlayer(DefaultSearchParams())}
+	}
+	fmt.Printf("%d openings, %d iterations of %d game pairs at %d nodes per move\n", len(starts), *iterations, *pairs, *nodes)
+	begin := time.Now()
+	for iteration := 1; iteration <= *iterations; iteration++ {
+		plus, minus, delta := spsa.Perturb(rng)
+		results := make([]float64, *pairs)
+		var wg sync.WaitGroup
+		for i := range players {
+			players[i][0].Params, players[i][1].Params = plus, minus
+			start := starts[rng.Intn(len(starts))]
+			wg.Add(1)
+			go func(i int, start *Board) {
+				defer wg.Done()
+				results[i] = PlayGamePair(players[i][0], players[i][1], start, *nodes)
+			}(i, start)
+		}
+		wg.Wait()
+		result := 0.0
+		for _, r := range results {
+			result += r
+		}
+		spsa.Update(delta, result)
+
+		row := []string{strconv.Itoa(iteration), strconv.FormatFloat(result, 'f', -1, 64)}
+		for _, theta := range spsa.Theta {
+			row = append(row, strconv.FormatFloat(theta, 'f', 2, 64))
+		}
+		fmt.Fprintln(log, strings.Join(row, ","))
+		fmt.Printf("iteration %d result %+.0f %s (%s)\n", iteration, result, spsa.Params(), time.Since(begin).Round(time.Second))
+	}
+
+	params := spsa.Params()
+	for _, spec := range SearchParamSpecs {
+		fmt.Printf("setoption name %s value %d\n", spec.Name, *spec.Value(&params))
+	}
+}
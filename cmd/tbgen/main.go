@@ -0,0 +1,90 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/eugenioenko/libra-chess/cmd/internal/cli"
+	. "github.com/eugenioenko/libra-chess/pkg"
+)
+
+// Generates the distance to mate tables of the endings with up to -pieces pieces,
+// kings included, by retrograde analysis, one <material>.lbtb file per ending in
+// -out. Tables already in -out are kept and probed by the tables depending on
+// them; -force regenerates them. The engine probes the directory once set with
+// "setoption name TablebasePath value <dir>".
+//
+//	go run ./cmd/tbgen -pieces 4 -out tablebases
+func main() {
+	out := flag.String("out", "tablebases", "directory of the table files")
+	pieces := flag.Int("pieces", TablebaseMaxPieces, "most pieces of a table, kings included")
+	tables := flag.String("tables", "", "comma separated tables to generate (KQKR,KRKP), missing tables they come after first, default all")
+	threads := flag.Int("threads", runtime.NumCPU(), "goroutines generating the moves")
+	force := flag.Bool("force", false, "regenerate the tables already in -out")
+	flag.Parse()
+
+	if *pieces < 3 || *pieces > TablebaseMaxPieces {
+		cli.Fail(fmt.Errorf("-pieces must be 3 to %d", TablebaseMaxPieces))
+	}
+	names := TablebaseMaterials(*pieces)
+	wanted := map[string]bool{}
+	if *tables != "" {
+		last := -1
+		for _, name := range strings.Split(*tables, ",") {
+			name = strings.ToUpper(strings.TrimSpace(name))
+			index := indexOf(names, name)
+			if index < 0 {
+				cli.Fail(fmt.Errorf("unknown table %q, expected one of %s", name, strings.Join(names, ",")))
+			}
+			wanted[name] = true
+			last = max(last, index)
+		}
+		names = names[:last+1]
+	}
+	if err := os.MkdirAll(*out, 0o755); err != nil {
+		cli.Fail(err)
+	}
+	tb, err := LoadTablebase(*out)
+	if err != nil {
+		cli.Fail(err)
+	}
+	existing := map[string]bool{}
+	for _, name := range tb.Tables() {
+		existing[name] = true
+	}
+
+	begin := time.Now()
+	generated := 0
+	for _, name := range names {
+		if existing[name] && !(*force && (len(wanted) == 0 || wanted[name])) {
+			continue
+		}
+		start := time.Now()
+		table, err := GenerateTablebaseTable(name, tb, *threads)
+		if err != nil {
+			cli.Fail(err)
+		}
+		if err := table.SaveFile(*out); err != nil {
+			cli.Fail(err)
+		}
+		tb.Add(table)
+		generated++
+		stats := table.Stats()
+		fmt.Printf("%-6s %9d wins %9d draws %9d losses, longest mate %3d plies (%s)\n",
+			name, stats.Wins, stats.Draws, stats.Losses, stats.LongestMate, time.Since(start).Round(time.Millisecond))
+	}
+	fmt.Printf("done: %d tables generated in %s, %d in %s\n", generated, time.Since(begin).Round(time.Second), len(tb.Tables()), *out)
+}
+
+func indexOf(names []string, name string) int {
+	for i, n := range names {
+		if n == name {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"runtime"
+	"time"
+
+	"github.com/eugenioenko/libra-chess/cmd/internal/cli"
+	. "github.com/eugenioenko/libra-chess/pkg"
+)
+
+// Tunes the evaluation weights to a labeled EPD/FEN dataset (Texel tuning) and
+// writes them as an EvalParams JSON file for the EvalFile option.
+//
+//	go run ./cmd/tune -data positions.epd -out tuned.json
+func main() {
+	data := flag.String("data", "", "labeled positions, one FEN or EPD per line with a result or ce score")
+	start := flag.String("params", "", "EvalParams JSON to start from (default: compiled-in weights)")
+	out := flag.String("out", "tuned.json", "file the tuned EvalParams are written to after every pass")
+	iterations := flag.Int("iterations", 100, "maximum local search passes")
+	threads := flag.Int("threads", runtime.NumCPU(), "goroutines evaluating the positions")
+	k := flag.Float64("k", 0, "sigmoid scale, 0 fits it to the starting weights")
+	step := flag.Int("step", 1, "change tried on every weight")
+	flag.Parse()
+	if *data == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	params := DefaultEvalParams()
+	if *start != "" {
+		loaded, err := LoadEvalParamsFile(*start)
+		if err != nil {
+			cli.Fail(err)
+		}
+		params = loaded
+	}
+	// Positions are resolved to quiet ones with the starting weights
+	if err := ApplyEvalParams(params); err != nil {
+		cli.Fail(err)
+	}
+	file, err := os.Open(*data)
+	if err != nil {
+		cli.Fail(err)
+	}
+	begin := time.Now()
+	entries, err := LoadTuneEntries(file)
+	file.Close()
+	if err != nil {
+		cli.Fail(err)
+	}
+	fmt.Printf("loaded %d positions in %s\n", len(entries), time.Since(begin).Round(time.Millisecond))
+
+	tuner := NewTuner(entries, *threads)
+	tuner.Step = *step
+	if *k > 0 {
+		tuner.K = *k
+	} else if _, err := tuner.FitK(params); err != nil {
+		cli.Fail(err)
+	}
+	initial, err := tuner.Error(params)
+	if err != nil {
+		cli.Fail(err)
+	}
+	fmt.Printf("K %.4f, %d tunable weights, initial error %.8f\n", tuner.K, len(TunableParams(params)), initial)
+
+	final, err := tuner.Tune(params, *iterations, func(iteration int, mse float64) {
+		fmt.Printf("pass %d error %.8f (%s)\n", iteration, mse, time.Since(begin).Round(time.Second))
+		if err := params.SaveFile(*out); err != nil {
+			cli.Fail(err)
+		}
+	})
+	if err != nil {
+		cli.Fail(err)
+	}
+	if err := params.SaveFile(*out); err != nil {
+		cli.Fail(err)
+	}
+	fmt.Printf("final error %.8f, written to %s\n", final, *out)
+}
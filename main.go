@@ -2,6 +2,7 @@ package main
 
 import (
 	"bufio"
+	"context"
 	"fmt"
 	"os"
 	"strings"
@@ -18,9 +19,25 @@ func main() {
 	fmt.Println("For more information, visit: https://github.com/eugenioenko/libra-chess")
 	scanner := bufio.NewScanner(os.Stdin)
 	board := NewBoard()
+	engine := NewEngine()
 
 	var searchMu sync.Mutex
-	var stopChan chan struct{}
+	var stopSearch context.CancelFunc
+	var searchDone chan struct{} // Closed once the bestmove of the last search is printed
+
+	// waitSearch stops the running search, if any, and waits for its bestmove
+	waitSearch := func() {
+		searchMu.Lock()
+		if stopSearch != nil {
+			stopSearch()
+			stopSearch = nil
+		}
+		done := searchDone
+		searchMu.Unlock()
+		if done != nil {
+			<-done
+		}
+	}
 
 	for scanner.Scan() {
 		line := scanner.Text()
@@ -32,49 +49,71 @@ func main() {
 		case "uci":
 			fmt.Println("id name LibraChess")
 			fmt.Println("id author eugenioenko")
+			for _, option := range engine.UCIOptions() {
+				fmt.Println(option.UCI())
+			}
 			fmt.Println("uciok")
 		case "isready":
 			fmt.Println("readyok")
+		case "setoption":
+			name, value := ParseSetOption(fields)
+			if err := engine.SetOption(name, value); err != nil {
+				fmt.Printf("info string %s\n", err)
+			}
 		case "ucinewgame":
+			waitSearch()
 			board = NewBoard()
 			board.LoadInitial()
+			if err := engine.NewGame(); err != nil {
+				fmt.Printf("info string %s\n", err)
+			}
 		case "position":
 			board.ParseAndApplyPosition(fields[1:])
 		case "go":
+			// A search still winding down after "stop" must send its bestmove first
+			waitSearch()
 			goOpts := ParseGoOptions(fields)
-			optimalTime, maxTime := goOpts.CalcTimeLimit(board.WhiteToMove)
-
+			ctx, cancel := context.WithCancel(context.Background())
+			infos, err := engine.Analyze(ctx, board, goOpts)
+			if err != nil {
+				cancel()
+				fmt.Printf("info string %s\n", err)
+				continue
+			}
+			done := make(chan struct{})
 			searchMu.Lock()
-			stopChan = make(chan struct{})
-			currentStop := stopChan
+			stopSearch = cancel
+			searchDone = done
 			searchMu.Unlock()
 
-			opts := SearchOptions{
-				TimeLimitInMs:    optimalTime,
-				MaxTimeLimitInMs: maxTime,
-				MaxDepth:         goOpts.Depth,
-				StopChan:         currentStop,
-			}
-
 			go func() {
-				bestMove := board.IterativeDeepeningSearch(opts)
-				if bestMove != nil {
-					fmt.Printf("bestmove %s\n", bestMove.ToUCI())
-				} else {
-					fmt.Println("bestmove 0000")
+				defer close(done)
+				defer cancel()
+				for info := range infos {
+					if !info.Final {
+						fmt.Println(info.UCI())
+						continue
+					}
+					if info.BestMove != nil {
+						fmt.Printf("bestmove %s\n", info.BestMove.ToUCI())
+					} else {
+						fmt.Println("bestmove 0000")
+					}
 				}
 			}()
+		case "eval":
+			fmt.Print(board.EvaluateTrace())
 		case "stop":
 			searchMu.Lock()
-			if stopChan != nil {
-				close(stopChan)
-				stopChan = nil
+			if stopSearch != nil {
+				stopSearch()
+				stopSearch = nil
 			}
 			searchMu.Unlock()
 		case "quit":
 			searchMu.Lock()
-			if stopChan != nil {
-				close(stopChan)
+			if stopSearch != nil {
+				stopSearch()
 			}
 			searchMu.Unlock()
 			return
@@ -39,6 +39,13 @@ type Board struct {
 	HalfMoveClock int
 	// FullMoveCounter counts the number of full moves (incremented after Black's move)
 	FullMoveCounter int
+
+	// pawnTable caches the pawn structure evaluation, owned by the search thread using the board
+	pawnTable *PawnHashTable
+	// nnue holds the network accumulators of the positions played, nil until evaluated by a network
+	nnue *nnueState
+	// material is the material signature with materialKnown set, 0 until evaluated
+	material uint64
 }
 
 // NewBoard creates a new, empty board. You must call LoadInitial or FromFEN to set up a position.
@@ -93,6 +100,8 @@ func (board *Board) Reset() {
 	board.OnPassant = 0
 	board.HalfMoveClock = 0
 	board.FullMoveCounter = 1
+	board.nnue = nil
+	board.material = 0
 }
 
 // LoadInitial sets up the board to the standard chess starting position.
@@ -354,6 +363,16 @@ func (board *Board) OccupiedSquares() uint64 {
 		board.BlackPawns | board.BlackKnights | board.BlackBishops | board.BlackRooks | board.BlackQueens | board.BlackKing
 }
 
+// WhitePieces returns the squares occupied by white pieces
+func (board *Board) WhitePieces() uint64 {
+	return board.WhitePawns | board.WhiteKnights | board.WhiteBishops | board.WhiteRooks | board.WhiteQueens | board.WhiteKing
+}
+
+// BlackPieces returns the squares occupied by black pieces
+func (board *Board) BlackPieces() uint64 {
+	return board.BlackPawns | board.BlackKnights | board.BlackBishops | board.BlackRooks | board.BlackQueens | board.BlackKing
+}
+
 // IsSquareOnPassant returns true if the square is the current en passant target square.
 func (board *Board) IsSquareOnPassant(square byte) bool {
 	return board.OnPassant == square
@@ -517,6 +536,7 @@ func (board *Board) Clone() *Board {
 	clone.OnPassant = board.OnPassant
 	clone.HalfMoveClock = board.HalfMoveClock
 	clone.FullMoveCounter = board.FullMoveCounter
+	clone.material = board.material
 	return clone
 }
 
@@ -570,6 +590,8 @@ func (board *Board) SetPiece(square byte, piece byte) {
 	board.BlackRooks &= mask
 	board.BlackQueens &= mask
 	board.BlackKing &= mask
+	board.nnue = nil
+	board.material = 0
 	// Set the new piece
 	if piece != 0 {
 		bit := uint64(1) << square
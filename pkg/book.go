@@ -0,0 +1,194 @@
+package libra
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/eugenioenko/libra-chess/books"
+)
+
+const (
+	DefaultBookFile       = "<internal>" // The book compiled into the engine, books/book.txt
+	DefaultBookDepth      = 20           // Last full move played from the book
+	MaxBookDepth          = 100
+	DefaultBookRandomness = 100 // Moves picked in proportion to their counts
+	MaxBookRandomness     = 200 // Moves picked in proportion to the square roots of their counts
+)
+
+var ErrInvalidBook = errors.New("invalid opening book")
+
+// BookMove is a move of a book position with its weight, the number of games it
+// was played in for books/book.txt
+type BookMove struct {
+	Move   Move
+	Weight int
+}
+
+// Book is an opening book: the moves played from each position, looked up by the
+// Polyglot key of the position, see PolyglotKey. Depth, Randomness and Rand are
+// read by PickMove and may be changed between searches.
+type Book struct {
+	Depth      int        // Last full move played from the book, 0 = no limit
+	Randomness int        // 0 to MaxBookRandomness, see PickMove
+	Rand       *rand.Rand // Optional random source, defaults to the global one
+
+	positions map[uint64][]bookEntry
+}
+
+// bookEntry is a move of a book position in the Polyglot format, see polyglotMove
+type bookEntry struct {
+	move   uint16
+	weight int
+}
+
+// NewBook creates an empty book with the default depth and randomness
+func NewBook() *Book {
+	return &Book{
+		Depth:      DefaultBookDepth,
+		Randomness: DefaultBookRandomness,
+		positions:  map[uint64][]bookEntry{},
+	}
+}
+
+// LoadBook reads a book in the books/book.txt format: a "pos <FEN>" line for every
+// position, followed by its moves as "<UCI move> <count>" lines. Empty lines and
+// lines starting with '#' are skipped, and the moves must be legal.
+func LoadBook(r io.Reader) (*Book, error) {
+	book := NewBook()
+	var board *Board
+	scanner := bufio.NewScanner(r)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if fen, ok := strings.CutPrefix(line, "pos "); ok {
+			board = NewBoard()
+			if _, err := board.FromFEN(strings.TrimSpace(fen)); err != nil {
+				return nil, fmt.Errorf("line %d: %w", lineNumber, err)
+			}
+			continue
+		}
+		fields := strings.Fields(line)
+		if board == nil || len(fields) != 2 {
+			return nil, fmt.Errorf("line %d: %w: expected a position or a move and its count, got %q", lineNumber, ErrInvalidBook, line)
+		}
+		move := board.ParseUCIMove(fields[0])
+		count, err := strconv.Atoi(fields[1])
+		if move == nil || err != nil || count <= 0 {
+			return nil, fmt.Errorf("line %d: %w: %q in %s", lineNumber, ErrInvalidBook, line, board.ToFEN())
+		}
+		book.Add(board, *move, count)
+	}
+	return book, scanner.Err()
+}
+
+// LoadBookFile reads the book saved at path, a Polyglot book for the
+// PolyglotExtension and one in the books/book.txt format otherwise
+func LoadBookFile(path string) (*Book, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	if strings.EqualFold(filepath.Ext(path), PolyglotExtension) {
+		return LoadPolyglot(bufio.NewReader(file))
+	}
+	return LoadBook(file)
+}
+
+// InternalBook reads the book compiled into the engine, books/book.txt
+func InternalBook() (*Book, error) {
+	return LoadBook(bytes.NewReader(books.Book))
+}
+
+// Add adds weight to a move of the position, which must be legal
+func (book *Book) Add(board *Board, move Move, weight int) {
+	book.add(board.PolyglotKey(), polyglotMove(move), weight)
+}
+
+func (book *Book) add(key uint64, move uint16, weight int) {
+	entries := book.positions[key]
+	for i := range entries {
+		if entries[i].move == move {
+			entries[i].weight += weight
+			return
+		}
+	}
+	book.positions[key] = append(entries, bookEntry{move: move, weight: weight})
+}
+
+// Len returns the number of positions of the book
+func (book *Book) Len() int {
+	return len(book.positions)
+}
+
+// Moves returns the legal moves of the position in the book, most played first
+func (book *Book) Moves(board *Board) []BookMove {
+	if book == nil {
+		return nil
+	}
+	entries := book.positions[board.PolyglotKey()]
+	if len(entries) == 0 {
+		return nil
+	}
+	var moves []BookMove
+	for _, move := range board.GenerateLegalMoves() {
+		code := polyglotMove(move)
+		for _, entry := range entries {
+			if entry.move == code && entry.weight > 0 {
+				moves = append(moves, BookMove{Move: move, Weight: entry.weight})
+			}
+		}
+	}
+	sort.SliceStable(moves, func(i, j int) bool {
+		return moves[i].Weight > moves[j].Weight
+	})
+	return moves
+}
+
+// PickMove returns a book move of the position, or nil when it is not in the book
+// or past Depth. Moves are picked with probabilities proportional to their weights
+// raised to the power of 100 / Randomness: 0 always plays the most played move,
+// 100 plays moves as often as they were played and MaxBookRandomness favors the
+// rarer moves more.
+func (book *Book) PickMove(board *Board) *Move {
+	moves := book.Moves(board)
+	if len(moves) == 0 || (book.Depth > 0 && board.FullMoveCounter > book.Depth) {
+		return nil
+	}
+	if book.Randomness <= 0 {
+		return &moves[0].Move
+	}
+	exponent := 100 / float64(MathMinInt(book.Randomness, MaxBookRandomness))
+	weights := make([]float64, len(moves))
+	total := 0.0
+	for i, move := range moves {
+		// Relative to the most played move, so large counts do not overflow
+		weights[i] = math.Pow(float64(move.Weight)/float64(moves[0].Weight), exponent)
+		total += weights[i]
+	}
+	var r float64
+	if book.Rand != nil {
+		r = book.Rand.Float64() * total
+	} else {
+		r = rand.Float64() * total
+	}
+	for i, weight := range weights {
+		if r < weight {
+			return &moves[i].Move
+		}
+		r -= weight
+	}
+	return &moves[0].Move
+}
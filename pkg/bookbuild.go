@@ -0,0 +1,217 @@
+package libra
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// BookMoveStats are the statistics of a move of a book position over the games
+// it was played in. Wins and losses are from the side playing the move.
+type BookMoveStats struct {
+	Move      Move
+	Games     int
+	Wins      int
+	Draws     int
+	Losses    int
+	RatingSum int // Sum of the ratings of the players of the move, over Rated games
+	Rated     int
+}
+
+// Score returns the share of the points the side playing the move scored over
+// the games with a known result, 0.5 without any
+func (stats *BookMoveStats) Score() float64 {
+	decided := stats.Wins + stats.Draws + stats.Losses
+	if decided == 0 {
+		return 0.5
+	}
+	return (float64(stats.Wins) + float64(stats.Draws)/2) / float64(decided)
+}
+
+// AverageRating returns the average rating of the players of the move, 0 if unknown
+func (stats *BookMoveStats) AverageRating() int {
+	if stats.Rated == 0 {
+		return 0
+	}
+	return stats.RatingSum / stats.Rated
+}
+
+// BookPosition is a position of a built book with the statistics of its moves,
+// most played first
+type BookPosition struct {
+	FEN   string // Without the move counters, as in books/book.txt
+	Moves []BookMoveStats
+}
+
+// BookFilter selects the moves of a built book
+type BookFilter struct {
+	MinGames  int     // Least games a move was played in
+	MinScore  float64 // Least score of the side playing the move, 0 to 1
+	MinRating int     // Least average rating of the players of the move, 0 = any
+}
+
+// BookBuilder accumulates the moves played in games into an opening book
+type BookBuilder struct {
+	MaxPly int // Moves recorded from the first MaxPly plies of every game, 0 = all
+	Games  int // Games added
+
+	positions map[uint64]*BookPosition
+	order     []uint64 // Keys of the positions in the order they were first reached
+}
+
+// NewBookBuilder creates a builder recording the first maxPly plies of the games
+func NewBookBuilder(maxPly int) *BookBuilder {
+	return &BookBuilder{MaxPly: maxPly, positions: map[uint64]*BookPosition{}}
+}
+
+// AddGame replays the moves of a game and records them with its result and the
+// ratings of its players. A game with an illegal or unreadable move in its
+// first MaxPly plies is not recorded at all.
+func (builder *BookBuilder) AddGame(game *PGNGame) error {
+	board, err := game.Start()
+	if err != nil {
+		return err
+	}
+	sans := game.Moves
+	if builder.MaxPly > 0 && len(sans) > builder.MaxPly {
+		sans = sans[:builder.MaxPly]
+	}
+	type played struct {
+		key  uint64
+		fen  string
+		move Move
+	}
+	moves := make([]played, 0, len(sans))
+	whiteToMove := make([]bool, 0, len(sans))
+	for ply, san := range sans {
+		move, err := board.ParseSAN(san)
+		if err != nil {
+			return fmt.Errorf("ply %d: %w", ply+1, err)
+		}
+		fen := strings.Join(strings.Fields(board.ToFEN())[:4], " ")
+		moves = append(moves, played{key: board.PolyglotKey(), fen: fen, move: *move})
+		whiteToMove = append(whiteToMove, board.WhiteToMove)
+		board.Move(*move)
+	}
+
+	ratings := [2]int{}
+	ratings[0], _ = strconv.Atoi(game.Tags["WhiteElo"])
+	ratings[1], _ = strconv.Atoi(game.Tags["BlackElo"])
+	for i, p := range moves {
+		position, ok := builder.positions[p.key]
+		if !ok {
+			position = &BookPosition{FEN: p.fen}
+			builder.positions[p.key] = position
+			builder.order = append(builder.order, p.key)
+		}
+		stats := position.stats(p.move)
+		stats.Games++
+		color := ColorWhite
+		if !whiteToMove[i] {
+			color = ColorBlack
+		}
+		switch game.Result {
+		case "1/2-1/2":
+			stats.Draws++
+		case "1-0", "0-1":
+			if (game.Result == "1-0") == (color == ColorWhite) {
+				stats.Wins++
+			} else {
+				stats.Losses++
+			}
+		}
+		if ratings[color] > 0 {
+			stats.RatingSum += ratings[color]
+			stats.Rated++
+		}
+	}
+	builder.Games++
+	return nil
+}
+
+// stats returns the statistics of a move of the position, adding them if needed
+func (position *BookPosition) stats(move Move) *BookMoveStats {
+	for i := range position.Moves {
+		if position.Moves[i].Move == move {
+			return &position.Moves[i]
+		}
+	}
+	position.Moves = append(position.Moves, BookMoveStats{Move: move})
+	return &position.Moves[len(position.Moves)-1]
+}
+
+// Positions returns the positions with the moves passing the filter, most played
+// first, in the order the positions were first reached. Positions without such
+// moves are left out.
+func (builder *BookBuilder) Positions(filter BookFilter) []BookPosition {
+	var positions []BookPosition
+	for _, key := range builder.order {
+		position := builder.positions[key]
+		var moves []BookMoveStats
+		for _, stats := range position.Moves {
+			if stats.Games >= filter.MinGames && stats.Score() >= filter.MinScore &&
+				(filter.MinRating == 0 || stats.AverageRating() >= filter.MinRating) {
+				moves = append(moves, stats)
+			}
+		}
+		if len(moves) == 0 {
+			continue
+		}
+		sort.SliceStable(moves, func(i, j int) bool { return moves[i].Games > moves[j].Games })
+		positions = append(positions, BookPosition{FEN: position.FEN, Moves: moves})
+	}
+	return positions
+}
+
+// SaveBookText writes positions in the books/book.txt format read by LoadBook
+func SaveBookText(w io.Writer, positions []BookPosition) error {
+	writer := bufio.NewWriter(w)
+	for _, position := range positions {
+		fmt.Fprintf(writer, "pos %s\n", position.FEN)
+		for _, stats := range position.Moves {
+			fmt.Fprintf(writer, "%s %d\n", stats.Move.ToUCI(), stats.Games)
+		}
+	}
+	return writer.Flush()
+}
+
+// SaveBookJSON writes positions in the books/book.json format of the web
+// interface: an object from the ZobristHashWasm of every position, in hex, to its
+// moves in UCI notation, most played first. The statistics are left out.
+func SaveBookJSON(w io.Writer, positions []BookPosition) error {
+	book := map[string][]string{}
+	board := NewBoard()
+	for _, position := range positions {
+		if _, err := board.FromFEN(position.FEN); err != nil {
+			return err
+		}
+		moves := make([]string, len(position.Moves))
+		for i, stats := range position.Moves {
+			moves[i] = stats.Move.ToUCI()
+		}
+		book[fmt.Sprintf("%#x", board.ZobristHashWasm())] = moves
+	}
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "\t")
+	return encoder.Encode(book)
+}
+
+// NewBookFromPositions creates a book of positions weighted by their games, to
+// be played or saved with SavePolyglot
+func NewBookFromPositions(positions []BookPosition) (*Book, error) {
+	book := NewBook()
+	board := NewBoard()
+	for _, position := range positions {
+		if _, err := board.FromFEN(position.FEN); err != nil {
+			return nil, err
+		}
+		for _, stats := range position.Moves {
+			book.Add(board, stats.Move, stats.Games)
+		}
+	}
+	return book, nil
+}
@@ -1,5 +1,7 @@
 package libra
 
+import "math/bits"
+
 // RookRays [square][direction]
 var RookRays [64][4]uint64
 
@@ -20,6 +22,41 @@ var BoardDirOffsets = [8]int8{-8, 1, 8, -1, -7, 9, 7, -9} // N, E, S, W, NE, SE,
 // SquaresToEdge [square][direction]
 var SquaresToEdge [64][8]byte
 
+// Color indexes of the [2] tables below
+const (
+	ColorWhite = 0
+	ColorBlack = 1
+)
+
+// FileMasks [file] has every square of the file set, file 0 is the a-file
+var FileMasks [8]uint64
+
+// AdjacentFileMasks [file] has every square of the neighbouring files set
+var AdjacentFileMasks [8]uint64
+
+// ForwardRanks [color][row] has every square of the rows ahead of row from color's side set.
+// Rows are numbered like squares, row 0 is the 8th rank.
+var ForwardRanks [2][8]uint64
+
+// ForwardFileMasks [color][square] has the squares in front of square on its file set
+var ForwardFileMasks [2][64]uint64
+
+// PassedPawnMasks [color][square] has the squares in front of square on its file and the
+// adjacent files set: a pawn is passed if no enemy pawn stands on them
+var PassedPawnMasks [2][64]uint64
+
+// PawnAttacks [color][square] has the squares attacked by a pawn of color on square set
+var PawnAttacks [2][64]uint64
+
+// SquareDistance [a][b] is the number of king moves between two squares
+var SquareDistance [64][64]int
+
+// KnightAttacks [square] has the squares attacked by a knight on square set
+var KnightAttacks [64]uint64
+
+// KingAttacks [square] has the squares attacked by a king on square set
+var KingAttacks [64]uint64
+
 func init() {
 	// Initialize SquaresToEdge
 	for i := 0; i < 64; i++ {
@@ -157,6 +194,93 @@ func init() {
 	}
 }
 
+func init() {
+	// Initialize the pawn structure masks
+	for file := 0; file < 8; file++ {
+		for row := 0; row < 8; row++ {
+			FileMasks[file] |= 1 << (row*8 + file)
+		}
+	}
+	for file := 0; file < 8; file++ {
+		if file > 0 {
+			AdjacentFileMasks[file] |= FileMasks[file-1]
+		}
+		if file < 7 {
+			AdjacentFileMasks[file] |= FileMasks[file+1]
+		}
+	}
+	for row := 0; row < 8; row++ {
+		for ahead := 0; ahead < row; ahead++ {
+			ForwardRanks[ColorWhite][row] |= 0xFF << (ahead * 8)
+		}
+		for ahead := row + 1; ahead < 8; ahead++ {
+			ForwardRanks[ColorBlack][row] |= 0xFF << (ahead * 8)
+		}
+	}
+	for sq := 0; sq < 64; sq++ {
+		row, file := sq/8, sq%8
+		for color := ColorWhite; color <= ColorBlack; color++ {
+			ForwardFileMasks[color][sq] = ForwardRanks[color][row] & FileMasks[file]
+			PassedPawnMasks[color][sq] = ForwardRanks[color][row] & (FileMasks[file] | AdjacentFileMasks[file])
+		}
+		if row > 0 {
+			if file > 0 {
+				PawnAttacks[ColorWhite][sq] |= 1 << (sq - 9)
+			}
+			if file < 7 {
+				PawnAttacks[ColorWhite][sq] |= 1 << (sq - 7)
+			}
+		}
+		if row < 7 {
+			if file > 0 {
+				PawnAttacks[ColorBlack][sq] |= 1 << (sq + 7)
+			}
+			if file < 7 {
+				PawnAttacks[ColorBlack][sq] |= 1 << (sq + 9)
+			}
+		}
+		for other := 0; other < 64; other++ {
+			SquareDistance[sq][other] = MathMaxInt(abs(row-other/8), abs(file-other%8))
+		}
+		for i := 0; i < 8; i++ {
+			if KnightOffsets[sq][i] < 64 {
+				KnightAttacks[sq] |= 1 << KnightOffsets[sq][i]
+			}
+			if KingOffsets[sq][i] < 64 {
+				KingAttacks[sq] |= 1 << KingOffsets[sq][i]
+			}
+		}
+	}
+}
+
+// RookAttacks returns the squares attacked by a rook on square, stopping at occupied squares
+func RookAttacks(square int, occupied uint64) uint64 {
+	return rayAttacks(&RookRays, square, occupied)
+}
+
+// BishopAttacks returns the squares attacked by a bishop on square, stopping at occupied squares
+func BishopAttacks(square int, occupied uint64) uint64 {
+	return rayAttacks(&BishopRays, square, occupied)
+}
+
+// rayAttacks cuts every ray at its first blocker. Directions 1 and 2 of both ray
+// tables point to higher square indexes, directions 0 and 3 to lower ones.
+func rayAttacks(rays *[64][4]uint64, square int, occupied uint64) uint64 {
+	attacks := uint64(0)
+	for dir := 0; dir < 4; dir++ {
+		ray := rays[square][dir]
+		if blockers := ray & occupied; blockers != 0 {
+			blocker := 63 - bits.LeadingZeros64(blockers)
+			if dir == 1 || dir == 2 {
+				blocker = bits.TrailingZeros64(blockers)
+			}
+			ray ^= rays[blocker][dir]
+		}
+		attacks |= ray
+	}
+	return attacks
+}
+
 func min(a, b byte) byte {
 	if a < b {
 		return a
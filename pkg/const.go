@@ -246,11 +246,17 @@ const (
 	TotalPhase  = 24 // 4*1(knights) + 4*1(bishops) + 4*2(rooks) + 2*4(queens)
 )
 
-// PeSTO base material values (added to PST in init)
+// Active phase weights by piece (pawn, knight, bishop, rook, queen, king), set by ApplyEvalParams
+var phaseWeights = [6]int{PawnPhase, KnightPhase, BishopPhase, RookPhase, QueenPhase, 0}
+
+// Phase of the starting material with phaseWeights
+var phaseWeightsTotal = TotalPhase
+
+// PeSTO base material values (added to PST by ApplyEvalParams)
 var mgPieceValue = [6]int{82, 337, 365, 477, 1025, 0} // pawn, knight, bishop, rook, queen, king
 var egPieceValue = [6]int{94, 281, 297, 512, 936, 0}
 
-// PeSTO Piece-Square Tables (positional component, material added in init)
+// PeSTO Piece-Square Tables (positional component, material added by ApplyEvalParams)
 // Indexed from white's perspective: a8=0, h1=63
 
 // Middlegame tables
@@ -379,26 +385,3 @@ var egKingPST = [64]int{
 	-27, -11, 4, 13, 14, 4, -5, -17,
 	-53, -34, -21, -11, -28, -14, -24, -43,
 }
-
-func init() {
-	// Bake material values into PST tables
-	type pstPair struct {
-		mg    *[64]int
-		eg    *[64]int
-		piece int // index into mgPieceValue/egPieceValue
-	}
-	pairs := []pstPair{
-		{&mgPawnPST, &egPawnPST, 0},
-		{&mgKnightPST, &egKnightPST, 1},
-		{&mgBishopPST, &egBishopPST, 2},
-		{&mgRookPST, &egRookPST, 3},
-		{&mgQueenPST, &egQueenPST, 4},
-		{&mgKingPST, &egKingPST, 5},
-	}
-	for _, p := range pairs {
-		for sq := 0; sq < 64; sq++ {
-			p.mg[sq] += mgPieceValue[p.piece]
-			p.eg[sq] += egPieceValue[p.piece]
-		}
-	}
-}
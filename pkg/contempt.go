@@ -0,0 +1,43 @@
+package libra
+
+import (
+	"strconv"
+	"strings"
+)
+
+const (
+	MaxContempt        = 100 // Contempt UCI option range is [-MaxContempt, MaxContempt] (cp)
+	DynamicContemptMax = 50  // Largest adjustment of the contempt from the rating difference (cp)
+)
+
+// DrawScore returns the score of a draw from white's perspective, given the contempt
+// of the side to move at the root. A positive contempt makes the root side avoid draws.
+func DrawScore(contempt int, rootWhiteToMove bool) int {
+	if rootWhiteToMove {
+		return -contempt
+	}
+	return contempt
+}
+
+// DynamicContempt adjusts the contempt by the rating difference with the opponent:
+// 1cp per 10 Elo the engine is stronger (more contempt) or weaker (less contempt),
+// up to DynamicContemptMax.
+func DynamicContempt(contempt int, ownElo int, opponentElo int) int {
+	adjustment := MathMaxInt(-DynamicContemptMax, MathMinInt((ownElo-opponentElo)/10, DynamicContemptMax))
+	return contempt + adjustment
+}
+
+// ParseUCIOpponent extracts the rating from a UCI_Opponent option value,
+// formatted as "<title> <rating> <computer|human> <name>", e.g. "GM 2800 human Gary Kasparov".
+// Returns false if the rating is "none" or missing.
+func ParseUCIOpponent(value string) (int, bool) {
+	fields := strings.Fields(value)
+	if len(fields) < 2 {
+		return 0, false
+	}
+	rating, err := strconv.Atoi(fields[1])
+	if err != nil || rating <= 0 {
+		return 0, false
+	}
+	return rating, true
+}
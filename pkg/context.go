@@ -1,13 +1,63 @@
 package libra
 
-const MaxSearchDepth = 32
+import (
+	"runtime"
+	"sync"
+	"sync/atomic"
+)
 
-// SearchContext holds per-search context (killer moves, history heuristic, etc.)
-type SearchContext struct {
+const (
+	MaxSearchDepth = 32
+	MaxPly         = 128 // Deepest ply reachable including quiescence search
+)
+
+const (
+	HistoryPieces = 13    // PieceToHistoryIndex values (1..12), 0 means no piece
+	HistoryMax    = 16384 // History scores are kept within [-HistoryMax, HistoryMax]
+)
+
+// SearchHeuristics are the move ordering tables a search context learns, kept
+// across moves and reset on a new game
+type SearchHeuristics struct {
 	KillerMoves [MaxSearchDepth][2]Move
-	// HistoryHeuristic[piece][toSquare] (simple version)
-	HistoryHeuristic [16][64]int   // 16 piece types, 64 squares
-	Done             chan struct{} // Channel to signal cancellation
+	// ButterflyHistory[color][from][to], color 0 is white and 1 is black
+	ButterflyHistory [2][64][64]int
+	// CounterMoves[piece][to] is the quiet move that refuted the previous move of piece to square
+	CounterMoves [HistoryPieces][64]Move
+	// ContinuationHistory[prevPiece][prevTo][piece][to] scores a quiet move by the move
+	// played 1 or 2 plies before it
+	ContinuationHistory [HistoryPieces][64][HistoryPieces][64]int16
+	// CaptureHistory[piece][to][capturedPiece]
+	CaptureHistory [HistoryPieces][64][HistoryPieces]int
+}
+
+// SearchContext holds the per-thread search state (killer moves, history tables, etc.)
+// A context is owned by a single worker and lives across iterations and moves,
+// see SearchThreads.
+type SearchContext struct {
+	SearchHeuristics
+	// MoveStack[ply] is the move played at ply, MoveStack[0] is the root move
+	MoveStack [MaxPly]Move
+	Done      chan struct{}  // Channel to signal cancellation
+	NodeLimit uint64         // Stop once this many nodes were searched in the iteration, 0 = no limit
+	DrawScore int            // Score of draws from white's perspective, biased by the contempt
+	Tracer    SearchTracer   // Optional search tree tracer
+	PawnTable *PawnHashTable // Pawn structure cache of the thread, nil = no caching
+	Params    *SearchParams  // Search parameters, nil = DefaultSearchParams
+	// SelDepth is the deepest ply reached in the current iteration, including quiescence search
+	SelDepth int
+	// NodesAtPly[ply] counts the nodes visited at ply in the current iteration
+	NodesAtPly [MaxPly]uint64
+
+	traceIDs [MaxPly]int // traced node id at each ply, -1 when not traced
+}
+
+// SearchParams returns the parameters of the search using the context
+func (info *SearchContext) SearchParams() *SearchParams {
+	if info.Params == nil {
+		return &defaultSearchParams
+	}
+	return info.Params
 }
 
 // IsKillerMove returns true if the move is a killer move at the given ply
@@ -23,3 +73,214 @@ func (info *SearchContext) AddKillerMove(move Move, ply int) {
 	info.KillerMoves[ply][1] = info.KillerMoves[ply][0]
 	info.KillerMoves[ply][0] = move
 }
+
+// IsStopped returns true if the search was cancelled or ran out of nodes
+func (info *SearchContext) IsStopped(stats *SearchResult) bool {
+	select {
+	case <-info.Done:
+		return true
+	default:
+	}
+	return info.NodeLimit > 0 && atomic.LoadUint64(&stats.NodesSearched) >= info.NodeLimit
+}
+
+// VisitNode records a node visited at ply for the selective depth and node distribution
+func (info *SearchContext) VisitNode(ply int) {
+	if ply > info.SelDepth {
+		info.SelDepth = ply
+	}
+	info.NodesAtPly[ply]++
+}
+
+// ResetDepthStats clears the selective depth and node distribution before an iteration
+func (info *SearchContext) ResetDepthStats() {
+	info.SelDepth = 0
+	info.NodesAtPly = [MaxPly]uint64{}
+}
+
+// PreviousMove returns the move played n plies before the node at ply, if any
+func (info *SearchContext) PreviousMove(ply int, n int) (Move, bool) {
+	if ply-n < 0 || info.MoveStack[ply-n] == (Move{}) {
+		return Move{}, false
+	}
+	return info.MoveStack[ply-n], true
+}
+
+// CounterMove returns the counter move to the move played just before ply, if any
+func (info *SearchContext) CounterMove(ply int) (Move, bool) {
+	prev, ok := info.PreviousMove(ply, 1)
+	if !ok {
+		return Move{}, false
+	}
+	counter := info.CounterMoves[PieceToHistoryIndex[prev.Piece]][prev.To]
+	return counter, counter != Move{}
+}
+
+// QuietHistory returns the combined butterfly and continuation history score of a quiet move at ply
+func (info *SearchContext) QuietHistory(move Move, whiteToMove bool, ply int) int {
+	piece := PieceToHistoryIndex[move.Piece]
+	score := info.ButterflyHistory[colorIndex(whiteToMove)][move.From][move.To]
+	for n := 1; n <= 2; n++ {
+		if prev, ok := info.PreviousMove(ply, n); ok {
+			score += int(info.ContinuationHistory[PieceToHistoryIndex[prev.Piece]][prev.To][piece][move.To])
+		}
+	}
+	return score
+}
+
+// CaptureHistoryScore returns the capture history score of a capture
+func (info *SearchContext) CaptureHistoryScore(move Move) int {
+	return info.CaptureHistory[PieceToHistoryIndex[move.Piece]][move.To][PieceToHistoryIndex[move.Captured]]
+}
+
+// UpdateQuietHistory rewards the quiet move that caused a beta cutoff at ply and
+// penalizes the quiet moves searched before it
+func (info *SearchContext) UpdateQuietHistory(best Move, searched []Move, whiteToMove bool, depth int, ply int) {
+	bonus := info.SearchParams().historyBonus(depth)
+	info.AddKillerMove(best, ply)
+	if prev, ok := info.PreviousMove(ply, 1); ok {
+		info.CounterMoves[PieceToHistoryIndex[prev.Piece]][prev.To] = best
+	}
+	info.updateQuietMove(best, whiteToMove, ply, bonus)
+	for _, move := range searched {
+		if move != best {
+			info.updateQuietMove(move, whiteToMove, ply, -bonus)
+		}
+	}
+}
+
+// UpdateCaptureHistory rewards the capture that caused a beta cutoff and
+// penalizes the captures searched before it
+func (info *SearchContext) UpdateCaptureHistory(best Move, searched []Move, depth int) {
+	bonus := info.SearchParams().historyBonus(depth)
+	info.updateCapture(best, bonus)
+	for _, move := range searched {
+		if move != best {
+			info.updateCapture(move, -bonus)
+		}
+	}
+}
+
+func (info *SearchContext) updateQuietMove(move Move, whiteToMove bool, ply int, bonus int) {
+	piece := PieceToHistoryIndex[move.Piece]
+	applyHistoryGravity(&info.ButterflyHistory[colorIndex(whiteToMove)][move.From][move.To], bonus)
+	for n := 1; n <= 2; n++ {
+		if prev, ok := info.PreviousMove(ply, n); ok {
+			entry := &info.ContinuationHistory[PieceToHistoryIndex[prev.Piece]][prev.To][piece][move.To]
+			value := int(*entry)
+			applyHistoryGravity(&value, bonus)
+			*entry = int16(value)
+		}
+	}
+}
+
+func (info *SearchContext) updateCapture(move Move, bonus int) {
+	applyHistoryGravity(&info.CaptureHistory[PieceToHistoryIndex[move.Piece]][move.To][PieceToHistoryIndex[move.Captured]], bonus)
+}
+
+// Age scales down the history tables and forgets the killer moves, so knowledge
+// from previous moves of the game is kept but newer results dominate
+func (info *SearchContext) Age() {
+	info.KillerMoves = [MaxSearchDepth][2]Move{}
+	for color := range info.ButterflyHistory {
+		for from := range info.ButterflyHistory[color] {
+			for to := range info.ButterflyHistory[color][from] {
+				info.ButterflyHistory[color][from][to] /= 2
+			}
+		}
+	}
+	for prevPiece := range info.ContinuationHistory {
+		for prevTo := range info.ContinuationHistory[prevPiece] {
+			for piece := range info.ContinuationHistory[prevPiece][prevTo] {
+				for to := range info.ContinuationHistory[prevPiece][prevTo][piece] {
+					info.ContinuationHistory[prevPiece][prevTo][piece][to] /= 2
+				}
+			}
+		}
+	}
+	for piece := range info.CaptureHistory {
+		for to := range info.CaptureHistory[piece] {
+			for captured := range info.CaptureHistory[piece][to] {
+				info.CaptureHistory[piece][to][captured] /= 2
+			}
+		}
+	}
+}
+
+// Clear resets all the heuristics and the pawn structure cache, e.g. on a new game
+func (info *SearchContext) Clear() {
+	info.SearchHeuristics = SearchHeuristics{}
+	if info.PawnTable != nil {
+		info.PawnTable.Clear()
+	}
+}
+
+// applyHistoryGravity adds bonus to a history entry, scaled so the entry stays within
+// [-HistoryMax, HistoryMax] and large values are harder to grow further
+func applyHistoryGravity(entry *int, bonus int) {
+	*entry += bonus - *entry*abs(bonus)/HistoryMax
+}
+
+func colorIndex(white bool) int {
+	if white {
+		return 0
+	}
+	return 1
+}
+
+// SearchThreads owns the long-lived search contexts, one per root search worker.
+// Keeping them across iterations and moves preserves killer moves and history tables.
+type SearchThreads struct {
+	Contexts  []*SearchContext
+	Tracer    SearchTracer // Optional search tree tracer handed to every context
+	NodeLimit uint64       // Node limit of the next iteration handed to every context, 0 = no limit
+	DrawScore int          // Draw score from white's perspective handed to every context
+	Params    SearchParams // Search parameters handed to every context
+}
+
+// NewSearchThreads creates n search contexts, n <= 0 uses one per available CPU
+func NewSearchThreads(n int) *SearchThreads {
+	if n <= 0 {
+		n = runtime.GOMAXPROCS(0)
+	}
+	threads := &SearchThreads{Contexts: make([]*SearchContext, n), Params: DefaultSearchParams()}
+	for i := range threads.Contexts {
+		threads.Contexts[i] = &SearchContext{PawnTable: NewPawnHashTable()}
+	}
+	return threads
+}
+
+// idleThreads keeps the contexts of searches given none, see acquireSearchThreads
+var idleThreads sync.Pool
+
+// acquireSearchThreads returns cleared contexts, one per available CPU, for a
+// search given none. The contexts of previous such searches are reused, since
+// the history tables and pawn caches take megabytes per context.
+func acquireSearchThreads() *SearchThreads {
+	threads, ok := idleThreads.Get().(*SearchThreads)
+	if !ok || len(threads.Contexts) != runtime.GOMAXPROCS(0) {
+		return NewSearchThreads(0)
+	}
+	*threads = SearchThreads{Contexts: threads.Contexts, Params: DefaultSearchParams()}
+	threads.Clear()
+	return threads
+}
+
+// releaseSearchThreads returns the contexts of a finished search to acquireSearchThreads
+func releaseSearchThreads(threads *SearchThreads) {
+	idleThreads.Put(threads)
+}
+
+// Age ages the heuristics of every context, called before searching a new position
+func (threads *SearchThreads) Age() {
+	for _, ctx := range threads.Contexts {
+		ctx.Age()
+	}
+}
+
+// Clear resets the heuristics of every context
+func (threads *SearchThreads) Clear() {
+	for _, ctx := range threads.Contexts {
+		ctx.Clear()
+	}
+}
@@ -0,0 +1,65 @@
+package libra
+
+import (
+	"fmt"
+	"strconv"
+)
+
+const (
+	DatagenMinPly   = 8     // Default DatagenFilter.MinPly
+	DatagenMaxScore = 2_000 // Default DatagenFilter.MaxScore (cp)
+)
+
+// DatagenFilter selects the self-play positions kept as training data. A position
+// is kept when the side to move is not in check, the searched best move is quiet
+// (no capture or promotion), the game is past MinPly and the search score is
+// within MaxScore, which also drops mate scores.
+type DatagenFilter struct {
+	MinPly   int // Plies played from the opening position before recording
+	MaxScore int // Largest absolute search score recorded (cp)
+}
+
+// DefaultDatagenFilter returns the filter of cmd/datagen
+func DefaultDatagenFilter() DatagenFilter {
+	return DatagenFilter{MinPly: DatagenMinPly, MaxScore: DatagenMaxScore}
+}
+
+// Accept reports whether the position before best move, found with score (white's)
+// at the given ply of the game, is kept
+func (filter DatagenFilter) Accept(board *Board, best Move, score int, ply int) bool {
+	return ply >= filter.MinPly &&
+		abs(score) <= filter.MaxScore &&
+		best.IsQuiet() &&
+		!board.IsInCheck()
+}
+
+// TrainingPosition is a recorded self-play position, labeled once the game is over
+type TrainingPosition struct {
+	FEN    string
+	Score  int     // Search score (cp), white's
+	Result float64 // Game result for white: 1 win, 0.5 draw, 0 loss
+}
+
+// String formats the position as "<fen> | <score> | <result>", both labels from
+// white's perspective, the text format read by ParseTuneEntry
+func (position TrainingPosition) String() string {
+	return fmt.Sprintf("%s | %d | %s", position.FEN, position.Score, strconv.FormatFloat(position.Result, 'f', 1, 64))
+}
+
+// GenerateTrainingGame plays a self-play game from start and returns the positions
+// accepted by filter, labeled with the game result
+func GenerateTrainingGame(white *SelfPlayer, black *SelfPlayer, start *Board, options SelfPlayOptions, filter DatagenFilter) []TrainingPosition {
+	var positions []TrainingPosition
+	ply := 0
+	options.OnMove = func(board *Board, move Move, score int) {
+		if filter.Accept(board, move, score, ply) {
+			positions = append(positions, TrainingPosition{FEN: board.ToFEN(), Score: score})
+		}
+		ply++
+	}
+	result := PlaySelfPlayGame(white, black, start, options)
+	for i := range positions {
+		positions[i].Result = result
+	}
+	return positions
+}
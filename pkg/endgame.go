@@ -0,0 +1,336 @@
+package libra
+
+import (
+	"math/bits"
+	"strings"
+)
+
+const (
+	EndgameKnownWin = 10_000 // Bonus of a won endgame over its material
+	ScaleNormal     = 64     // Scale factor leaving the evaluation unchanged
+	ScaleDraw       = 0
+)
+
+// Endgame scale factors, out of ScaleNormal
+var (
+	pureOppositeBishopsScale  = 16 // Only bishops of opposite colors and pawns left, pawn counts within one
+	pureOppositeBishopsScale2 = 32 // Same, with a larger pawn difference
+	oppositeBishopsScale      = 48 // Bishops of opposite colors with other pieces
+	pawnlessMinorUpScale      = 4  // No pawns, a minor up at most, against at most a minor
+	pawnlessMinorUpScale2     = 14 // Same, against more than a minor
+)
+
+// materialCounts are the pawns, knights, bishops, rooks and queens of each color
+type materialCounts [2][5]int
+
+func (board *Board) materialCounts() materialCounts {
+	counts := materialCounts{}
+	for color := ColorWhite; color <= ColorBlack; color++ {
+		pawns := board.WhitePawns
+		if color == ColorBlack {
+			pawns = board.BlackPawns
+		}
+		counts[color][0] = bits.OnesCount64(pawns)
+		for piece, bb := range board.pieceBitboards(color) {
+			counts[color][piece+1] = bits.OnesCount64(bb)
+		}
+	}
+	return counts
+}
+
+// materialKnown marks the material signature kept by a board, see materialSignature,
+// so the signature of bare kings, 0, is not mistaken for an unknown one
+const materialKnown = 1 << 63
+
+// materialUnits are the amounts a piece adds to a material signature, 0 for kings
+var materialUnits [256]uint64
+
+func init() {
+	for kind := 0; kind < 5; kind++ {
+		materialUnits[whitePieceCodes[kind]] = 1 << (4 * kind)
+		materialUnits[blackPieceCodes[kind]] = 1 << (4 * (5 + kind))
+	}
+}
+
+// materialSignature returns the material signature of the position, counted on
+// the first call and then kept up to date by Move and UndoMove
+func (board *Board) materialSignature() uint64 {
+	if board.material == 0 {
+		board.material = board.materialCounts().key() | materialKnown
+	}
+	return board.material &^ materialKnown
+}
+
+// updateMaterial applies the captures and promotions of a move being played to the
+// material signature, when kept
+func (board *Board) updateMaterial(move Move, piece byte) {
+	if board.material == 0 {
+		return
+	}
+	switch move.MoveType {
+	case MoveCapture, MovePromotionCapture:
+		board.material -= materialUnits[move.Captured]
+	case MoveEnPassant:
+		board.material -= materialUnits[piece^0x20]
+	}
+	if move.IsPromotion() {
+		board.material += materialUnits[move.Promoted]
+		board.material -= materialUnits[piece]
+	}
+}
+
+// materialCountsOf unpacks a material signature, the inverse of key
+func materialCountsOf(key uint64) materialCounts {
+	counts := materialCounts{}
+	for color := range counts {
+		for piece := range counts[color] {
+			counts[color][piece] = int(key>>(4*(color*5+piece))) & 15
+		}
+	}
+	return counts
+}
+
+// key packs the counts into a material signature, 4 bits per count
+func (counts materialCounts) key() uint64 {
+	key := uint64(0)
+	for color := range counts {
+		for piece, count := range counts[color] {
+			key |= uint64(MathMinInt(count, 15)) << (4 * (color*5 + piece))
+		}
+	}
+	return key
+}
+
+// nonPawnMaterial returns the middlegame value of the pieces of color
+func (counts materialCounts) nonPawnMaterial(color int) int {
+	value := 0
+	for piece := 1; piece < 5; piece++ {
+		value += counts[color][piece] * mgPieceValue[piece]
+	}
+	return value
+}
+
+// endgame is a specialized evaluator, scoring from the strong side's perspective
+type endgame struct {
+	name     string
+	strong   int
+	evaluate func(board *Board, strong int) int
+}
+
+// endgames dispatches material signatures to their specialized evaluator
+var endgames = map[uint64]endgame{}
+
+func init() {
+	registerEndgame("KPK", evaluateKPK)
+	registerEndgame("KBNK", evaluateKBNK)
+	for _, code := range []string{"KK", "KNK", "KBK", "KNNK"} {
+		registerEndgame(code, evaluateDraw)
+	}
+}
+
+// registerEndgame adds an evaluator for a signature like "KBNK", the strong side's
+// pieces then the weak side's, for both colors of the strong side
+func registerEndgame(code string, evaluate func(board *Board, strong int) int) {
+	split := strings.LastIndex(code, "K")
+	sides := [2]string{code[1:split], code[split+1:]}
+	for strong := ColorWhite; strong <= ColorBlack; strong++ {
+		counts := materialCounts{}
+		for side, pieces := range sides {
+			color := strong ^ side
+			for _, piece := range pieces {
+				counts[color][strings.IndexRune("PNBRQ", piece)]++
+			}
+		}
+		endgames[counts.key()] = endgame{name: code, strong: strong, evaluate: evaluate}
+	}
+}
+
+// evaluateEndgame returns the evaluation of the position with the endgame knowledge
+// applied: a specialized evaluator for known material signatures and lone kings, or
+// else evaluateGeneral, the evaluation without endgame knowledge, times a scale
+// factor for drawish material. Also returns the name of the rule applied, empty for none.
+func (board *Board) evaluateEndgame() (int, string) {
+	key := board.materialSignature()
+	if endgame, ok := endgames[key]; ok {
+		return fromStrongSide(endgame.evaluate(board, endgame.strong), endgame.strong), endgame.name
+	}
+	counts := materialCountsOf(key)
+	for strong := ColorWhite; strong <= ColorBlack; strong++ {
+		weak := strong ^ 1
+		if counts[weak] == [5]int{} && counts.nonPawnMaterial(strong) > 0 {
+			if score, name, ok := board.evaluateLoneKing(counts, strong); ok {
+				return fromStrongSide(score, strong), name
+			}
+		}
+	}
+	score := board.evaluateGeneral()
+	if score == 0 {
+		return 0, ""
+	}
+	strong := ColorWhite
+	if score < 0 {
+		strong = ColorBlack
+	}
+	scale, name := board.endgameScale(counts, strong)
+	return score * scale / ScaleNormal, name
+}
+
+func fromStrongSide(score int, strong int) int {
+	if strong == ColorBlack {
+		return -score
+	}
+	return score
+}
+
+// evaluateLoneKing is KXK: with mating material the strong side drives the lone
+// king to the edge and brings its own king close, without it and without pawns
+// the position is a draw. Other positions are left to the general evaluation.
+func (board *Board) evaluateLoneKing(counts materialCounts, strong int) (int, string, bool) {
+	pieces := counts[strong]
+	bishops := board.WhiteBishops
+	if strong == ColorBlack {
+		bishops = board.BlackBishops
+	}
+	bothBishops := bishops&darkSquares != 0 && bishops&^darkSquares != 0
+	mating := pieces[4] > 0 || pieces[3] > 0 || (pieces[1] > 0 && pieces[2] > 0) || bothBishops || pieces[1] > 2
+	if !mating {
+		if pieces[0] == 0 {
+			return 0, "KXK", true
+		}
+		return 0, "", false
+	}
+	// Stalemate, with the lone king to move
+	if board.WhiteToMove == (strong == ColorBlack) && !board.IsInCheck() && len(board.GenerateLegalMoves()) == 0 {
+		return 0, "KXK", true
+	}
+	strongKing, weakKing := board.kingSquare(strong), board.kingSquare(strong^1)
+	score := EndgameKnownWin + 20*pushToEdge(weakKing) + 10*(7-SquareDistance[strongKing][weakKing])
+	for piece, count := range pieces {
+		score += count * egPieceValue[piece]
+	}
+	return score, "KXK", true
+}
+
+func evaluateDraw(board *Board, strong int) int {
+	return 0
+}
+
+// evaluateKPK scores a won KPK position as a known win growing as the pawn advances
+func evaluateKPK(board *Board, strong int) int {
+	pawns := board.WhitePawns
+	if strong == ColorBlack {
+		pawns = board.BlackPawns
+	}
+	pawn := byte(bits.TrailingZeros64(pawns))
+	strongKing, weakKing := byte(board.kingSquare(strong)), byte(board.kingSquare(strong^1))
+	if !ProbeKPK(strong, strongKing, pawn, weakKing, board.WhiteToMove == (strong == ColorWhite)) {
+		return 0
+	}
+	return EndgameKnownWin + egPieceValue[0] + 10*relativeRank(strong, int(pawn)/8)
+}
+
+// evaluateKBNK drives the lone king to a corner of the bishop's color, the only
+// corners where bishop and knight can mate
+func evaluateKBNK(board *Board, strong int) int {
+	bishops := board.WhiteBishops
+	if strong == ColorBlack {
+		bishops = board.BlackBishops
+	}
+	strongKing, weakKing := board.kingSquare(strong), board.kingSquare(strong^1)
+	corners := [2]int{SquareA8, SquareH1}
+	if bishops&darkSquares != 0 {
+		corners = [2]int{SquareA1, SquareH8}
+	}
+	cornerDistance := MathMinInt(SquareDistance[weakKing][corners[0]], SquareDistance[weakKing][corners[1]])
+	return EndgameKnownWin + egPieceValue[1] + egPieceValue[2] +
+		40*(7-cornerDistance) + 10*(7-SquareDistance[strongKing][weakKing])
+}
+
+// endgameScale returns the scale factor of the evaluation for the strong side's
+// winning chances, with the name of the rule applied
+func (board *Board) endgameScale(counts materialCounts, strong int) (int, string) {
+	weak := strong ^ 1
+	strongMaterial, weakMaterial := counts.nonPawnMaterial(strong), counts.nonPawnMaterial(weak)
+
+	// Without pawns, a minor piece up is rarely enough
+	if counts[strong][0] == 0 && strongMaterial-weakMaterial <= mgPieceValue[2] {
+		switch {
+		case strongMaterial < mgPieceValue[3]:
+			return ScaleDraw, "pawnless minor"
+		case weakMaterial <= mgPieceValue[2]:
+			return pawnlessMinorUpScale, "pawnless minor"
+		default:
+			return pawnlessMinorUpScale2, "pawnless minor"
+		}
+	}
+
+	if scale, ok := board.wrongBishopScale(counts, strong); ok {
+		return scale, "wrong bishop"
+	}
+
+	if counts[strong][2] == 1 && counts[weak][2] == 1 {
+		whiteBishop, blackBishop := board.WhiteBishops&darkSquares != 0, board.BlackBishops&darkSquares != 0
+		if whiteBishop != blackBishop {
+			pure := strongMaterial == mgPieceValue[2] && weakMaterial == mgPieceValue[2]
+			switch {
+			case pure && abs(counts[strong][0]-counts[weak][0]) <= 1:
+				return pureOppositeBishopsScale, "opposite bishops"
+			case pure:
+				return pureOppositeBishopsScale2, "opposite bishops"
+			default:
+				return oppositeBishopsScale, "opposite bishops"
+			}
+		}
+	}
+	return ScaleNormal, ""
+}
+
+// wrongBishopScale detects a bishop and rook pawns of one file against a lone king
+// standing next to the promotion square when the bishop cannot control it: a draw
+func (board *Board) wrongBishopScale(counts materialCounts, strong int) (int, bool) {
+	weak := strong ^ 1
+	if counts[weak] != [5]int{} || counts[strong] != [5]int{counts[strong][0], 0, 1, 0, 0} {
+		return 0, false
+	}
+	pawns, bishops := board.WhitePawns, board.WhiteBishops
+	if strong == ColorBlack {
+		pawns, bishops = board.BlackPawns, board.BlackBishops
+	}
+	promotion := 0
+	switch {
+	case pawns&^FileMasks[0] == 0:
+		promotion = SquareA8
+	case pawns&^FileMasks[7] == 0:
+		promotion = SquareH8
+	default:
+		return 0, false
+	}
+	if strong == ColorBlack {
+		promotion += 56
+	}
+	darkPromotion := uint64(1)<<promotion&darkSquares != 0
+	if darkPromotion == (bishops&darkSquares != 0) {
+		return 0, false
+	}
+	if SquareDistance[board.kingSquare(weak)][promotion] > 1 {
+		return 0, false
+	}
+	return ScaleDraw, true
+}
+
+// darkSquares has a bit for every dark square (a1, h8)
+var darkSquares = func() uint64 {
+	mask := uint64(0)
+	for sq := 0; sq < 64; sq++ {
+		if (sq/8+sq%8)%2 == 1 {
+			mask |= 1 << sq
+		}
+	}
+	return mask
+}()
+
+// pushToEdge grows from 0 in the center to 6 in the corners
+func pushToEdge(sq int) int {
+	rank, file := sq/8, sq%8
+	return MathMaxInt(3-rank, rank-4) + MathMaxInt(3-file, file-4)
+}
@@ -0,0 +1,625 @@
+package libra
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	ErrSearchInProgress = errors.New("a search is already in progress")
+	ErrNilBoard         = errors.New("board is nil")
+	ErrUnknownOption    = errors.New("unknown option")
+	ErrInvalidOption    = errors.New("invalid option value")
+)
+
+// EngineOptions are the configurable settings of an Engine, see Engine.SetOption
+type EngineOptions struct {
+	MoveOverheadMs   int          // "Move Overhead": time reserved for GUI communication (ms)
+	SkillLevel       int          // "Skill Level": 0 to MaxSkillLevel
+	LimitStrength    bool         // "UCI_LimitStrength": use Elo instead of SkillLevel
+	Elo              int          // "UCI_Elo": MinSkillElo to MaxSkillElo, see NewSkillFromElo
+	Contempt         int          // "Contempt": draw score penalty for the side to move (cp)
+	DynamicContempt  bool         // "Dynamic Contempt": adjust the contempt with OpponentElo
+	OpponentElo      int          // Rating from "UCI_Opponent", 0 = unknown
+	HashMB           int          // "Hash": transposition table size (MB)
+	HashFile         string       // "Hash File": path used by "Save Hash" and "Load Hash"
+	EvalFile         string       // "EvalFile": network or evaluation parameters file, DefaultEvalFile for the compiled-in parameters
+	TablebasePath    string       // "TablebasePath": directory of the tables generated by cmd/tbgen, DefaultTablebasePath for none
+	SyzygyPath       string       // "SyzygyPath": directories of Syzygy tables, DefaultSyzygyPath for none
+	SyzygyProbeDepth int          // "SyzygyProbeDepth": least depth probing the Syzygy tables with the most pieces
+	Syzygy50MoveRule bool         // "Syzygy50MoveRule": score wins and losses spoiled by the 50-move rule as draws
+	OwnBook          bool         // "OwnBook": play book moves in timed searches
+	BookFile         string       // "BookFile": book in the books/book.txt format, DefaultBookFile for the compiled-in book
+	BookDepth        int          // "BookDepth": last full move played from the book
+	BookRandomness   int          // "BookRandomness": 0 to MaxBookRandomness, see Book.PickMove
+	Search           SearchParams // One UCI spin option per SearchParamSpecs entry
+}
+
+// DefaultEngineOptions returns the options of a new engine (full strength, no contempt)
+func DefaultEngineOptions() EngineOptions {
+	return EngineOptions{
+		MoveOverheadMs:   DefaultMoveOverheadMs,
+		SkillLevel:       MaxSkillLevel,
+		Elo:              MaxSkillElo,
+		DynamicContempt:  true,
+		HashMB:           DefaultHashMB,
+		HashFile:         DefaultHashFile,
+		EvalFile:         DefaultEvalFile,
+		TablebasePath:    DefaultTablebasePath,
+		SyzygyPath:       DefaultSyzygyPath,
+		SyzygyProbeDepth: DefaultSyzygyProbeDepth,
+		Syzygy50MoveRule: true,
+		OwnBook:          true,
+		BookFile:         DefaultBookFile,
+		BookDepth:        DefaultBookDepth,
+		BookRandomness:   DefaultBookRandomness,
+		Search:           DefaultSearchParams(),
+	}
+}
+
+// EngineOption describes an option in the UCI "option" format
+type EngineOption struct {
+	Name    string
+	Type    string // "spin", "check", "string" or "button"
+	Default string
+	Min     int // spin only
+	Max     int // spin only
+}
+
+// UCI returns the option as announced after the "uci" command
+func (opt EngineOption) UCI() string {
+	switch opt.Type {
+	case "spin":
+		return fmt.Sprintf("option name %s type spin default %s min %d max %d", opt.Name, opt.Default, opt.Min, opt.Max)
+	case "button":
+		return fmt.Sprintf("option name %s type button", opt.Name)
+	default:
+		return fmt.Sprintf("option name %s type %s default %s", opt.Name, opt.Type, opt.Default)
+	}
+}
+
+// SearchInfo is a search progress report streamed by Engine.Analyze.
+// Scores are from the perspective of the side to move, as in UCI.
+type SearchInfo struct {
+	Depth    int    // Depth of the completed iteration
+	SelDepth int    // Deepest ply reached, including quiescence search
+	Score    int    // Score in centipawns
+	Mate     int    // Moves to mate, negative when getting mated, 0 = no mate found
+	PV       []Move // Principal variation
+	Nodes    uint64 // Nodes searched since the start of the search
+	NPS      uint64 // Nodes per second
+	HashFull int    // Transposition table usage (permille)
+	TBHits   uint64 // Positions scored by the tablebase since the start of the search
+	TimeMs   int64  // Time since the start of the search (ms)
+	// NodesAtPly is the node distribution by ply of the iteration
+	NodesAtPly []uint64
+	BestMove   *Move // Move to play, set in the final report
+	Final      bool  // Last report of the search, sent once before the channel is closed
+}
+
+// UCI formats the report as a UCI "info" line
+func (info SearchInfo) UCI() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "info depth %d seldepth %d", info.Depth, info.SelDepth)
+	if info.Mate != 0 {
+		fmt.Fprintf(&sb, " score mate %d", info.Mate)
+	} else {
+		fmt.Fprintf(&sb, " score cp %d", info.Score)
+	}
+	fmt.Fprintf(&sb, " nodes %d nps %d hashfull %d", info.Nodes, info.NPS, info.HashFull)
+	if info.TBHits > 0 {
+		fmt.Fprintf(&sb, " tbhits %d", info.TBHits)
+	}
+	fmt.Fprintf(&sb, " time %d", info.TimeMs)
+	if len(info.PV) > 0 {
+		sb.WriteString(" pv")
+		for _, move := range info.PV {
+			sb.WriteString(" " + move.ToUCI())
+		}
+	}
+	return sb.String()
+}
+
+// Engine is the library entry point: it owns the transposition table, the options
+// and the long-lived search threads, and runs one search at a time without writing
+// to stdout. Several engines may search at once, but the evaluation and the
+// tablebases are shared by the whole process: the engine methods changing them
+// fail while any engine is searching, and ApplyEvalParams, SetNetwork,
+// SetTablebase and SetSyzygy must not be called during a search.
+type Engine struct {
+	Options EngineOptions // Do not modify while a search is in progress
+
+	tt        *TranspositionTable
+	threads   *SearchThreads
+	book      *Book // Loaded on first use for DefaultBookFile
+	mu        sync.Mutex
+	searching bool
+}
+
+// NewEngine creates an engine with the default options
+func NewEngine() *Engine {
+	return &Engine{
+		Options: DefaultEngineOptions(),
+		tt:      NewTranspositionTableMB(DefaultHashMB),
+		threads: NewSearchThreads(0),
+	}
+}
+
+// UCIOptions lists the options announced after the "uci" command
+func (engine *Engine) UCIOptions() []EngineOption {
+	options := []EngineOption{
+		{Name: "Hash", Type: "spin", Default: strconv.Itoa(DefaultHashMB), Min: 1, Max: MaxHashMB},
+		{Name: "Clear Hash", Type: "button"},
+		{Name: "Hash File", Type: "string", Default: DefaultHashFile},
+		{Name: "Save Hash", Type: "button"},
+		{Name: "Load Hash", Type: "button"},
+		{Name: "EvalFile", Type: "string", Default: DefaultEvalFile},
+		{Name: "TablebasePath", Type: "string", Default: DefaultTablebasePath},
+		{Name: "SyzygyPath", Type: "string", Default: DefaultSyzygyPath},
+		{Name: "SyzygyProbeDepth", Type: "spin", Default: strconv.Itoa(DefaultSyzygyProbeDepth), Min: 1, Max: MaxSyzygyProbeDepth},
+		{Name: "Syzygy50MoveRule", Type: "check", Default: "true"},
+		{Name: "OwnBook", Type: "check", Default: "true"},
+		{Name: "BookFile", Type: "string", Default: DefaultBookFile},
+		{Name: "BookDepth", Type: "spin", Default: strconv.Itoa(DefaultBookDepth), Min: 1, Max: MaxBookDepth},
+		{Name: "BookRandomness", Type: "spin", Default: strconv.Itoa(DefaultBookRandomness), Min: 0, Max: MaxBookRandomness},
+		{Name: "Move Overhead", Type: "spin", Default: strconv.Itoa(DefaultMoveOverheadMs), Min: 0, Max: MaxMoveOverheadMs},
+		{Name: "Skill Level", Type: "spin", Default: strconv.Itoa(MaxSkillLevel), Min: 0, Max: MaxSkillLevel},
+		{Name: "UCI_LimitStrength", Type: "check", Default: "false"},
+		{Name: "UCI_Elo", Type: "spin", Default: strconv.Itoa(MaxSkillElo), Min: MinSkillElo, Max: MaxSkillElo},
+		{Name: "Contempt", Type: "spin", Default: "0", Min: -MaxContempt, Max: MaxContempt},
+		{Name: "Dynamic Contempt", Type: "check", Default: "true"},
+		{Name: "UCI_Opponent", Type: "string", Default: "<empty>"},
+	}
+	for _, spec := range SearchParamSpecs {
+		options = append(options, spec.UCIOption())
+	}
+	return options
+}
+
+// SetOption sets an option by its UCI name (case insensitive)
+func (engine *Engine) SetOption(name string, value string) error {
+	options := &engine.Options
+	switch strings.ToLower(name) {
+	case "hash":
+		var hashMB int
+		if err := parseSpinOption(value, 1, MaxHashMB, &hashMB); err != nil {
+			return err
+		}
+		return engine.withIdleTable(func(tt *TranspositionTable) error {
+			tt.Resize(hashMB)
+			// Rounded down to a power of two, report the size in use like LoadHash
+			options.HashMB = tt.SizeMB()
+			return nil
+		})
+	case "clear hash":
+		return engine.withIdleTable(func(tt *TranspositionTable) error {
+			tt.Clear()
+			return nil
+		})
+	case "hash file":
+		options.HashFile = strings.TrimSpace(value)
+		return nil
+	case "save hash":
+		return engine.SaveHash(options.HashFile)
+	case "load hash":
+		return engine.LoadHash(options.HashFile)
+	case "evalfile":
+		return engine.LoadEvalFile(value)
+	case "tablebasepath":
+		return engine.LoadTablebase(value)
+	case "syzygypath":
+		return engine.LoadSyzygy(value)
+	case "syzygyprobedepth":
+		probeDepth := options.SyzygyProbeDepth
+		if err := parseSpinOption(value, 1, MaxSyzygyProbeDepth, &probeDepth); err != nil {
+			return err
+		}
+		return engine.configureSyzygy(probeDepth, options.Syzygy50MoveRule)
+	case "syzygy50moverule":
+		rule50 := options.Syzygy50MoveRule
+		if err := parseCheckOption(value, &rule50); err != nil {
+			return err
+		}
+		return engine.configureSyzygy(options.SyzygyProbeDepth, rule50)
+	case "ownbook":
+		return parseCheckOption(value, &options.OwnBook)
+	case "bookfile":
+		return engine.LoadBook(value)
+	case "bookdepth":
+		return parseSpinOption(value, 1, MaxBookDepth, &options.BookDepth)
+	case "bookrandomness":
+		return parseSpinOption(value, 0, MaxBookRandomness, &options.BookRandomness)
+	case "move overhead":
+		return parseSpinOption(value, 0, MaxMoveOverheadMs, &options.MoveOverheadMs)
+	case "skill level":
+		return parseSpinOption(value, 0, MaxSkillLevel, &options.SkillLevel)
+	case "uci_limitstrength":
+		return parseCheckOption(value, &options.LimitStrength)
+	case "uci_elo":
+		return parseSpinOption(value, MinSkillElo, MaxSkillElo, &options.Elo)
+	case "contempt":
+		return parseSpinOption(value, -MaxContempt, MaxContempt, &options.Contempt)
+	case "dynamic contempt":
+		return parseCheckOption(value, &options.DynamicContempt)
+	case "uci_opponent":
+		options.OpponentElo, _ = ParseUCIOpponent(value)
+		return nil
+	}
+	if spec, ok := FindSearchParamSpec(name); ok {
+		return parseSpinOption(value, spec.Min, spec.Max, spec.Value(&options.Search))
+	}
+	return fmt.Errorf("%w: %s", ErrUnknownOption, name)
+}
+
+// processMu guards processSearches, the searches running in every engine of the
+// process. It is locked before the mu of an engine.
+var (
+	processMu       sync.Mutex
+	processSearches int
+)
+
+// withIdleTable runs fn on the transposition table unless a search is using it
+func (engine *Engine) withIdleTable(fn func(tt *TranspositionTable) error) error {
+	engine.mu.Lock()
+	defer engine.mu.Unlock()
+	if engine.searching {
+		return ErrSearchInProgress
+	}
+	return fn(engine.tt)
+}
+
+// withIdleProcess runs fn on the transposition table unless any engine of the
+// process is searching, for the evaluation and the tablebases every search reads
+func (engine *Engine) withIdleProcess(fn func(tt *TranspositionTable) error) error {
+	processMu.Lock()
+	defer processMu.Unlock()
+	if processSearches > 0 {
+		return ErrSearchInProgress
+	}
+	return engine.withIdleTable(fn)
+}
+
+// SaveHash writes the transposition table to the file at path, so a long analysis
+// can be resumed later with LoadHash
+func (engine *Engine) SaveHash(path string) error {
+	return engine.withIdleTable(func(tt *TranspositionTable) error {
+		return tt.SaveFile(path)
+	})
+}
+
+// LoadHash replaces the transposition table with the one saved at path.
+// The "Hash" option is updated to the size of the loaded table.
+func (engine *Engine) LoadHash(path string) error {
+	return engine.withIdleTable(func(tt *TranspositionTable) error {
+		if err := tt.LoadFile(path); err != nil {
+			return err
+		}
+		engine.Options.HashMB = tt.SizeMB()
+		return nil
+	})
+}
+
+// LoadEvalFile switches the evaluation to the network or the classical parameters
+// saved at path, or back to the compiled-in parameters for an empty path or
+// DefaultEvalFile. A network file replaces the classical evaluation, which keeps its
+// compiled-in parameters. The evaluation is shared by every engine of the process,
+// so it fails with ErrSearchInProgress while any of them is searching. Cached
+// evaluations are cleared, in this engine only.
+func (engine *Engine) LoadEvalFile(path string) error {
+	return engine.withIdleProcess(func(tt *TranspositionTable) error {
+		params := DefaultEvalParams()
+		var network *Network
+		path = strings.TrimSpace(path)
+		if !isDefaultEvalFile(path) {
+			var err error
+			if isNetworkFile(path) {
+				network, err = LoadNetworkFile(path)
+			} else {
+				params, err = LoadEvalParamsFile(path)
+			}
+			if err != nil {
+				return err
+			}
+		}
+		if err := ApplyEvalParams(params); err != nil {
+			return err
+		}
+		SetNetwork(network)
+		if isDefaultEvalFile(path) {
+			path = DefaultEvalFile
+		}
+		engine.Options.EvalFile = path
+		tt.Clear()
+		engine.threads.Clear()
+		return nil
+	})
+}
+
+// LoadTablebase makes the searches probe the tables in the directory at path, or
+// none for an empty path or DefaultTablebasePath. The tablebase is shared by every
+// engine of the process, so it fails with ErrSearchInProgress while any of them is
+// searching. Cached scores are cleared, in this engine only.
+func (engine *Engine) LoadTablebase(path string) error {
+	return engine.withIdleProcess(func(tt *TranspositionTable) error {
+		path = strings.TrimSpace(path)
+		if path == "" {
+			path = DefaultTablebasePath
+		}
+		var tb *Tablebase
+		if path != DefaultTablebasePath {
+			var err error
+			if tb, err = LoadTablebase(path); err != nil {
+				return err
+			}
+		}
+		SetTablebase(tb)
+		engine.Options.TablebasePath = path
+		tt.Clear()
+		return nil
+	})
+}
+
+// LoadSyzygy makes the searches probe the Syzygy tables in the directories of
+// path, separated like the PATH environment variable, or none for an empty path
+// or DefaultSyzygyPath. The tables are shared by every engine of the process, so
+// it fails with ErrSearchInProgress while any of them is searching, and the
+// previous tables are closed. Cached scores are cleared, in this engine only.
+func (engine *Engine) LoadSyzygy(path string) error {
+	return engine.withIdleProcess(func(tt *TranspositionTable) error {
+		path = strings.TrimSpace(path)
+		if path == "" {
+			path = DefaultSyzygyPath
+		}
+		var tb *SyzygyTablebase
+		if path != DefaultSyzygyPath {
+			var err error
+			if tb, err = LoadSyzygy(path); err != nil {
+				return err
+			}
+			tb.ProbeDepth, tb.Rule50 = engine.Options.SyzygyProbeDepth, engine.Options.Syzygy50MoveRule
+		}
+		if previous := ActiveSyzygy(); previous != nil {
+			previous.Close()
+		}
+		SetSyzygy(tb)
+		engine.Options.SyzygyPath = path
+		tt.Clear()
+		return nil
+	})
+}
+
+// configureSyzygy sets the Syzygy probing options and applies them to the active tables
+func (engine *Engine) configureSyzygy(probeDepth int, rule50 bool) error {
+	return engine.withIdleProcess(func(tt *TranspositionTable) error {
+		engine.Options.SyzygyProbeDepth, engine.Options.Syzygy50MoveRule = probeDepth, rule50
+		if tb := ActiveSyzygy(); tb != nil {
+			tb.ProbeDepth, tb.Rule50 = probeDepth, rule50
+			tt.Clear()
+		}
+		return nil
+	})
+}
+
+// LoadBook makes the engine play the moves of the book saved at path, or of the
+// compiled-in book for an empty path or DefaultBookFile, see LoadBook
+func (engine *Engine) LoadBook(path string) error {
+	return engine.withIdleTable(func(tt *TranspositionTable) error {
+		path = strings.TrimSpace(path)
+		if path == "" {
+			path = DefaultBookFile
+		}
+		var book *Book
+		if path != DefaultBookFile {
+			var err error
+			if book, err = LoadBookFile(path); err != nil {
+				return err
+			}
+		}
+		engine.book = book
+		engine.Options.BookFile = path
+		return nil
+	})
+}
+
+// ownBook returns the book of the engine with the book options applied, loading
+// the compiled-in book on first use, or nil when it fails to load
+func (engine *Engine) ownBook() *Book {
+	if engine.book == nil {
+		book, err := InternalBook()
+		if err != nil {
+			return nil
+		}
+		engine.book = book
+	}
+	engine.book.Depth = engine.Options.BookDepth
+	engine.book.Randomness = engine.Options.BookRandomness
+	return engine.book
+}
+
+func parseSpinOption(value string, min int, max int, target *int) error {
+	n, err := strconv.Atoi(strings.TrimSpace(value))
+	if err != nil || n < min || n > max {
+		return fmt.Errorf("%w: %q, expected %d to %d", ErrInvalidOption, value, min, max)
+	}
+	*target = n
+	return nil
+}
+
+func parseCheckOption(value string, target *bool) error {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "true":
+		*target = true
+	case "false":
+		*target = false
+	default:
+		return fmt.Errorf("%w: %q, expected true or false", ErrInvalidOption, value)
+	}
+	return nil
+}
+
+// NewGame forgets everything learned from previous searches. It fails with
+// ErrSearchInProgress while a search is running, stop it and wait for its final
+// report first.
+func (engine *Engine) NewGame() error {
+	return engine.withIdleTable(func(tt *TranspositionTable) error {
+		tt.Clear()
+		engine.threads.Clear()
+		return nil
+	})
+}
+
+// TranspositionTable returns the table shared by the searches of the engine
+func (engine *Engine) TranspositionTable() *TranspositionTable {
+	return engine.tt
+}
+
+// Analyze searches a copy of board within limits, streaming a SearchInfo for every
+// completed iteration and a final report with the best move before closing the channel.
+// Cancelling ctx stops the search, the final report is still sent.
+func (engine *Engine) Analyze(ctx context.Context, board *Board, limits GoOptions) (<-chan SearchInfo, error) {
+	if board == nil {
+		return nil, ErrNilBoard
+	}
+	processMu.Lock()
+	engine.mu.Lock()
+	if engine.searching {
+		engine.mu.Unlock()
+		processMu.Unlock()
+		return nil, ErrSearchInProgress
+	}
+	engine.searching = true
+	processSearches++
+	engine.mu.Unlock()
+	processMu.Unlock()
+
+	root := board.Clone()
+	options := engine.searchOptions(root, limits)
+	// The book is for games, analysis and fixed limits always search
+	if engine.Options.OwnBook && !limits.Infinite && limits.Depth == 0 && limits.Nodes == 0 {
+		options.UseBookMoves = true
+		options.Book = engine.ownBook()
+	}
+	stopChan := make(chan struct{})
+	finished := make(chan struct{})
+	options.StopChan = stopChan
+	go func() {
+		select {
+		case <-ctx.Done():
+			close(stopChan)
+		case <-finished:
+		}
+	}()
+
+	// One report per iteration plus the final one never fill the buffer
+	infos := make(chan SearchInfo, MaxSearchDepth+2)
+	go func() {
+		defer close(infos)
+		startTime := time.Now()
+		var nodes, tbHits uint64
+		var last SearchInfo
+		options.OnIteration = func(result *SearchResult) {
+			nodes += result.NodesSearched
+			tbHits += result.TBHits
+			if result.IsInterrupted || result.BestMove == nil {
+				return
+			}
+			last = engine.newSearchInfo(root, result, nodes, startTime)
+			last.TBHits = tbHits
+			infos <- last
+		}
+		bestMove := root.IterativeDeepeningSearch(options)
+		close(finished)
+
+		final := last
+		final.Nodes = nodes
+		final.TBHits = tbHits
+		final.TimeMs = time.Since(startTime).Milliseconds()
+		final.NPS = nodesPerSecond(nodes, final.TimeMs)
+		final.HashFull = engine.tt.Hashfull()
+		final.BestMove = bestMove
+		final.Final = true
+
+		processMu.Lock()
+		engine.mu.Lock()
+		engine.searching = false
+		processSearches--
+		engine.mu.Unlock()
+		processMu.Unlock()
+		infos <- final
+	}()
+	return infos, nil
+}
+
+// searchOptions converts the engine options and go limits into search options
+func (engine *Engine) searchOptions(board *Board, limits GoOptions) SearchOptions {
+	options := engine.Options
+	skill := NewSkill(options.SkillLevel)
+	ownElo := skill.Elo()
+	if options.LimitStrength {
+		skill = NewSkillFromElo(options.Elo)
+		ownElo = options.Elo
+	}
+	contempt := options.Contempt
+	if options.DynamicContempt && options.OpponentElo > 0 {
+		contempt = DynamicContempt(contempt, ownElo, options.OpponentElo)
+	}
+	return SearchOptions{
+		TimeManager:        limits.NewTimeManager(board.WhiteToMove, options.MoveOverheadMs),
+		TranspositionTable: engine.tt,
+		Threads:            engine.threads,
+		MaxDepth:           MathMinInt(limits.Depth, MaxSearchDepth),
+		MaxNodes:           uint64(limits.Nodes),
+		Skill:              skill,
+		Contempt:           contempt,
+		Params:             &options.Search,
+	}
+}
+
+// newSearchInfo builds the report of a completed iteration
+func (engine *Engine) newSearchInfo(board *Board, result *SearchResult, nodes uint64, startTime time.Time) SearchInfo {
+	timeMs := time.Since(startTime).Milliseconds()
+	score := result.BestScore
+	if !board.WhiteToMove {
+		score = -score
+	}
+	pv := board.PrincipalVariation(*result.BestMove, engine.tt, int(result.MaxSearchDepth))
+	mate := 0
+	// Mate scores count the plies to mate
+	if score >= MateScoreThreshold {
+		mate = (MaxEvaluationScore - score + 1) / 2
+	} else if score <= -MateScoreThreshold {
+		mate = -(MaxEvaluationScore + score) / 2
+	}
+	// Tablebase scores carry the distance of the children, the root is probed instead
+	if tb := ActiveTablebase(); tb != nil {
+		if probe, ok := tb.Probe(board); ok && probe.WDL != 0 {
+			mate = probe.WDL * (probe.DTM + 1) / 2
+		}
+	}
+	return SearchInfo{
+		Depth:    int(result.MaxSearchDepth),
+		SelDepth: result.SelDepth,
+		Score:    score,
+		Mate:     mate,
+		PV:       pv,
+		Nodes:    nodes,
+		NPS:      nodesPerSecond(nodes, timeMs),
+		HashFull: engine.tt.Hashfull(),
+		TimeMs:   timeMs,
+		BestMove: result.BestMove,
+
+		NodesAtPly: result.NodesAtPly,
+	}
+}
+
+func nodesPerSecond(nodes uint64, timeMs int64) uint64 {
+	if timeMs <= 0 {
+		return 0
+	}
+	return nodes * 1000 / uint64(timeMs)
+}
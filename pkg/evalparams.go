@@ -0,0 +1,299 @@
+package libra
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+const DefaultEvalFile = "<empty>" // Default "EvalFile" UCI option, the compiled-in weights
+
+var ErrInvalidEvalParams = errors.New("invalid evaluation parameters")
+
+// EvalParams holds every weight of the evaluation. Arrays by piece are ordered pawn,
+// knight, bishop, rook, queen, king; arrays by attacker knight, bishop, rook, queen;
+// arrays by rank are indexed by relative rank (0 = own back rank). Fields tagged
+// tune:"-" are scales and indexes left alone by the tuner, and endgame scale
+// factors, which the classical evaluation it fits does not apply.
+type EvalParams struct {
+	PieceValues     [6]TaperedScore `json:"pieceValues"`
+	PSTMg           [6][64]int      `json:"pstMg"` // without material, white's view, a8 = 0
+	PSTEg           [6][64]int      `json:"pstEg"`
+	PhaseWeights    [6]int          `json:"phaseWeights" tune:"-"`
+	MoveOrderValues [6]int          `json:"moveOrderValues" tune:"-"` // MVV-LVA capture ordering values
+
+	KingProximityWeight int `json:"kingProximityWeight"`
+	KingProximityPhase  int `json:"kingProximityPhase" tune:"-"`
+
+	DoubledPawn             TaperedScore    `json:"doubledPawn"`
+	IsolatedPawn            TaperedScore    `json:"isolatedPawn"`
+	BackwardPawn            TaperedScore    `json:"backwardPawn"`
+	ConnectedPawn           [8]TaperedScore `json:"connectedPawn"`
+	PassedPawn              [8]TaperedScore `json:"passedPawn"`
+	PassedEnemyKingDistance int             `json:"passedEnemyKingDistance"`
+	PassedOwnKingDistance   int             `json:"passedOwnKingDistance"`
+	PassedFreePath          int             `json:"passedFreePath"`
+
+	PawnShield          [3]TaperedScore `json:"pawnShield"`
+	PawnStorm           [8]TaperedScore `json:"pawnStorm"`
+	BlockedStormScale   int             `json:"blockedStormScale" tune:"-"`
+	KingOpenFile        TaperedScore    `json:"kingOpenFile"`
+	KingSemiOpenFile    TaperedScore    `json:"kingSemiOpenFile"`
+	KingAttackWeight    [4]int          `json:"kingAttackWeight"`
+	KingCheckWeight     [4]int          `json:"kingCheckWeight"`
+	KingDangerDivisor   int             `json:"kingDangerDivisor" tune:"-"`
+	KingDangerMax       int             `json:"kingDangerMax"`
+	KingDangerEgDivisor int             `json:"kingDangerEgDivisor" tune:"-"`
+
+	MobilityWeight   [4]TaperedScore `json:"mobilityWeight"`
+	MobilityBaseline [4]int          `json:"mobilityBaseline" tune:"-"`
+	BishopPair       TaperedScore    `json:"bishopPair"`
+	RookOpenFile     TaperedScore    `json:"rookOpenFile"`
+	RookSemiOpenFile TaperedScore    `json:"rookSemiOpenFile"`
+	RookSeventhRank  TaperedScore    `json:"rookSeventhRank"`
+	KnightOutpost    TaperedScore    `json:"knightOutpost"`
+	TrappedMinor     TaperedScore    `json:"trappedMinor"`
+	TrappedRook      TaperedScore    `json:"trappedRook"`
+	HangingPiece     TaperedScore    `json:"hangingPiece"`
+
+	PureOppositeBishopsScale  int `json:"pureOppositeBishopsScale" tune:"-"` // out of ScaleNormal
+	PureOppositeBishopsScale2 int `json:"pureOppositeBishopsScale2" tune:"-"`
+	OppositeBishopsScale      int `json:"oppositeBishopsScale" tune:"-"`
+	PawnlessMinorUpScale      int `json:"pawnlessMinorUpScale" tune:"-"`
+	PawnlessMinorUpScale2     int `json:"pawnlessMinorUpScale2" tune:"-"`
+}
+
+// pstTables are the active middlegame and endgame tables by piece, with the material baked in
+var pstTables = [6][2]*[64]int{
+	{&mgPawnPST, &egPawnPST},
+	{&mgKnightPST, &egKnightPST},
+	{&mgBishopPST, &egBishopPST},
+	{&mgRookPST, &egRookPST},
+	{&mgQueenPST, &egQueenPST},
+	{&mgKingPST, &egKingPST},
+}
+
+// Piece codes of each color, in the order of the arrays by piece
+var (
+	whitePieceCodes = [6]byte{WhitePawn, WhiteKnight, WhiteBishop, WhiteRook, WhiteQueen, WhiteKing}
+	blackPieceCodes = [6]byte{BlackPawn, BlackKnight, BlackBishop, BlackRook, BlackQueen, BlackKing}
+)
+
+// defaultEvalParams are the compiled-in weights. Package variables are initialized
+// before init functions run, so the tables are still without material here.
+var defaultEvalParams = builtinEvalParams()
+
+// activeEvalParams are the weights in use, see ApplyEvalParams
+var activeEvalParams EvalParams
+
+func init() {
+	if err := ApplyEvalParams(DefaultEvalParams()); err != nil {
+		panic(err)
+	}
+}
+
+func builtinEvalParams() EvalParams {
+	params := EvalParams{
+		PhaseWeights:        phaseWeights,
+		KingProximityWeight: kingProximityWeight,
+		KingProximityPhase:  kingProximityPhase,
+
+		DoubledPawn:             doubledPawnPenalty,
+		IsolatedPawn:            isolatedPawnPenalty,
+		BackwardPawn:            backwardPawnPenalty,
+		ConnectedPawn:           connectedPawnBonus,
+		PassedPawn:              passedPawnBonus,
+		PassedEnemyKingDistance: passedEnemyKingDistance,
+		PassedOwnKingDistance:   passedOwnKingDistance,
+		PassedFreePath:          passedFreePath,
+
+		PawnShield:          pawnShieldBonus,
+		PawnStorm:           pawnStormPenalty,
+		BlockedStormScale:   blockedStormScale,
+		KingOpenFile:        kingOpenFile,
+		KingSemiOpenFile:    kingSemiOpenFile,
+		KingAttackWeight:    kingAttackWeight,
+		KingCheckWeight:     kingCheckWeight,
+		KingDangerDivisor:   kingDangerDivisor,
+		KingDangerMax:       kingDangerMax,
+		KingDangerEgDivisor: kingDangerEgDivisor,
+
+		MobilityWeight:   mobilityWeight,
+		MobilityBaseline: mobilityBaseline,
+		BishopPair:       bishopPairBonus,
+		RookOpenFile:     rookOpenFileBonus,
+		RookSemiOpenFile: rookSemiOpenFileBonus,
+		RookSeventhRank:  rookSeventhRankBonus,
+		KnightOutpost:    knightOutpostBonus,
+		TrappedMinor:     trappedMinorPenalty,
+		TrappedRook:      trappedRookPenalty,
+		HangingPiece:     hangingPieceThreat,
+
+		PureOppositeBishopsScale:  pureOppositeBishopsScale,
+		PureOppositeBishopsScale2: pureOppositeBishopsScale2,
+		OppositeBishopsScale:      oppositeBishopsScale,
+		PawnlessMinorUpScale:      pawnlessMinorUpScale,
+		PawnlessMinorUpScale2:     pawnlessMinorUpScale2,
+	}
+	for piece := range pstTables {
+		params.PieceValues[piece] = TaperedScore{mgPieceValue[piece], egPieceValue[piece]}
+		params.PSTMg[piece] = *pstTables[piece][0]
+		params.PSTEg[piece] = *pstTables[piece][1]
+		params.MoveOrderValues[piece] = PieceCodeToValue[whitePieceCodes[piece]]
+	}
+	return params
+}
+
+// DefaultEvalParams returns a copy of the compiled-in evaluation weights
+func DefaultEvalParams() *EvalParams {
+	params := defaultEvalParams
+	return &params
+}
+
+// CurrentEvalParams returns a copy of the evaluation weights in use
+func CurrentEvalParams() *EvalParams {
+	params := activeEvalParams
+	return &params
+}
+
+// Validate checks the weights that would make the evaluation divide by zero
+func (params *EvalParams) Validate() error {
+	for _, weight := range params.PhaseWeights {
+		if weight < 0 {
+			return fmt.Errorf("%w: negative phase weight", ErrInvalidEvalParams)
+		}
+	}
+	switch {
+	case startingPhase(params.PhaseWeights) <= 0:
+		return fmt.Errorf("%w: phase weights add up to 0", ErrInvalidEvalParams)
+	case params.BlockedStormScale <= 0:
+		return fmt.Errorf("%w: blockedStormScale must be positive", ErrInvalidEvalParams)
+	case params.KingDangerDivisor <= 0:
+		return fmt.Errorf("%w: kingDangerDivisor must be positive", ErrInvalidEvalParams)
+	case params.KingDangerEgDivisor <= 0:
+		return fmt.Errorf("%w: kingDangerEgDivisor must be positive", ErrInvalidEvalParams)
+	}
+	return nil
+}
+
+// ApplyEvalParams makes params the weights used by every board. It must not be
+// called while a search is running, and pawn hash tables filled with the previous
+// weights must be cleared.
+func ApplyEvalParams(params *EvalParams) error {
+	if err := params.Validate(); err != nil {
+		return err
+	}
+	for piece := range pstTables {
+		value := params.PieceValues[piece]
+		mgPieceValue[piece], egPieceValue[piece] = value.Mg, value.Eg
+		for sq := 0; sq < 64; sq++ {
+			pstTables[piece][0][sq] = params.PSTMg[piece][sq] + value.Mg
+			pstTables[piece][1][sq] = params.PSTEg[piece][sq] + value.Eg
+		}
+		PieceCodeToValue[whitePieceCodes[piece]] = params.MoveOrderValues[piece]
+		PieceCodeToValue[blackPieceCodes[piece]] = params.MoveOrderValues[piece]
+	}
+	phaseWeights = params.PhaseWeights
+	phaseWeightsTotal = startingPhase(phaseWeights)
+	kingProximityWeight = params.KingProximityWeight
+	kingProximityPhase = params.KingProximityPhase
+
+	doubledPawnPenalty = params.DoubledPawn
+	isolatedPawnPenalty = params.IsolatedPawn
+	backwardPawnPenalty = params.BackwardPawn
+	connectedPawnBonus = params.ConnectedPawn
+	passedPawnBonus = params.PassedPawn
+	passedEnemyKingDistance = params.PassedEnemyKingDistance
+	passedOwnKingDistance = params.PassedOwnKingDistance
+	passedFreePath = params.PassedFreePath
+
+	pawnShieldBonus = params.PawnShield
+	pawnStormPenalty = params.PawnStorm
+	blockedStormScale = params.BlockedStormScale
+	kingOpenFile = params.KingOpenFile
+	kingSemiOpenFile = params.KingSemiOpenFile
+	kingAttackWeight = params.KingAttackWeight
+	kingCheckWeight = params.KingCheckWeight
+	kingDangerDivisor = params.KingDangerDivisor
+	kingDangerMax = params.KingDangerMax
+	kingDangerEgDivisor = params.KingDangerEgDivisor
+
+	mobilityWeight = params.MobilityWeight
+	mobilityBaseline = params.MobilityBaseline
+	bishopPairBonus = params.BishopPair
+	rookOpenFileBonus = params.RookOpenFile
+	rookSemiOpenFileBonus = params.RookSemiOpenFile
+	rookSeventhRankBonus = params.RookSeventhRank
+	knightOutpostBonus = params.KnightOutpost
+	trappedMinorPenalty = params.TrappedMinor
+	trappedRookPenalty = params.TrappedRook
+	hangingPieceThreat = params.HangingPiece
+
+	pureOppositeBishopsScale = params.PureOppositeBishopsScale
+	pureOppositeBishopsScale2 = params.PureOppositeBishopsScale2
+	oppositeBishopsScale = params.OppositeBishopsScale
+	pawnlessMinorUpScale = params.PawnlessMinorUpScale
+	pawnlessMinorUpScale2 = params.PawnlessMinorUpScale2
+
+	activeEvalParams = *params
+	return nil
+}
+
+// startingPhase returns the phase of the starting material with weights
+func startingPhase(weights [6]int) int {
+	return 16*weights[0] + 4*weights[1] + 4*weights[2] + 4*weights[3] + 2*weights[4]
+}
+
+// Save writes the parameters to w as indented JSON
+func (params *EvalParams) Save(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(params)
+}
+
+// SaveFile writes the parameters to the file at path
+func (params *EvalParams) SaveFile(path string) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := params.Save(file); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// LoadEvalParams reads JSON parameters from r. Parameters missing from the JSON
+// keep their default value, so a file may list only the weights it changes.
+func LoadEvalParams(r io.Reader) (*EvalParams, error) {
+	params := DefaultEvalParams()
+	decoder := json.NewDecoder(r)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(params); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidEvalParams, err)
+	}
+	if err := params.Validate(); err != nil {
+		return nil, err
+	}
+	return params, nil
+}
+
+// LoadEvalParamsFile reads the parameters saved in the file at path
+func LoadEvalParamsFile(path string) (*EvalParams, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return LoadEvalParams(file)
+}
+
+// isDefaultEvalFile reports whether an "EvalFile" value selects the compiled-in weights
+func isDefaultEvalFile(path string) bool {
+	path = strings.TrimSpace(path)
+	return path == "" || path == DefaultEvalFile
+}
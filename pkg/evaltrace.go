@@ -0,0 +1,120 @@
+package libra
+
+import (
+	"fmt"
+	"math/bits"
+	"strings"
+)
+
+// EvalTerm is the middlegame and endgame score of one evaluation term for each side
+type EvalTerm struct {
+	Name  string
+	White TaperedScore
+	Black TaperedScore
+}
+
+// Total returns the white minus black score of the term
+func (term EvalTerm) Total() TaperedScore {
+	return term.White.Sub(term.Black)
+}
+
+// EvalTrace is the breakdown of the static evaluation by term
+type EvalTrace struct {
+	Terms     []EvalTerm
+	Phase     int    // TotalPhase is full middlegame, 0 full endgame
+	Score     int    // Evaluate(), from white's perspective
+	Classical int    // EvaluateClassical(), the sum of the terms
+	Network   bool   // Score comes from the network set with SetNetwork
+	Endgame   string // Specialized evaluator or scale factor applied to Score, if any
+}
+
+// EvaluateTrace returns the evaluation of every term for each side. Terms are
+// tapered one by one, so their sum may differ from Score by rounding.
+func (board *Board) EvaluateTrace() EvalTrace {
+	phase := board.Phase()
+	material, pst := board.materialAndPST()
+	egWhite := material[ColorWhite].Eg + pst[ColorWhite].Eg
+	egBlack := material[ColorBlack].Eg + pst[ColorBlack].Eg
+	proximity := board.kingProximityScore(egWhite, egBlack, phase)
+	endgame := EvalTerm{Name: "Endgame"}
+	if proximity > 0 {
+		endgame.White = TaperedScore{proximity, proximity}
+	} else {
+		endgame.Black = TaperedScore{-proximity, -proximity}
+	}
+	pawns := board.EvaluatePawns()
+	pieces := board.EvaluatePieces()
+	kingSafety := board.EvaluateKingSafety()
+
+	score, endgameRule := board.evaluateEndgame()
+	return EvalTrace{
+		Terms: []EvalTerm{
+			{"Material", material[ColorWhite], material[ColorBlack]},
+			{"PST", pst[ColorWhite], pst[ColorBlack]},
+			{"Pawns", pawns[ColorWhite], pawns[ColorBlack]},
+			{"Mobility", pieces[ColorWhite], pieces[ColorBlack]},
+			{"King safety", kingSafety[ColorWhite], kingSafety[ColorBlack]},
+			endgame,
+		},
+		Phase:     phase,
+		Score:     score,
+		Classical: board.EvaluateClassical(),
+		Network:   activeNetwork != nil,
+		Endgame:   endgameRule,
+	}
+}
+
+// materialAndPST splits the PeSTO tables, which have the material baked in,
+// into the material and the piece-square score of each color
+func (board *Board) materialAndPST() ([2]TaperedScore, [2]TaperedScore) {
+	pieces := [2][6]uint64{
+		{board.WhitePawns, board.WhiteKnights, board.WhiteBishops, board.WhiteRooks, board.WhiteQueens, board.WhiteKing},
+		{board.BlackPawns, board.BlackKnights, board.BlackBishops, board.BlackRooks, board.BlackQueens, board.BlackKing},
+	}
+	material, pst := [2]TaperedScore{}, [2]TaperedScore{}
+	for color := ColorWhite; color <= ColorBlack; color++ {
+		for piece, bb := range pieces[color] {
+			value := TaperedScore{mgPieceValue[piece], egPieceValue[piece]}
+			for ; bb != 0; bb &= bb - 1 {
+				sq := bits.TrailingZeros64(bb)
+				if color == ColorBlack {
+					sq = int(mirrorIndex(byte(sq)))
+				}
+				material[color] = material[color].Add(value)
+				pst[color] = pst[color].Add(TaperedScore{pstTables[piece][0][sq], pstTables[piece][1][sq]}.Sub(value))
+			}
+		}
+	}
+	return material, pst
+}
+
+// String formats the trace as a table in centipawns, from white's perspective
+func (trace EvalTrace) String() string {
+	var sb strings.Builder
+	sb.WriteString("        Term |     White     |     Black     |     Total     | Tapered\n")
+	sb.WriteString("             |    MG     EG  |    MG     EG  |    MG     EG  |\n")
+	sb.WriteString("-------------+---------------+---------------+---------------+--------\n")
+	for _, term := range trace.Terms {
+		total := term.Total()
+		fmt.Fprintf(&sb, "%12s | %6d %6d | %6d %6d | %6d %6d | %6d\n",
+			term.Name,
+			term.White.Mg, term.White.Eg,
+			term.Black.Mg, term.Black.Eg,
+			total.Mg, total.Eg,
+			total.Taper(trace.Phase),
+		)
+	}
+	sb.WriteString("-------------+---------------+---------------+---------------+--------\n")
+	fmt.Fprintf(&sb, "Phase: %d/%d\n", trace.Phase, TotalPhase)
+	if trace.Network || trace.Endgame != "" {
+		fmt.Fprintf(&sb, "Classical evaluation: %d cp (white side)\n", trace.Classical)
+	}
+	if trace.Network {
+		sb.WriteString("Evaluated by the network\n")
+	}
+	if trace.Endgame != "" {
+		fmt.Fprintf(&sb, "Endgame: %s\n", trace.Endgame)
+	}
+	fmt.Fprintf(&sb, "Final evaluation: %d cp (white side)\n", trace.Score)
+	return sb.String()
+}
@@ -4,6 +4,32 @@ import (
 	"math/bits"
 )
 
+// TaperedScore is a middlegame and endgame score pair, interpolated by the game phase
+type TaperedScore struct {
+	Mg int `json:"mg"`
+	Eg int `json:"eg"`
+}
+
+// Add returns the sum of two scores
+func (score TaperedScore) Add(other TaperedScore) TaperedScore {
+	return TaperedScore{score.Mg + other.Mg, score.Eg + other.Eg}
+}
+
+// Sub returns the difference of two scores
+func (score TaperedScore) Sub(other TaperedScore) TaperedScore {
+	return TaperedScore{score.Mg - other.Mg, score.Eg - other.Eg}
+}
+
+// Mul returns the score multiplied by n
+func (score TaperedScore) Mul(n int) TaperedScore {
+	return TaperedScore{score.Mg * n, score.Eg * n}
+}
+
+// Taper interpolates the score, phase=TotalPhase is full middlegame and phase=0 full endgame
+func (score TaperedScore) Taper(phase int) int {
+	return (score.Mg*phase + score.Eg*(TotalPhase-phase)) / TotalPhase
+}
+
 // mirrorIndex mirrors a square index for black's perspective
 func mirrorIndex(idx byte) byte {
 	return 56 ^ (idx & 56) | (idx & 7)
@@ -16,14 +42,35 @@ func abs(x int) int {
 	return x
 }
 
+// Phase returns the game phase from TotalPhase (all pieces on the board) down to 0 (pawn endgame).
+func (board *Board) Phase() int {
+	phase := bits.OnesCount64(board.WhitePawns|board.BlackPawns)*phaseWeights[0] +
+		bits.OnesCount64(board.WhiteKnights|board.BlackKnights)*phaseWeights[1] +
+		bits.OnesCount64(board.WhiteBishops|board.BlackBishops)*phaseWeights[2] +
+		bits.OnesCount64(board.WhiteRooks|board.BlackRooks)*phaseWeights[3] +
+		bits.OnesCount64(board.WhiteQueens|board.BlackQueens)*phaseWeights[4]
+	// Scale weights that do not add up to TotalPhase for the starting material
+	if phaseWeightsTotal != TotalPhase {
+		phase = phase * TotalPhase / phaseWeightsTotal
+	}
+	if phase > TotalPhase {
+		phase = TotalPhase
+	}
+	return phase
+}
+
 // EvaluateMaterialAndPST evaluates using tapered PeSTO piece-square tables.
 // Returns separate white and black scores after phase interpolation.
 func (board *Board) EvaluateMaterialAndPST() (int, int) {
+	return board.evaluateMaterialAndPST(board.Phase())
+}
+
+// evaluateMaterialAndPST is EvaluateMaterialAndPST at the given game phase
+func (board *Board) evaluateMaterialAndPST(phase int) (int, int) {
 	mgWhite, mgBlack := 0, 0
 	egWhite, egBlack := 0, 0
-	phase := 0
 
-	// Pawns (phase weight = 0, so no phase contribution)
+	// Pawns
 	for bb := board.WhitePawns; bb != 0; {
 		sq := bits.TrailingZeros64(bb)
 		mgWhite += mgPawnPST[sq]
@@ -42,14 +89,12 @@ func (board *Board) EvaluateMaterialAndPST() (int, int) {
 		sq := bits.TrailingZeros64(bb)
 		mgWhite += mgKnightPST[sq]
 		egWhite += egKnightPST[sq]
-		phase += KnightPhase
 		bb &= bb - 1
 	}
 	for bb := board.BlackKnights; bb != 0; {
 		sq := bits.TrailingZeros64(bb)
 		mgBlack += mgKnightPST[mirrorIndex(byte(sq))]
 		egBlack += egKnightPST[mirrorIndex(byte(sq))]
-		phase += KnightPhase
 		bb &= bb - 1
 	}
 
@@ -58,14 +103,12 @@ func (board *Board) EvaluateMaterialAndPST() (int, int) {
 		sq := bits.TrailingZeros64(bb)
 		mgWhite += mgBishopPST[sq]
 		egWhite += egBishopPST[sq]
-		phase += BishopPhase
 		bb &= bb - 1
 	}
 	for bb := board.BlackBishops; bb != 0; {
 		sq := bits.TrailingZeros64(bb)
 		mgBlack += mgBishopPST[mirrorIndex(byte(sq))]
 		egBlack += egBishopPST[mirrorIndex(byte(sq))]
-		phase += BishopPhase
 		bb &= bb - 1
 	}
 
@@ -74,14 +117,12 @@ func (board *Board) EvaluateMaterialAndPST() (int, int) {
 		sq := bits.TrailingZeros64(bb)
 		mgWhite += mgRookPST[sq]
 		egWhite += egRookPST[sq]
-		phase += RookPhase
 		bb &= bb - 1
 	}
 	for bb := board.BlackRooks; bb != 0; {
 		sq := bits.TrailingZeros64(bb)
 		mgBlack += mgRookPST[mirrorIndex(byte(sq))]
 		egBlack += egRookPST[mirrorIndex(byte(sq))]
-		phase += RookPhase
 		bb &= bb - 1
 	}
 
@@ -90,18 +131,16 @@ func (board *Board) EvaluateMaterialAndPST() (int, int) {
 		sq := bits.TrailingZeros64(bb)
 		mgWhite += mgQueenPST[sq]
 		egWhite += egQueenPST[sq]
-		phase += QueenPhase
 		bb &= bb - 1
 	}
 	for bb := board.BlackQueens; bb != 0; {
 		sq := bits.TrailingZeros64(bb)
 		mgBlack += mgQueenPST[mirrorIndex(byte(sq))]
 		egBlack += egQueenPST[mirrorIndex(byte(sq))]
-		phase += QueenPhase
 		bb &= bb - 1
 	}
 
-	// Kings (no phase contribution)
+	// Kings
 	if board.WhiteKing != 0 {
 		sq := bits.TrailingZeros64(board.WhiteKing)
 		mgWhite += mgKingPST[sq]
@@ -113,51 +152,91 @@ func (board *Board) EvaluateMaterialAndPST() (int, int) {
 		egBlack += egKingPST[mirrorIndex(byte(sq))]
 	}
 
-	// Clamp phase to TotalPhase
-	if phase > TotalPhase {
-		phase = TotalPhase
-	}
-
 	// Tapered interpolation: phase=TotalPhase means full middlegame, phase=0 means full endgame
 	mgScore := mgWhite - mgBlack
 	egScore := egWhite - egBlack
 	whiteScore := (mgScore*phase + egScore*(TotalPhase-phase)) / TotalPhase
-
-	// Endgame king proximity heuristic
-	if phase <= 6 && board.WhiteKing != 0 && board.BlackKing != 0 {
-		wKing := byte(bits.TrailingZeros64(board.WhiteKing))
-		bKing := byte(bits.TrailingZeros64(board.BlackKing))
-		wRank := int(wKing / 8)
-		wFile := int(wKing % 8)
-		bRank := int(bKing / 8)
-		bFile := int(bKing % 8)
-		dist := abs(wRank-bRank) + abs(wFile-bFile)
-		if egWhite > egBlack {
-			whiteScore += (14 - dist) * 10
-		} else if egBlack > egWhite {
-			whiteScore -= (14 - dist) * 10
-		}
-	}
+	whiteScore += board.kingProximityScore(egWhite, egBlack, phase)
 
 	return whiteScore, 0
 }
 
-// Mobility: count the number of legal moves for each side
+// King proximity heuristic weights
+var (
+	kingProximityWeight = 10 // per square closer than the farthest distance (14)
+	kingProximityPhase  = 6  // largest game phase where the heuristic applies
+)
+
+// kingProximityScore is the endgame king proximity heuristic: the side ahead in
+// endgame material and PST is rewarded for bringing its king close to the enemy king
+func (board *Board) kingProximityScore(egWhite, egBlack, phase int) int {
+	if phase > kingProximityPhase || board.WhiteKing == 0 || board.BlackKing == 0 {
+		return 0
+	}
+	wKing := byte(bits.TrailingZeros64(board.WhiteKing))
+	bKing := byte(bits.TrailingZeros64(board.BlackKing))
+	wRank := int(wKing / 8)
+	wFile := int(wKing % 8)
+	bRank := int(bKing / 8)
+	bFile := int(bKing % 8)
+	dist := abs(wRank-bRank) + abs(wFile-bFile)
+	if egWhite > egBlack {
+		return (14 - dist) * kingProximityWeight
+	} else if egBlack > egWhite {
+		return -(14 - dist) * kingProximityWeight
+	}
+	return 0
+}
+
+// MateOrStalemateScore returns the score of a position without legal moves, scoring stalemate as 0
 func (board *Board) MateOrStalemateScore(maximizing bool) int {
-	kingSq := board.ActiveKingSquare()
-	if board.IsSquareAttacked(kingSq, board.WhiteToMove) {
+	return board.MateOrDrawScore(maximizing, 0)
+}
+
+// MateOrDrawScore returns the score of a position without legal moves,
+// scoring stalemate as drawScore (from white's perspective, see DrawScore)
+func (board *Board) MateOrDrawScore(maximizing bool, drawScore int) int {
+	if board.IsInCheck() {
 		if maximizing {
 			return -MaxEvaluationScore
 		} else {
 			return MaxEvaluationScore
 		}
 	} else {
-		return 0
+		return drawScore
 	}
 }
 
+// IsInCheck returns true if the side to move is in check
+func (board *Board) IsInCheck() bool {
+	return board.IsSquareAttacked(board.ActiveKingSquare(), board.WhiteToMove)
+}
+
+// Evaluate returns the static evaluation of the position from white's perspective,
+// by the network set with SetNetwork or else by EvaluateClassical, with the endgame
+// knowledge of evaluateEndgame applied
 func (board *Board) Evaluate() int {
-	whiteScore, blackScore := board.EvaluateMaterialAndPST()
+	score, _ := board.evaluateEndgame()
+	return score
+}
+
+// evaluateGeneral returns the evaluation without endgame knowledge
+func (board *Board) evaluateGeneral() int {
+	if network := activeNetwork; network != nil {
+		return board.EvaluateNetwork(network)
+	}
+	return board.EvaluateClassical()
+}
 
-	return whiteScore - blackScore
+// EvaluateClassical returns the hand-crafted evaluation from white's perspective
+func (board *Board) EvaluateClassical() int {
+	phase := board.Phase()
+	whiteScore, blackScore := board.evaluateMaterialAndPST(phase)
+	pawns := board.EvaluatePawns()
+	kingSafety := board.EvaluateKingSafety()
+	pieces := board.EvaluatePieces()
+	positional := pawns[ColorWhite].Add(kingSafety[ColorWhite]).Add(pieces[ColorWhite]).
+		Sub(pawns[ColorBlack]).Sub(kingSafety[ColorBlack]).Sub(pieces[ColorBlack])
+
+	return whiteScore - blackScore + positional.Taper(phase)
 }
@@ -507,3 +507,100 @@ func (board *Board) IsSquareAttackedBySlidingPieces(square byte, whiteToMove boo
 	}
 	return false
 }
+
+// IsMovePseudoLegal returns true if the move can be played in the position, ignoring
+// whether it leaves the king in check. Used to validate moves read from the
+// transposition table, which may belong to another position after a key collision.
+// Only castling generates moves, everything else is checked on the bitboards.
+func (board *Board) IsMovePseudoLegal(move Move) bool {
+	if move.From > 63 || move.To > 63 || move.Piece == 0 || board.PieceAtSquare(move.From) != move.Piece {
+		return false
+	}
+	white := board.WhiteToMove
+	own, color, pawn, enemyPawn := board.WhitePieces(), ColorWhite, byte(WhitePawn), byte(BlackPawn)
+	if !white {
+		own, color, pawn, enemyPawn = board.BlackPieces(), ColorBlack, BlackPawn, WhitePawn
+	}
+	from, to := uint64(1)<<move.From, uint64(1)<<move.To
+	if own&from == 0 || own&to != 0 {
+		return false
+	}
+	if move.MoveType == MoveCastle {
+		for _, castle := range board.GenerateCastleMoves(white) {
+			if castle == move {
+				return true
+			}
+		}
+		return false
+	}
+
+	// The captured piece and the promotion must match the position
+	switch move.MoveType {
+	case MoveQuiet, MovePromotion:
+		if move.Captured != 0 || board.IsSquareOccupied(move.To) {
+			return false
+		}
+	case MoveCapture, MovePromotionCapture:
+		if move.Captured == 0 || board.PieceAtSquare(move.To) != move.Captured {
+			return false
+		}
+	case MoveEnPassant:
+		if move.Piece != pawn || move.Captured != enemyPawn || move.To == 0 || !board.IsSquareOnPassant(move.To) {
+			return false
+		}
+	default:
+		return false
+	}
+	promotionRank := byte(0)
+	if !white {
+		promotionRank = 7
+	}
+	promotes := move.MoveType == MovePromotion || move.MoveType == MovePromotionCapture
+	if promotes && move.Piece != pawn || move.Piece == pawn && promotes != (move.To/8 == promotionRank) {
+		return false
+	}
+	if promotes && !isPromotionPiece(move.Promoted, white) || !promotes && move.Promoted != 0 {
+		return false
+	}
+
+	occupied := board.OccupiedSquares()
+	var targets uint64
+	switch move.Piece {
+	case WhitePawn, BlackPawn:
+		if move.IsCapture() {
+			targets = PawnAttacks[color][move.From]
+			break
+		}
+		dir, startRank := -8, byte(6)
+		if !white {
+			dir, startRank = 8, 1
+		}
+		push := int(move.From) + dir
+		if occupied&(1<<push) != 0 {
+			return false
+		}
+		targets = 1 << push
+		if move.From/8 == startRank {
+			targets |= 1 << (push + dir)
+		}
+	case WhiteKnight, BlackKnight:
+		targets = KnightAttacks[move.From]
+	case WhiteBishop, BlackBishop:
+		targets = BishopAttacks(int(move.From), occupied)
+	case WhiteRook, BlackRook:
+		targets = RookAttacks(int(move.From), occupied)
+	case WhiteQueen, BlackQueen:
+		targets = BishopAttacks(int(move.From), occupied) | RookAttacks(int(move.From), occupied)
+	case WhiteKing, BlackKing:
+		targets = KingAttacks[move.From]
+	}
+	return targets&to != 0
+}
+
+// isPromotionPiece returns true if piece is a queen, rook, bishop or knight of the side
+func isPromotionPiece(piece byte, white bool) bool {
+	if white {
+		return piece == WhiteQueen || piece == WhiteRook || piece == WhiteBishop || piece == WhiteKnight
+	}
+	return piece == BlackQueen || piece == BlackRook || piece == BlackBishop || piece == BlackKnight
+}
@@ -0,0 +1,139 @@
+package libra
+
+import "math/bits"
+
+// Pawn shield and storm weights, by the files next to and on the king file
+var (
+	pawnShieldBonus   = [3]TaperedScore{{18, 0}, {9, 0}, {0, 0}}                       // nearest own pawn 1, 2 or more rows ahead
+	pawnStormPenalty  = [8]TaperedScore{{0, 0}, {0, 0}, {-30, -4}, {-16, -2}, {-6, 0}} // nearest enemy pawn by relative rank
+	blockedStormScale = 2                                                              // storm penalty divisor when the pawn is blocked by an own pawn
+	kingOpenFile      = TaperedScore{-24, -4}                                          // no pawns on the file
+	kingSemiOpenFile  = TaperedScore{-12, -2}                                          // no own pawns on the file
+)
+
+// King attack weights, indexed by attacker: knight, bishop, rook, queen
+var (
+	kingAttackWeight = [4]int{2, 2, 3, 5} // per attacked square of the king zone
+	kingCheckWeight  = [4]int{3, 2, 4, 5} // when the piece can give a safe check
+)
+
+var (
+	kingDangerDivisor   = 4   // danger is the square of the attack units divided by this
+	kingDangerMax       = 500 // cap of the middlegame king danger
+	kingDangerEgDivisor = 4   // endgame danger is the middlegame danger divided by this
+)
+
+// EvaluateKingSafety returns the king safety score of each color, from the pawns
+// around its king and the enemy pieces attacking it
+func (board *Board) EvaluateKingSafety() [2]TaperedScore {
+	scores := [2]TaperedScore{}
+	if board.WhiteKing == 0 || board.BlackKing == 0 {
+		return scores
+	}
+	for color := ColorWhite; color <= ColorBlack; color++ {
+		scores[color] = board.evaluateKingShelter(color).Add(board.evaluateKingAttacks(color))
+	}
+	return scores
+}
+
+// evaluateKingShelter scores the pawn shield, the pawn storm and the open files
+// on the king file and the files next to it
+func (board *Board) evaluateKingShelter(color int) TaperedScore {
+	own, enemy := board.WhitePawns, board.BlackPawns
+	if color == ColorBlack {
+		own, enemy = enemy, own
+	}
+	king := board.kingSquare(color)
+	row, kingFile := king/8, king%8
+
+	score := TaperedScore{}
+	for file := MathMaxInt(0, kingFile-1); file <= MathMinInt(7, kingFile+1); file++ {
+		ahead := ForwardFileMasks[color][row*8+file]
+		ownAhead, enemyAhead := ahead&own, ahead&enemy
+
+		if ownAhead == 0 {
+			if FileMasks[file]&enemy == 0 {
+				score = score.Add(kingOpenFile)
+			} else {
+				score = score.Add(kingSemiOpenFile)
+			}
+		}
+
+		shield := len(pawnShieldBonus) - 1
+		shieldPawn := -1
+		if ownAhead != 0 {
+			shieldPawn = nearestPawn(color, ownAhead)
+			shield = MathMinInt(abs(shieldPawn/8-row)-1, shield)
+		}
+		score = score.Add(pawnShieldBonus[shield])
+
+		if enemyAhead != 0 {
+			stormPawn := nearestPawn(color, enemyAhead)
+			storm := pawnStormPenalty[relativeRank(color, stormPawn/8)]
+			// A storming pawn stopped by the shield pawn cannot open the file
+			if shieldPawn >= 0 && abs(stormPawn-shieldPawn) == 8 {
+				storm = TaperedScore{storm.Mg / blockedStormScale, storm.Eg / blockedStormScale}
+			}
+			score = score.Add(storm)
+		}
+	}
+	return score
+}
+
+// evaluateKingAttacks counts the attack units of the enemy pieces attacking the zone
+// around the king of color, and turns them into a danger penalty growing with their square
+func (board *Board) evaluateKingAttacks(color int) TaperedScore {
+	king := board.kingSquare(color)
+	attackers := board.pieceBitboards(1 - color)
+	ownPawns, enemyPieces := board.WhitePawns, board.BlackPieces()
+	if color == ColorBlack {
+		ownPawns, enemyPieces = board.BlackPawns, board.WhitePieces()
+	}
+
+	// The squares around the king and the row in front of them
+	zone := KingAttacks[king] | 1<<king
+	if color == ColorWhite {
+		zone |= zone >> 8
+	} else {
+		zone |= zone << 8
+	}
+	ownPawnAttacks := pawnAttackSquares(color, ownPawns)
+
+	occupied := board.OccupiedSquares()
+	rookChecks, bishopChecks := RookAttacks(king, occupied), BishopAttacks(king, occupied)
+	checkSquares := [4]uint64{KnightAttacks[king], bishopChecks, rookChecks, rookChecks | bishopChecks}
+	// Check squares not occupied by the attacker and not defended by a pawn
+	safe := ^enemyPieces &^ ownPawnAttacks
+
+	attackerCount, units := 0, 0
+	for piece, bb := range attackers {
+		for ; bb != 0; bb &= bb - 1 {
+			sq := bits.TrailingZeros64(bb)
+			attacks := pieceAttacks(piece, sq, occupied)
+			if attacks&zone != 0 {
+				attackerCount++
+				units += kingAttackWeight[piece] * bits.OnesCount64(attacks&zone)
+			}
+			if attacks&checkSquares[piece]&safe != 0 {
+				units += kingCheckWeight[piece]
+			}
+		}
+	}
+	// A single piece rarely mates on its own
+	if attackerCount < 2 {
+		return TaperedScore{}
+	}
+	danger := MathMinInt(units*units/kingDangerDivisor, kingDangerMax)
+	if attackers[activityQueen] == 0 {
+		danger /= 2
+	}
+	return TaperedScore{-danger, -danger / kingDangerEgDivisor}
+}
+
+// nearestPawn returns the square of the pawn in pawns closest to color's back rank
+func nearestPawn(color int, pawns uint64) int {
+	if color == ColorWhite {
+		return 63 - bits.LeadingZeros64(pawns)
+	}
+	return bits.TrailingZeros64(pawns)
+}
@@ -0,0 +1,171 @@
+package libra
+
+import "sync"
+
+// The KPK bitbase tells for every king and pawn versus king position whether the side
+// with the pawn wins. It is generated on first use by iterating over all positions until
+// none changes, with the strong side as white, its pawn on files a to d and squares
+// numbered a1 = 0 (rank * 8 + file).
+const (
+	kpkPawnSquares = 24 // Files a-d, ranks 2-7
+	kpkSize        = 2 * kpkPawnSquares * 64 * 64
+)
+
+// Position results while generating, a move leading to an invalid position is ignored
+const (
+	kpkInvalid byte = 0
+	kpkUnknown byte = 1
+	kpkDraw    byte = 2
+	kpkWin     byte = 4
+)
+
+var (
+	kpkBitbase [kpkSize / 8]byte
+	kpkOnce    sync.Once
+)
+
+// kpkIndex returns the index of a position, stm 0 for the strong side to move
+func kpkIndex(stm int, strongKing int, weakKing int, pawn int) int {
+	pawnIndex := (pawn/8-1)*4 + pawn%8
+	return ((stm*kpkPawnSquares+pawnIndex)*64+strongKing)*64 + weakKing
+}
+
+func kpkDistance(a int, b int) int {
+	return MathMaxInt(abs(a/8-b/8), abs(a%8-b%8))
+}
+
+// kpkKingMoves returns the squares next to sq
+func kpkKingMoves(sq int) []int {
+	moves := make([]int, 0, 8)
+	for dr := -1; dr <= 1; dr++ {
+		for df := -1; df <= 1; df++ {
+			r, f := sq/8+dr, sq%8+df
+			if (dr != 0 || df != 0) && r >= 0 && r < 8 && f >= 0 && f < 8 {
+				moves = append(moves, r*8+f)
+			}
+		}
+	}
+	return moves
+}
+
+// kpkPawnAttacks reports whether the pawn attacks sq
+func kpkPawnAttacks(pawn int, sq int) bool {
+	return sq/8 == pawn/8+1 && abs(sq%8-pawn%8) == 1
+}
+
+// kpkInitial classifies the positions decided without looking ahead
+func kpkInitial(stm int, strongKing int, weakKing int, pawn int) byte {
+	promotion := pawn + 8
+	switch {
+	case strongKing == weakKing || strongKing == pawn || weakKing == pawn || kpkDistance(strongKing, weakKing) <= 1:
+		return kpkInvalid
+	case stm == 0 && kpkPawnAttacks(pawn, weakKing):
+		return kpkInvalid
+	case stm == 0 && pawn/8 == 6 && strongKing != promotion && weakKing != promotion &&
+		(kpkDistance(weakKing, promotion) > 1 || kpkDistance(strongKing, promotion) == 1):
+		// The pawn promotes and the queen is safe
+		return kpkWin
+	case stm == 1 && kpkDistance(weakKing, pawn) == 1 && kpkDistance(strongKing, pawn) > 1:
+		// The pawn is lost
+		return kpkDraw
+	case stm == 1:
+		for _, sq := range kpkKingMoves(weakKing) {
+			if kpkDistance(sq, strongKing) > 1 && !kpkPawnAttacks(pawn, sq) {
+				return kpkUnknown
+			}
+		}
+		// Stalemate
+		return kpkDraw
+	}
+	return kpkUnknown
+}
+
+// kpkClassify looks one move ahead: the side to move gets the best result of its moves
+func kpkClassify(db []byte, stm int, strongKing int, weakKing int, pawn int) byte {
+	good, bad := kpkWin, kpkDraw
+	if stm == 1 {
+		good, bad = kpkDraw, kpkWin
+	}
+	r := kpkInvalid
+	if stm == 0 {
+		for _, sq := range kpkKingMoves(strongKing) {
+			r |= db[kpkIndex(1, sq, weakKing, pawn)]
+		}
+		if pawn/8 < 6 {
+			r |= db[kpkIndex(1, strongKing, weakKing, pawn+8)]
+		}
+		if pawn/8 == 1 && pawn+8 != strongKing && pawn+8 != weakKing {
+			r |= db[kpkIndex(1, strongKing, weakKing, pawn+16)]
+		}
+	} else {
+		for _, sq := range kpkKingMoves(weakKing) {
+			r |= db[kpkIndex(0, strongKing, sq, pawn)]
+		}
+	}
+	switch {
+	case r&good != 0:
+		return good
+	case r&kpkUnknown != 0:
+		return kpkUnknown
+	}
+	return bad
+}
+
+func generateKPK() {
+	db := make([]byte, kpkSize)
+	forEachKPK := func(visit func(index int, stm int, strongKing int, weakKing int, pawn int)) {
+		for stm := 0; stm < 2; stm++ {
+			for pawn := 8; pawn < 56; pawn++ {
+				if pawn%8 > 3 {
+					continue
+				}
+				for strongKing := 0; strongKing < 64; strongKing++ {
+					for weakKing := 0; weakKing < 64; weakKing++ {
+						visit(kpkIndex(stm, strongKing, weakKing, pawn), stm, strongKing, weakKing, pawn)
+					}
+				}
+			}
+		}
+	}
+	forEachKPK(func(index int, stm int, strongKing int, weakKing int, pawn int) {
+		db[index] = kpkInitial(stm, strongKing, weakKing, pawn)
+	})
+	for changed := true; changed; {
+		changed = false
+		forEachKPK(func(index int, stm int, strongKing int, weakKing int, pawn int) {
+			if db[index] == kpkUnknown {
+				if db[index] = kpkClassify(db, stm, strongKing, weakKing, pawn); db[index] != kpkUnknown {
+					changed = true
+				}
+			}
+		})
+	}
+	// Positions still unknown are cycles the strong side cannot break: draws
+	for index, result := range db {
+		if result == kpkWin {
+			kpkBitbase[index/8] |= 1 << (index % 8)
+		}
+	}
+}
+
+// ProbeKPK reports whether the side with the pawn wins a king and pawn versus king
+// position. Squares are board squares (a8 = 0), strongToMove tells who moves.
+func ProbeKPK(strong int, strongKing byte, pawn byte, weakKing byte, strongToMove bool) bool {
+	kpkOnce.Do(generateKPK)
+	toKPK := func(sq byte) int {
+		rank, file := 7-int(sq/8), int(sq%8)
+		if strong == ColorBlack {
+			rank = 7 - rank
+		}
+		if pawn%8 > 3 {
+			file = 7 - file
+		}
+		return rank*8 + file
+	}
+	stm := 1
+	if strongToMove {
+		stm = 0
+	}
+	index := kpkIndex(stm, toKPK(strongKing), toKPK(weakKing), toKPK(pawn))
+	return kpkBitbase[index/8]&(1<<(index%8)) != 0
+}
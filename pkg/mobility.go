@@ -0,0 +1,159 @@
+package libra
+
+import "math/bits"
+
+// Piece indexes of the activity and king attack weights
+const (
+	activityKnight = iota
+	activityBishop
+	activityRook
+	activityQueen
+)
+
+// Mobility is scored per safe square above a baseline, indexed by knight, bishop, rook, queen
+var (
+	mobilityWeight   = [4]TaperedScore{{4, 4}, {5, 5}, {2, 4}, {1, 2}}
+	mobilityBaseline = [4]int{4, 6, 7, 13}
+)
+
+// Piece activity weights
+var (
+	bishopPairBonus       = TaperedScore{30, 50}
+	rookOpenFileBonus     = TaperedScore{40, 10}   // no pawns on the file
+	rookSemiOpenFileBonus = TaperedScore{18, 6}    // no own pawns on the file
+	rookSeventhRankBonus  = TaperedScore{10, 30}   // when the enemy king or enemy pawns are on their home ranks
+	knightOutpostBonus    = TaperedScore{25, 15}   // defended by a pawn and out of reach of enemy pawns
+	trappedMinorPenalty   = TaperedScore{-30, -30} // knight or bishop without a safe square
+	trappedRookPenalty    = TaperedScore{-40, -10} // rook shut in by its own uncastled king
+	hangingPieceThreat    = TaperedScore{35, 20}   // per enemy piece attacked and not defended
+)
+
+// EvaluatePieces returns the mobility, piece activity and threats score of each color
+func (board *Board) EvaluatePieces() [2]TaperedScore {
+	scores := [2]TaperedScore{}
+	if board.WhiteKing == 0 || board.BlackKing == 0 {
+		return scores
+	}
+	pawnAttacks := [2]uint64{
+		pawnAttackSquares(ColorWhite, board.WhitePawns),
+		pawnAttackSquares(ColorBlack, board.BlackPawns),
+	}
+	attacked := [2]uint64{}
+	for color := ColorWhite; color <= ColorBlack; color++ {
+		scores[color], attacked[color] = board.evaluatePieceActivity(color, pawnAttacks[1-color])
+		attacked[color] |= pawnAttacks[color] | KingAttacks[board.kingSquare(color)]
+	}
+	colorPieces := [2]uint64{board.WhitePieces(), board.BlackPieces()}
+	kings := board.WhiteKing | board.BlackKing
+	for color := ColorWhite; color <= ColorBlack; color++ {
+		hanging := colorPieces[1-color] &^ kings & attacked[color] &^ attacked[1-color]
+		scores[color] = scores[color].Add(hangingPieceThreat.Mul(bits.OnesCount64(hanging)))
+	}
+	return scores
+}
+
+// evaluatePieceActivity scores the knights, bishops, rooks and queens of color and
+// returns the squares they attack
+func (board *Board) evaluatePieceActivity(color int, enemyPawnAttacks uint64) (TaperedScore, uint64) {
+	score := TaperedScore{}
+	pieces := board.pieceBitboards(color)
+	own, enemy := board.WhitePawns, board.BlackPawns
+	ownPieces := board.WhitePieces()
+	if color == ColorBlack {
+		own, enemy = enemy, own
+		ownPieces = board.BlackPieces()
+	}
+	ownPawnAttacks := pawnAttackSquares(color, own)
+	king := board.kingSquare(color)
+	enemyKing := board.kingSquare(1 - color)
+	occupied := board.OccupiedSquares()
+	safe := ^ownPieces &^ enemyPawnAttacks
+
+	if bits.OnesCount64(pieces[activityBishop]) >= 2 {
+		score = score.Add(bishopPairBonus)
+	}
+
+	attacked := uint64(0)
+	for piece, bb := range pieces {
+		for ; bb != 0; bb &= bb - 1 {
+			sq := bits.TrailingZeros64(bb)
+			row, file := sq/8, sq%8
+			attacks := pieceAttacks(piece, sq, occupied)
+			attacked |= attacks
+			mobility := bits.OnesCount64(attacks & safe)
+			score = score.Add(mobilityWeight[piece].Mul(mobility - mobilityBaseline[piece]))
+
+			switch piece {
+			case activityKnight, activityBishop:
+				if mobility == 0 {
+					score = score.Add(trappedMinorPenalty)
+				}
+				if piece == activityKnight {
+					rank := relativeRank(color, row)
+					outOfReach := AdjacentFileMasks[file]&ForwardRanks[color][row]&enemy == 0
+					if rank >= 3 && rank <= 5 && ownPawnAttacks&(1<<sq) != 0 && outOfReach {
+						score = score.Add(knightOutpostBonus)
+					}
+				}
+			case activityRook:
+				if FileMasks[file]&own == 0 {
+					if FileMasks[file]&enemy == 0 {
+						score = score.Add(rookOpenFileBonus)
+					} else {
+						score = score.Add(rookSemiOpenFileBonus)
+					}
+				}
+				if relativeRank(color, row) == 6 &&
+					(relativeRank(color, enemyKing/8) == 7 || uint64(0xFF)<<(row*8)&enemy != 0) {
+					score = score.Add(rookSeventhRankBonus)
+				}
+				// A king that moved to the side of its rook without castling locks the rook in
+				kingFile := king % 8
+				if mobility <= 3 && relativeRank(color, king/8) == 0 &&
+					((kingFile >= 5 && file > kingFile) || (kingFile <= 2 && file < kingFile)) {
+					score = score.Add(trappedRookPenalty)
+				}
+			}
+		}
+	}
+	return score, attacked
+}
+
+// pieceAttacks returns the squares attacked by a knight, bishop, rook or queen on sq
+func pieceAttacks(piece int, sq int, occupied uint64) uint64 {
+	switch piece {
+	case activityKnight:
+		return KnightAttacks[sq]
+	case activityBishop:
+		return BishopAttacks(sq, occupied)
+	case activityRook:
+		return RookAttacks(sq, occupied)
+	}
+	return RookAttacks(sq, occupied) | BishopAttacks(sq, occupied)
+}
+
+// pieceBitboards returns the knights, bishops, rooks and queens of color, in the
+// order used by pieceAttacks
+func (board *Board) pieceBitboards(color int) [4]uint64 {
+	if color == ColorWhite {
+		return [4]uint64{board.WhiteKnights, board.WhiteBishops, board.WhiteRooks, board.WhiteQueens}
+	}
+	return [4]uint64{board.BlackKnights, board.BlackBishops, board.BlackRooks, board.BlackQueens}
+}
+
+// kingSquare returns the square of the king of color
+func (board *Board) kingSquare(color int) int {
+	if color == ColorWhite {
+		return bits.TrailingZeros64(board.WhiteKing)
+	}
+	return bits.TrailingZeros64(board.BlackKing)
+}
+
+// pawnAttackSquares returns the squares attacked by the pawns of color
+func pawnAttackSquares(color int, pawns uint64) uint64 {
+	attacks := uint64(0)
+	for bb := pawns; bb != 0; bb &= bb - 1 {
+		attacks |= PawnAttacks[color][bits.TrailingZeros64(bb)]
+	}
+	return attacks
+}
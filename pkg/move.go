@@ -66,6 +66,10 @@ type MoveState struct {
 	HalfMoveClock   int
 	FullMoveCounter int
 	WhiteToMove     bool
+
+	nnue       *nnueState // Network accumulators at the time of the move, nil without a network
+	nnueLength int
+	material   uint64 // Material signature at the time of the move, see Board.materialSignature
 }
 
 // CountMoves returns a summary of the number of moves by type in the current move list.
@@ -151,7 +155,9 @@ func (board *Board) Move(move Move) MoveState {
 		HalfMoveClock:   board.HalfMoveClock,
 		FullMoveCounter: board.FullMoveCounter,
 		WhiteToMove:     board.WhiteToMove,
+		material:        board.material,
 	}
+	board.pushAccumulator(&prev)
 
 	if !board.WhiteToMove {
 		board.FullMoveCounter += 1
@@ -166,6 +172,7 @@ func (board *Board) Move(move Move) MoveState {
 		board.HalfMoveClock += 1
 	}
 
+	board.updateMaterial(move, piece)
 	// Remove piece from 'from' square
 	board.clearPieceAtSquare(from, piece)
 	// Place piece at 'to' square
@@ -269,6 +276,9 @@ func (board *Board) Move(move Move) MoveState {
 // clearPieceAtSquare removes a piece from a square in the bitboards
 func (board *Board) clearPieceAtSquare(square byte, piece byte) {
 	mask := ^(uint64(1) << square)
+	if board.nnue != nil {
+		board.recordAccumulatorChange(square, piece, false)
+	}
 	switch piece {
 	case WhitePawn:
 		board.WhitePawns &= mask
@@ -300,6 +310,9 @@ func (board *Board) clearPieceAtSquare(square byte, piece byte) {
 // setPieceAtSquare places a piece on a square in the bitboards
 func (board *Board) setPieceAtSquare(square byte, piece byte) {
 	mask := uint64(1) << square
+	if board.nnue != nil {
+		board.recordAccumulatorChange(square, piece, true)
+	}
 	switch piece {
 	case WhitePawn:
 		board.WhitePawns |= mask
@@ -347,4 +360,6 @@ func (board *Board) UndoMove(state MoveState) {
 	board.HalfMoveClock = state.HalfMoveClock
 	board.FullMoveCounter = state.FullMoveCounter
 	board.WhiteToMove = state.WhiteToMove
+	board.material = state.material
+	board.popAccumulator(&state)
 }
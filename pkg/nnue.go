@@ -0,0 +1,335 @@
+package libra
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math/bits"
+	"os"
+)
+
+const (
+	NNUEInputs     = 768    // Chess768 features: 2 sides x 6 pieces x 64 squares
+	NNUEQA         = 255    // Quantization of the feature transformer (accumulator) weights
+	NNUEQB         = 64     // Quantization of the output weights
+	NNUEScale      = 400    // Output scale from the network to centipawns
+	NNUEMaxHidden  = 4096   // Largest accumulator accepted from a file
+	nnueMaxScore   = 30_000 // Network scores are clamped to stay clear of mate scores
+	nnueFileMagic  = "LBNN"
+	nnueVersion    = 1
+	nnueMaxChanges = 4 // Features changed by one move at most: castling removes and adds two
+)
+
+var ErrInvalidNetwork = errors.New("invalid network file")
+
+// Network is a (768->Hidden)x2->1 efficiently updatable neural network. Every position
+// is seen from both sides: a feature is (own or enemy piece) * 384 + piece * 64 + square,
+// piece ordered pawn to king, square a1 = 0 and flipped vertically for black. The two
+// accumulators, side to move first, go through a squared clipped ReLU into the output.
+type Network struct {
+	Hidden         int
+	FeatureWeights []int16 // NNUEInputs x Hidden, by feature, quantized by NNUEQA
+	FeatureBias    []int16 // Hidden, quantized by NNUEQA
+	OutputWeights  []int16 // 2 x Hidden, side to move then other side, quantized by NNUEQB
+	OutputBias     int16   // Quantized by NNUEQA * NNUEQB
+}
+
+// activeNetwork evaluates every board when set, see SetNetwork
+var activeNetwork *Network
+
+// nnuePieceIndex maps a piece code to color * 6 + piece + 1, 0 for no piece
+var nnuePieceIndex [256]int8
+
+func init() {
+	for piece := range whitePieceCodes {
+		nnuePieceIndex[whitePieceCodes[piece]] = int8(piece + 1)
+		nnuePieceIndex[blackPieceCodes[piece]] = int8(6 + piece + 1)
+	}
+}
+
+// NewNetwork creates a network with all weights zero
+func NewNetwork(hidden int) *Network {
+	return &Network{
+		Hidden:         hidden,
+		FeatureWeights: make([]int16, NNUEInputs*hidden),
+		FeatureBias:    make([]int16, hidden),
+		OutputWeights:  make([]int16, 2*hidden),
+	}
+}
+
+// SetNetwork makes network evaluate every board, or the classical evaluation for nil.
+// It must not be called while a search is running.
+func SetNetwork(network *Network) {
+	activeNetwork = network
+}
+
+// ActiveNetwork returns the network in use, nil for the classical evaluation
+func ActiveNetwork() *Network {
+	return activeNetwork
+}
+
+// nnueFeature returns the input of a piece (color * 6 + piece) on a square seen by perspective
+func nnueFeature(perspective int, piece int, square byte) int {
+	color, kind := piece/6, piece%6
+	sq := int(square) ^ 56 // a1 = 0
+	if perspective == ColorBlack {
+		color ^= 1
+		sq ^= 56
+	}
+	return color*384 + kind*64 + sq
+}
+
+// nnueChange is a piece added to or removed from a square by a move
+type nnueChange struct {
+	piece  int8 // color * 6 + piece
+	square byte
+	add    bool
+}
+
+// nnueEntry is the accumulator of one position of the line played on a board. Moves
+// only record their changes, the accumulator is computed from the previous one when
+// the position is evaluated.
+type nnueEntry struct {
+	accumulators [2][]int16 // White's and black's perspective
+	computed     bool
+	changes      [nnueMaxChanges]nnueChange
+	changeCount  int
+}
+
+// nnueState is the accumulator stack of a board, the last entry is the current position
+type nnueState struct {
+	network *Network
+	stack   []nnueEntry
+}
+
+// refreshAccumulator rebuilds the accumulator stack of the board from its bitboards
+func (board *Board) refreshAccumulator(network *Network) {
+	entry := nnueEntry{computed: true}
+	for perspective := ColorWhite; perspective <= ColorBlack; perspective++ {
+		acc := make([]int16, network.Hidden)
+		copy(acc, network.FeatureBias)
+		entry.accumulators[perspective] = acc
+	}
+	pieces := [12]uint64{
+		board.WhitePawns, board.WhiteKnights, board.WhiteBishops, board.WhiteRooks, board.WhiteQueens, board.WhiteKing,
+		board.BlackPawns, board.BlackKnights, board.BlackBishops, board.BlackRooks, board.BlackQueens, board.BlackKing,
+	}
+	for piece, bb := range pieces {
+		for ; bb != 0; bb &= bb - 1 {
+			square := byte(bits.TrailingZeros64(bb))
+			for perspective := ColorWhite; perspective <= ColorBlack; perspective++ {
+				network.addFeature(entry.accumulators[perspective], nnueFeature(perspective, piece, square))
+			}
+		}
+	}
+	board.nnue = &nnueState{network: network, stack: []nnueEntry{entry}}
+}
+
+// pushAccumulator starts the entry of the position after a move, saving the stack
+// to restore on UndoMove in state
+func (board *Board) pushAccumulator(state *MoveState) {
+	nnue := board.nnue
+	if nnue == nil {
+		return
+	}
+	length := len(nnue.stack)
+	state.nnue, state.nnueLength = nnue, length
+	if length < cap(nnue.stack) {
+		// Reuse the accumulators of a previously undone entry
+		nnue.stack = nnue.stack[:length+1]
+		entry := &nnue.stack[length]
+		entry.computed, entry.changeCount = false, 0
+	} else {
+		nnue.stack = append(nnue.stack, nnueEntry{})
+	}
+}
+
+// popAccumulator restores the stack saved by pushAccumulator
+func (board *Board) popAccumulator(state *MoveState) {
+	if board.nnue == nil {
+		return
+	}
+	if board.nnue != state.nnue {
+		// The accumulators were rebuilt after the move, rebuild them again when needed
+		board.nnue = nil
+		return
+	}
+	board.nnue.stack = board.nnue.stack[:state.nnueLength]
+}
+
+// recordAccumulatorChange notes a piece added to or removed from the current position
+func (board *Board) recordAccumulatorChange(square byte, piece byte, add bool) {
+	state := board.nnue
+	index := nnuePieceIndex[piece]
+	if state == nil || index == 0 || len(state.stack) < 2 {
+		return
+	}
+	entry := &state.stack[len(state.stack)-1]
+	if entry.changeCount == nnueMaxChanges {
+		// Not a move, fall back to a refresh
+		board.nnue = nil
+		return
+	}
+	entry.changes[entry.changeCount] = nnueChange{piece: index - 1, square: square, add: add}
+	entry.changeCount++
+}
+
+// currentAccumulator computes the accumulators of the current position from the last
+// computed entry of the stack, or from scratch when the network changed
+func (board *Board) currentAccumulator(network *Network) *nnueEntry {
+	if board.nnue == nil || board.nnue.network != network {
+		board.refreshAccumulator(network)
+	}
+	stack := board.nnue.stack
+	first := len(stack) - 1
+	for !stack[first].computed {
+		first--
+	}
+	for i := first + 1; i < len(stack); i++ {
+		prev, entry := &stack[i-1], &stack[i]
+		for perspective := ColorWhite; perspective <= ColorBlack; perspective++ {
+			if entry.accumulators[perspective] == nil {
+				entry.accumulators[perspective] = make([]int16, network.Hidden)
+			}
+			acc := entry.accumulators[perspective]
+			copy(acc, prev.accumulators[perspective])
+			for _, change := range entry.changes[:entry.changeCount] {
+				feature := nnueFeature(perspective, int(change.piece), change.square)
+				if change.add {
+					network.addFeature(acc, feature)
+				} else {
+					network.removeFeature(acc, feature)
+				}
+			}
+		}
+		entry.computed = true
+	}
+	return &stack[len(stack)-1]
+}
+
+func (network *Network) addFeature(acc []int16, feature int) {
+	weights := network.FeatureWeights[feature*network.Hidden : (feature+1)*network.Hidden]
+	for i, w := range weights {
+		acc[i] += w
+	}
+}
+
+func (network *Network) removeFeature(acc []int16, feature int) {
+	weights := network.FeatureWeights[feature*network.Hidden : (feature+1)*network.Hidden]
+	for i, w := range weights {
+		acc[i] -= w
+	}
+}
+
+// output returns the score of the side to move in centipawns. The sum is kept in
+// 64 bits: a single term reaches 255*255*32767, past the int of 32-bit builds.
+func (network *Network) output(us []int16, them []int16) int {
+	sum := int64(0)
+	for i, w := range network.OutputWeights[:network.Hidden] {
+		v := int64(screlu(us[i]))
+		sum += v * int64(w) * v
+	}
+	for i, w := range network.OutputWeights[network.Hidden:] {
+		v := int64(screlu(them[i]))
+		sum += v * int64(w) * v
+	}
+	score := (sum/NNUEQA + int64(network.OutputBias)) * NNUEScale / (NNUEQA * NNUEQB)
+	if score > nnueMaxScore {
+		return nnueMaxScore
+	}
+	if score < -nnueMaxScore {
+		return -nnueMaxScore
+	}
+	return int(score)
+}
+
+// screlu clips an accumulator value to [0, NNUEQA], squared by the caller
+func screlu(value int16) int {
+	return MathMaxInt(0, MathMinInt(int(value), NNUEQA))
+}
+
+// EvaluateNetwork returns the evaluation of network from white's perspective
+func (board *Board) EvaluateNetwork(network *Network) int {
+	entry := board.currentAccumulator(network)
+	white, black := entry.accumulators[ColorWhite], entry.accumulators[ColorBlack]
+	if board.WhiteToMove {
+		return network.output(white, black)
+	}
+	return -network.output(black, white)
+}
+
+// Save writes the network: the magic "LBNN", the format version and the hidden size
+// as little endian uint32, then the weights as little endian int16 in field order
+func (network *Network) Save(w io.Writer) error {
+	writer := bufio.NewWriter(w)
+	writer.WriteString(nnueFileMagic)
+	for _, value := range []any{uint32(nnueVersion), uint32(network.Hidden), network.FeatureWeights, network.FeatureBias, network.OutputWeights, network.OutputBias} {
+		if err := binary.Write(writer, binary.LittleEndian, value); err != nil {
+			return err
+		}
+	}
+	return writer.Flush()
+}
+
+// SaveFile writes the network to the file at path
+func (network *Network) SaveFile(path string) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := network.Save(file); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// LoadNetwork reads a network written by Save
+func LoadNetwork(r io.Reader) (*Network, error) {
+	reader := bufio.NewReader(r)
+	magic := make([]byte, len(nnueFileMagic))
+	if _, err := io.ReadFull(reader, magic); err != nil || string(magic) != nnueFileMagic {
+		return nil, fmt.Errorf("%w: bad magic", ErrInvalidNetwork)
+	}
+	var version, hidden uint32
+	if err := binary.Read(reader, binary.LittleEndian, &version); err != nil || version != nnueVersion {
+		return nil, fmt.Errorf("%w: unsupported version", ErrInvalidNetwork)
+	}
+	if err := binary.Read(reader, binary.LittleEndian, &hidden); err != nil || hidden == 0 || hidden > NNUEMaxHidden {
+		return nil, fmt.Errorf("%w: hidden size out of range", ErrInvalidNetwork)
+	}
+	network := NewNetwork(int(hidden))
+	for _, value := range []any{network.FeatureWeights, network.FeatureBias, network.OutputWeights, &network.OutputBias} {
+		if err := binary.Read(reader, binary.LittleEndian, value); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidNetwork, err)
+		}
+	}
+	if _, err := reader.ReadByte(); err != io.EOF {
+		return nil, fmt.Errorf("%w: trailing data", ErrInvalidNetwork)
+	}
+	return network, nil
+}
+
+// LoadNetworkFile reads the network saved in the file at path
+func LoadNetworkFile(path string) (*Network, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return LoadNetwork(file)
+}
+
+// isNetworkFile reports whether the file at path starts with the network magic
+func isNetworkFile(path string) bool {
+	file, err := os.Open(path)
+	if err != nil {
+		return false
+	}
+	defer file.Close()
+	magic := make([]byte, len(nnueFileMagic))
+	_, err = io.ReadFull(file, magic)
+	return err == nil && string(magic) == nnueFileMagic
+}
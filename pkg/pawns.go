@@ -0,0 +1,155 @@
+package libra
+
+import "math/bits"
+
+// Pawn structure weights, indexed by relative rank where applicable (0 = own back rank)
+var (
+	doubledPawnPenalty  = TaperedScore{-10, -40}
+	isolatedPawnPenalty = TaperedScore{-5, -15}
+	backwardPawnPenalty = TaperedScore{-9, -24}
+	connectedPawnBonus  = [8]TaperedScore{{0, 0}, {7, 0}, {8, 4}, {12, 6}, {29, 22}, {48, 44}, {86, 86}, {0, 0}}
+	passedPawnBonus     = [8]TaperedScore{{0, 0}, {5, 10}, {8, 15}, {8, 20}, {30, 35}, {80, 90}, {140, 130}, {0, 0}}
+)
+
+// Passed pawn endgame weights, multiplied by the rank weight of the passer (relative rank - 2)
+var (
+	passedEnemyKingDistance = 5  // per square between the enemy king and the stop square
+	passedOwnKingDistance   = 2  // per square between the own king and the stop square
+	passedFreePath          = 10 // when no piece stands between the passer and its promotion square
+)
+
+const PawnHashEntries = 1 << 14 // Entries of a pawn hash table, about 1MB
+
+type pawnEntry struct {
+	key    uint64
+	used   bool
+	scores [2]TaperedScore // pawn structure score of each color
+	passed [2]uint64       // passed pawns of each color
+}
+
+// PawnHashTable caches the pawn structure evaluation by pawn-only Zobrist key.
+// It is not safe for concurrent use, every search thread owns one.
+type PawnHashTable struct {
+	entries []pawnEntry
+}
+
+// NewPawnHashTable creates an empty pawn hash table
+func NewPawnHashTable() *PawnHashTable {
+	return &PawnHashTable{entries: make([]pawnEntry, PawnHashEntries)}
+}
+
+// Clear removes all entries
+func (pt *PawnHashTable) Clear() {
+	clear(pt.entries)
+}
+
+// SetPawnHashTable makes Evaluate cache pawn structures in pt, nil disables caching.
+// The board must not be evaluated concurrently with another board using the same table.
+func (board *Board) SetPawnHashTable(pt *PawnHashTable) {
+	board.pawnTable = pt
+}
+
+// EvaluatePawns returns the pawn structure and passed pawn score of each color
+func (board *Board) EvaluatePawns() [2]TaperedScore {
+	entry := board.probePawnStructure()
+	scores := entry.scores
+	for color := ColorWhite; color <= ColorBlack; color++ {
+		scores[color] = scores[color].Add(board.evaluatePassedPawns(color, entry.passed[color]))
+	}
+	return scores
+}
+
+// probePawnStructure returns the cached pawn structure, computing it on a miss
+func (board *Board) probePawnStructure() pawnEntry {
+	key := board.PawnHash()
+	if board.pawnTable == nil {
+		return board.computePawnStructure(key)
+	}
+	slot := &board.pawnTable.entries[key%PawnHashEntries]
+	if !slot.used || slot.key != key {
+		*slot = board.computePawnStructure(key)
+	}
+	return *slot
+}
+
+func (board *Board) computePawnStructure(key uint64) pawnEntry {
+	entry := pawnEntry{key: key, used: true}
+	pawns := [2]uint64{board.WhitePawns, board.BlackPawns}
+	for color := ColorWhite; color <= ColorBlack; color++ {
+		own, enemy := pawns[color], pawns[1-color]
+		score := TaperedScore{}
+		for bb := own; bb != 0; bb &= bb - 1 {
+			sq := bits.TrailingZeros64(bb)
+			row, file := sq/8, sq%8
+			rank := relativeRank(color, row)
+			stop := sq - 8
+			if color == ColorBlack {
+				stop = sq + 8
+			}
+
+			doubled := ForwardFileMasks[color][sq]&own != 0
+			isolated := AdjacentFileMasks[file]&own == 0
+			// Own pawns defending the pawn, or next to it on the same rank
+			supported := PawnAttacks[1-color][sq] & own
+			phalanx := AdjacentFileMasks[file] & (0xFF << (row * 8)) & own
+			// No own pawn on the adjacent files can come to support it and its stop square is controlled
+			backward := !isolated && supported|phalanx == 0 &&
+				AdjacentFileMasks[file]&^ForwardRanks[color][row]&own == 0 &&
+				PawnAttacks[color][stop]&enemy != 0
+
+			if doubled {
+				score = score.Add(doubledPawnPenalty)
+			}
+			if isolated {
+				score = score.Add(isolatedPawnPenalty)
+			} else if backward {
+				score = score.Add(backwardPawnPenalty)
+			}
+			if supported|phalanx != 0 {
+				score = score.Add(connectedPawnBonus[rank])
+			}
+			if !doubled && PassedPawnMasks[color][sq]&enemy == 0 {
+				score = score.Add(passedPawnBonus[rank])
+				entry.passed[color] |= 1 << sq
+			}
+		}
+		entry.scores[color] = score
+	}
+	return entry
+}
+
+// evaluatePassedPawns scores the passed pawns of color by king proximity and free path,
+// terms that depend on more than the pawns and so are not cached
+func (board *Board) evaluatePassedPawns(color int, passed uint64) TaperedScore {
+	score := TaperedScore{}
+	if passed == 0 || board.WhiteKing == 0 || board.BlackKing == 0 {
+		return score
+	}
+	kings := [2]int{bits.TrailingZeros64(board.WhiteKing), bits.TrailingZeros64(board.BlackKing)}
+	occupied := board.OccupiedSquares()
+	for bb := passed; bb != 0; bb &= bb - 1 {
+		sq := bits.TrailingZeros64(bb)
+		weight := relativeRank(color, sq/8) - 2
+		if weight <= 0 {
+			continue
+		}
+		stop := sq - 8
+		if color == ColorBlack {
+			stop = sq + 8
+		}
+		score.Eg += (SquareDistance[kings[1-color]][stop]*passedEnemyKingDistance -
+			SquareDistance[kings[color]][stop]*passedOwnKingDistance) * weight
+		if ForwardFileMasks[color][sq]&occupied == 0 {
+			score.Eg += passedFreePath * weight
+		}
+	}
+	return score
+}
+
+// relativeRank returns the rank of row from color's side, 0 is the own back rank
+func relativeRank(color int, row int) int {
+	if color == ColorWhite {
+		return 7 - row
+	}
+	return row
+}
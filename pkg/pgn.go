@@ -0,0 +1,200 @@
+package libra
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+var ErrInvalidPGN = errors.New("invalid PGN")
+
+// PGNGame is a game of a PGN file
+type PGNGame struct {
+	Tags   map[string]string // Tag pairs, like "White" or "FEN"
+	Moves  []string          // Main line in SAN, without move numbers, comments, NAGs and variations
+	Result string            // "1-0", "0-1", "1/2-1/2" or "*" when unknown
+	Line   int               // Line of the file the game starts at
+}
+
+// Start returns the starting position of the game, from its FEN tag if any
+func (game *PGNGame) Start() (*Board, error) {
+	board := NewBoard()
+	fen, ok := game.Tags["FEN"]
+	if !ok {
+		board.LoadInitial()
+		return board, nil
+	}
+	if _, err := board.FromFEN(fen); err != nil {
+		return nil, err
+	}
+	return board, nil
+}
+
+// PGNReader reads the games of a PGN file one at a time
+type PGNReader struct {
+	scanner   *bufio.Scanner
+	line      int
+	pending   string // Tag line starting the next game, read before the end of a game without a result
+	hasLine   bool
+	comment   bool // Inside a {} comment
+	variation int  // Depth of the () variations
+}
+
+// NewPGNReader creates a reader of the games in r
+func NewPGNReader(r io.Reader) *PGNReader {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	return &PGNReader{scanner: scanner}
+}
+
+// Next returns the next game, or io.EOF after the last one. Tag pairs and the
+// main line are read, everything else is skipped. A game ends with its result,
+// or at the tags of the next game or the end of the file.
+func (reader *PGNReader) Next() (*PGNGame, error) {
+	game := &PGNGame{Tags: map[string]string{}}
+	movetext := false
+	for {
+		line, ok := reader.readLine()
+		if !ok {
+			if err := reader.scanner.Err(); err != nil {
+				return nil, err
+			}
+			if game.Line == 0 {
+				return nil, io.EOF
+			}
+			if reader.comment || reader.variation > 0 {
+				return nil, fmt.Errorf("line %d: %w: unterminated comment or variation", reader.line, ErrInvalidPGN)
+			}
+			game.finish()
+			return game, nil
+		}
+		trimmed := strings.TrimSpace(line)
+		// Lines starting with % are escaped
+		if trimmed == "" || (!reader.comment && strings.HasPrefix(line, "%")) {
+			continue
+		}
+		if !reader.comment && reader.variation == 0 && strings.HasPrefix(trimmed, "[") {
+			if movetext {
+				reader.pending, reader.hasLine = line, true
+				reader.line--
+				game.finish()
+				return game, nil
+			}
+			if err := game.parseTag(trimmed); err != nil {
+				return nil, fmt.Errorf("line %d: %w", reader.line, err)
+			}
+			if game.Line == 0 {
+				game.Line = reader.line
+			}
+			continue
+		}
+		if game.Line == 0 {
+			game.Line = reader.line
+		}
+		movetext = true
+		done, err := reader.parseMoves(line, game)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", reader.line, err)
+		}
+		if done {
+			return game, nil
+		}
+	}
+}
+
+func (reader *PGNReader) readLine() (string, bool) {
+	if reader.hasLine {
+		reader.hasLine = false
+		reader.line++
+		return reader.pending, true
+	}
+	if !reader.scanner.Scan() {
+		return "", false
+	}
+	reader.line++
+	return reader.scanner.Text(), true
+}
+
+// parseTag parses a tag pair like [White "Carlsen, Magnus"]
+func (game *PGNGame) parseTag(line string) error {
+	if !strings.HasSuffix(line, "]") {
+		return fmt.Errorf("%w: tag %q", ErrInvalidPGN, line)
+	}
+	name, value, ok := strings.Cut(line[1:len(line)-1], " ")
+	value = strings.TrimSpace(value)
+	if !ok || name == "" || len(value) < 2 || value[0] != '"' || value[len(value)-1] != '"' {
+		return fmt.Errorf("%w: tag %q", ErrInvalidPGN, line)
+	}
+	value = strings.NewReplacer(`\"`, `"`, `\\`, `\`).Replace(value[1 : len(value)-1])
+	game.Tags[name] = value
+	return nil
+}
+
+// parseMoves reads the main line moves of a line of movetext, returning true at
+// the result ending the game
+func (reader *PGNReader) parseMoves(line string, game *PGNGame) (bool, error) {
+	for i := 0; i < len(line); {
+		c := line[i]
+		switch {
+		case reader.comment:
+			if c == '}' {
+				reader.comment = false
+			}
+			i++
+		case c == '{':
+			reader.comment = true
+			i++
+		case c == ';':
+			return false, nil
+		case c == '(':
+			reader.variation++
+			i++
+		case c == ')':
+			if reader.variation == 0 {
+				return false, fmt.Errorf("%w: unbalanced ')'", ErrInvalidPGN)
+			}
+			reader.variation--
+			i++
+		case c == ' ' || c == '\t' || c == '\r':
+			i++
+		default:
+			end := i
+			for end < len(line) && !strings.ContainsRune(" \t\r{}();", rune(line[end])) {
+				end++
+			}
+			token := line[i:end]
+			i = end
+			if reader.variation > 0 || token[0] == '$' {
+				continue
+			}
+			switch token {
+			case "1-0", "0-1", "1/2-1/2", "*":
+				game.Result = token
+				return true, nil
+			}
+			// Move numbers like "12." or "12...", maybe attached to the move, but
+			// not castling written with zeros
+			if token[0] >= '0' && token[0] <= '9' && !strings.HasPrefix(token, "0-0") {
+				dot := strings.LastIndexByte(token, '.')
+				if dot < 0 {
+					return false, fmt.Errorf("%w: token %q", ErrInvalidPGN, token)
+				}
+				token = token[dot+1:]
+			}
+			if token != "" {
+				game.Moves = append(game.Moves, token)
+			}
+		}
+	}
+	return false, nil
+}
+
+// finish sets the result of a game without one in its movetext from its tags
+func (game *PGNGame) finish() {
+	game.Result = "*"
+	if result, ok := game.Tags["Result"]; ok {
+		game.Result = result
+	}
+}
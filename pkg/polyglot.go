@@ -0,0 +1,128 @@
+package libra
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"sort"
+)
+
+const (
+	PolyglotExtension   = ".bin"
+	polyglotEntrySize   = 16 // Key, move, weight and learn fields, big endian
+	polyglotMaxWeight   = 0xFFFF
+	polyglotCastling    = 768
+	polyglotEnPassant   = 772
+	polyglotWhiteToMove = 780
+)
+
+// polyglotKinds are the Polyglot piece kinds, black then white for every piece
+var polyglotKinds = map[byte]int{
+	BlackPawn: 0, WhitePawn: 1, BlackKnight: 2, WhiteKnight: 3,
+	BlackBishop: 4, WhiteBishop: 5, BlackRook: 6, WhiteRook: 7,
+	BlackQueen: 8, WhiteQueen: 9, BlackKing: 10, WhiteKing: 11,
+}
+
+// polyglotPromotions are the Polyglot codes of the promotion pieces
+var polyglotPromotions = map[byte]uint16{
+	WhiteKnight: 1, WhiteBishop: 2, WhiteRook: 3, WhiteQueen: 4,
+	BlackKnight: 1, BlackBishop: 2, BlackRook: 3, BlackQueen: 4,
+}
+
+// PolyglotKey returns the Zobrist key of the position in the Polyglot book format.
+// Unlike ZobristHash, the en passant file only counts when a pawn can capture.
+func (board *Board) PolyglotKey() uint64 {
+	var key uint64
+	for sq := 0; sq < 64; sq++ {
+		piece := board.PieceAtSquare(byte(sq))
+		if piece == 0 {
+			continue
+		}
+		// Polyglot squares run from a1, ours from a8
+		key ^= polyglotRandom[64*polyglotKinds[piece]+(sq^56)]
+	}
+	castling := [4]bool{board.Castling.WhiteKingSide, board.Castling.WhiteQueenSide, board.Castling.BlackKingSide, board.Castling.BlackQueenSide}
+	for i, allowed := range castling {
+		if allowed {
+			key ^= polyglotRandom[polyglotCastling+i]
+		}
+	}
+	if board.canCaptureEnPassant() {
+		key ^= polyglotRandom[polyglotEnPassant+int(board.OnPassant%8)]
+	}
+	if board.WhiteToMove {
+		key ^= polyglotRandom[polyglotWhiteToMove]
+	}
+	return key
+}
+
+// polyglotMove encodes a move in the Polyglot format: the to square, the from
+// square and the promotion piece, with castling as the king taking its rook
+func polyglotMove(move Move) uint16 {
+	to := move.To
+	if move.MoveType == MoveCastle {
+		if to%8 == 6 {
+			to++
+		} else {
+			to -= 2
+		}
+	}
+	// Polyglot rows run from the first rank, ours from the eighth
+	code := uint16(to%8) | uint16(7-to/8)<<3 | uint16(move.From%8)<<6 | uint16(7-move.From/8)<<9
+	if move.IsPromotion() {
+		code |= polyglotPromotions[move.Promoted] << 12
+	}
+	return code
+}
+
+// LoadPolyglot reads a Polyglot book (.bin): 16-byte entries of a position key,
+// a move, its weight and a learn field ignored here. Moves are checked to be legal
+// when probing, since only the keys of the positions are stored.
+func LoadPolyglot(r io.Reader) (*Book, error) {
+	book := NewBook()
+	var entry [polyglotEntrySize]byte
+	for index := 0; ; index++ {
+		if _, err := io.ReadFull(r, entry[:]); err == io.EOF {
+			return book, nil
+		} else if err == io.ErrUnexpectedEOF {
+			return nil, fmt.Errorf("entry %d: %w: truncated", index, ErrInvalidBook)
+		} else if err != nil {
+			return nil, err
+		}
+		key := binary.BigEndian.Uint64(entry[0:8])
+		move := binary.BigEndian.Uint16(entry[8:10])
+		weight := binary.BigEndian.Uint16(entry[10:12])
+		book.add(key, move, int(weight))
+	}
+}
+
+// SavePolyglot writes the book as a Polyglot book (.bin), sorted by key with the
+// heaviest moves first. The weights of a position are scaled down to fit in 16
+// bits when needed, keeping every move at least 1.
+func (book *Book) SavePolyglot(w io.Writer) error {
+	keys := make([]uint64, 0, len(book.positions))
+	for key := range book.positions {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i] < keys[j] })
+	var entry [polyglotEntrySize]byte
+	for _, key := range keys {
+		entries := append([]bookEntry(nil), book.positions[key]...)
+		sort.SliceStable(entries, func(i, j int) bool { return entries[i].weight > entries[j].weight })
+		heaviest := entries[0].weight
+		for _, e := range entries {
+			weight := e.weight
+			if heaviest > polyglotMaxWeight {
+				weight = MathMaxInt(weight*polyglotMaxWeight/heaviest, 1)
+			}
+			binary.BigEndian.PutUint64(entry[0:8], key)
+			binary.BigEndian.PutUint16(entry[8:10], e.move)
+			binary.BigEndian.PutUint16(entry[10:12], uint16(weight))
+			binary.BigEndian.PutUint32(entry[12:16], 0)
+			if _, err := w.Write(entry[:]); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
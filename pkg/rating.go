@@ -0,0 +1,132 @@
+package libra
+
+import (
+	"errors"
+	"math"
+)
+
+// RatingResult is the outcome of the games between two players of a rating fit
+type RatingResult struct {
+	A, B  int     // Players, indices of the ratings
+	Score float64 // Points of A, a draw counts half
+	Games int
+}
+
+var ErrRatingUnconnected = errors.New("players not connected by games")
+
+const (
+	ratingScale      = 400 / math.Ln10 // Elo points per unit of the logistic model
+	ratingIterations = 100
+)
+
+// FitRatings returns the Elo ratings of players 0 to n-1 that best explain the
+// results under the logistic Elo model, by maximum likelihood, and their standard
+// errors. Ratings are relative: player pinned gets pinnedElo and no error. Every
+// result counts one more drawn game so even one-sided results have a finite fit.
+// Players without a chain of games to pinned fail with ErrRatingUnconnected.
+func FitRatings(n int, results []RatingResult, pinned int, pinnedElo float64) ([]float64, []float64, error) {
+	// free maps the players to the rows of the system, pinned excluded
+	free := make([]int, n)
+	for i, row := 0, 0; i < n; i++ {
+		free[i] = -1
+		if i != pinned {
+			free[i] = row
+			row++
+		}
+	}
+	x := make([]float64, n)
+	var hessian [][]float64
+	for iteration := 0; iteration < ratingIterations; iteration++ {
+		gradient := make([]float64, n-1)
+		hessian = make([][]float64, n-1)
+		for i := range hessian {
+			hessian[i] = make([]float64, n-1)
+		}
+		for _, result := range results {
+			games := float64(result.Games) + 1
+			score := result.Score + 0.5
+			p := 1 / (1 + math.Exp(x[result.B]-x[result.A]))
+			weight := games * p * (1 - p)
+			a, b := free[result.A], free[result.B]
+			if a >= 0 {
+				gradient[a] += score - games*p
+				hessian[a][a] += weight
+			}
+			if b >= 0 {
+				gradient[b] -= score - games*p
+				hessian[b][b] += weight
+			}
+			if a >= 0 && b >= 0 {
+				hessian[a][b] -= weight
+				hessian[b][a] -= weight
+			}
+		}
+		step, ok := solveLinear(hessian, gradient)
+		if !ok {
+			return nil, nil, ErrRatingUnconnected
+		}
+		change := 0.0
+		for i := range x {
+			if free[i] >= 0 {
+				x[i] += step[free[i]]
+				change = math.Max(change, math.Abs(step[free[i]]))
+			}
+		}
+		if change < 1e-9 {
+			break
+		}
+	}
+	ratings, errs := make([]float64, n), make([]float64, n)
+	for i := range x {
+		ratings[i] = pinnedElo + ratingScale*x[i]
+		if free[i] < 0 {
+			continue
+		}
+		// The variance is the diagonal of the inverse of the information matrix
+		unit := make([]float64, n-1)
+		unit[free[i]] = 1
+		column, ok := solveLinear(hessian, unit)
+		if !ok {
+			return nil, nil, ErrRatingUnconnected
+		}
+		errs[i] = ratingScale * math.Sqrt(column[free[i]])
+	}
+	return ratings, errs, nil
+}
+
+// solveLinear solves a x = b by Gaussian elimination with partial pivoting,
+// reporting false for a singular matrix. a and b are left unchanged.
+func solveLinear(a [][]float64, b []float64) ([]float64, bool) {
+	n := len(b)
+	m := make([][]float64, n)
+	for i := range m {
+		m[i] = append(append([]float64{}, a[i]...), b[i])
+	}
+	for col := 0; col < n; col++ {
+		pivot := col
+		for row := col + 1; row < n; row++ {
+			if math.Abs(m[row][col]) > math.Abs(m[pivot][col]) {
+				pivot = row
+			}
+		}
+		if math.Abs(m[pivot][col]) < 1e-12 {
+			return nil, false
+		}
+		m[col], m[pivot] = m[pivot], m[col]
+		for row := col + 1; row < n; row++ {
+			factor := m[row][col] / m[col][col]
+			for k := col; k <= n; k++ {
+				m[row][k] -= factor * m[col][k]
+			}
+		}
+	}
+	x := make([]float64, n)
+	for row := n - 1; row >= 0; row-- {
+		sum := m[row][n]
+		for k := row + 1; k < n; k++ {
+			sum -= m[row][k] * x[k]
+		}
+		x[row] = sum / m[row][row]
+	}
+	return x, true
+}
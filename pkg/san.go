@@ -0,0 +1,70 @@
+package libra
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var ErrInvalidSAN = errors.New("invalid SAN move")
+
+// ParseSAN parses a move in standard algebraic notation (e.g., "Nf3", "exd5",
+// "e8=Q+", "O-O") and returns the legal move it stands for. Check, mate and
+// annotation suffixes are ignored, as are a missing "=" before the promotion piece
+// and zeros for castling.
+func (board *Board) ParseSAN(san string) (*Move, error) {
+	text := strings.TrimRight(san, "+#!?")
+	if text == "" {
+		return nil, fmt.Errorf("%w: %q", ErrInvalidSAN, san)
+	}
+	moves := board.GenerateLegalMoves()
+	if castle := strings.ReplaceAll(text, "0", "O"); castle == "O-O" || castle == "O-O-O" {
+		kingSide := castle == "O-O"
+		for _, move := range moves {
+			if move.MoveType == MoveCastle && (move.To%8 == 6) == kingSide {
+				return &move, nil
+			}
+		}
+		return nil, fmt.Errorf("%w: %q is illegal in %s", ErrInvalidSAN, san, board.ToFEN())
+	}
+
+	piece := byte(WhitePawn)
+	if strings.IndexByte("NBRQK", text[0]) >= 0 {
+		piece, text = text[0], text[1:]
+	}
+	var promoted byte
+	if n := len(text); n > 2 && strings.IndexByte("NBRQ", text[n-1]) >= 0 {
+		promoted, text = text[n-1], strings.TrimSuffix(text[:n-1], "=")
+	}
+	if len(text) < 2 {
+		return nil, fmt.Errorf("%w: %q", ErrInvalidSAN, san)
+	}
+	to, ok := SquareNameToIndex(text[len(text)-2:])
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrInvalidSAN, san)
+	}
+	// What is left is the file and or rank of the moving piece and the capture sign
+	from := strings.TrimSuffix(text[:len(text)-2], "x")
+	if len(from) > 2 {
+		return nil, fmt.Errorf("%w: %q", ErrInvalidSAN, san)
+	}
+
+	var found *Move
+	for i, move := range moves {
+		if move.To != to || move.Piece&^0x20 != piece || move.Promoted&^0x20 != promoted {
+			continue
+		}
+		name, _ := SquareIndexToName(move.From)
+		if !strings.Contains(name, from) {
+			continue
+		}
+		if found != nil {
+			return nil, fmt.Errorf("%w: %q is ambiguous in %s", ErrInvalidSAN, san, board.ToFEN())
+		}
+		found = &moves[i]
+	}
+	if found == nil {
+		return nil, fmt.Errorf("%w: %q is illegal in %s", ErrInvalidSAN, san, board.ToFEN())
+	}
+	return found, nil
+}
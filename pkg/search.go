@@ -7,21 +7,36 @@ import (
 )
 
 const (
-	SearchMaxDepth      = 16        // Maximum depth to search
-	MaxEvaluationScore  = 1_000_000 // Maximum score for wining
-	MaxEvaluationTimeMs = 3_000     // Maximum time for a search at the root level
+	SearchMaxDepth      = 16                         // Maximum depth to search
+	MaxEvaluationScore  = 1_000_000                  // Maximum score for wining, a mate at the root
+	MateScoreThreshold  = MaxEvaluationScore - 1_000 // Mate scores are past it, one less per ply to mate
+	MaxEvaluationTimeMs = 3_000                      // Maximum time for a search at the root level
 )
 
 type SearchOptions struct {
 	MaxDepth           int                 // Maximum search depth (plies)
 	TimeLimitInMs      int                 // Soft time limit: stop deepening after this (ms)
 	MaxTimeLimitInMs   int                 // Hard time limit: abort in-flight search after this (ms)
+	TimeManager        *TimeManager        // Optional time manager, overrides TimeLimitInMs and MaxTimeLimitInMs
 	TranspositionTable *TranspositionTable // Optional transposition table to use for search
-	UseBookMoves       bool                // Optional flag to use book moves
-	StopChan           chan struct{}        // External stop signal (e.g. UCI "stop" command)
+	Threads            *SearchThreads      // Optional long-lived search contexts, keeps heuristics between moves
+	Tracer             SearchTracer        // Optional hook recording the searched tree for debugging
+	MaxNodes           uint64              // Optional node limit for the whole search, 0 = no limit
+	Contempt           int                 // Draw score penalty for the side to move at the root (cp)
+	Skill              *Skill              // Optional strength limit, nil = full strength
+	Params             *SearchParams       // Optional search parameters, nil = DefaultSearchParams
+	UseBookMoves       bool                // Optional flag to play a move of Book without searching
+	Book               *Book               // Opening book used with UseBookMoves
+	StopChan           chan struct{}       // External stop signal (e.g. UCI "stop" command)
+	OnIteration        func(*SearchResult) // Optional callback after every iteration, including interrupted ones
 }
 
 func (board *Board) IterativeDeepeningSearch(options SearchOptions) *Move {
+	if options.UseBookMoves {
+		if move := options.Book.PickMove(board); move != nil {
+			return move
+		}
+	}
 	tt := options.TranspositionTable
 	if tt == nil {
 		tt = NewTranspositionTable()
@@ -31,72 +46,146 @@ func (board *Board) IterativeDeepeningSearch(options SearchOptions) *Move {
 	if options.MaxDepth != 0 {
 		maxDepth = options.MaxDepth
 	}
+	maxNodes := options.MaxNodes
+	skill := options.Skill
+	if skill.Enabled() {
+		maxDepth = MathMinInt(maxDepth, skill.MaxDepth())
+		if maxNodes == 0 || maxNodes > skill.MaxNodes() {
+			maxNodes = skill.MaxNodes()
+		}
+	}
 
-	// Soft limit: stop starting new depths after this
-	softLimit := options.TimeLimitInMs
-	// Hard limit: abort in-flight search after this
-	hardLimit := options.MaxTimeLimitInMs
+	tm := options.TimeManager
+	if tm == nil {
+		// Soft limit: stop starting new depths after this
+		softLimit := options.TimeLimitInMs
+		// Hard limit: abort in-flight search after this
+		hardLimit := options.MaxTimeLimitInMs
+
+		// Fall back to defaults when no time info is provided
+		if softLimit == 0 && hardLimit == 0 && options.MaxDepth == 0 && options.MaxNodes == 0 {
+			softLimit = MaxEvaluationTimeMs
+			hardLimit = MaxEvaluationTimeMs
+		}
 
-	// Fall back to defaults when no time info is provided
-	if softLimit == 0 && hardLimit == 0 && options.MaxDepth == 0 {
-		softLimit = MaxEvaluationTimeMs
-		hardLimit = MaxEvaluationTimeMs
+		// If only one is set, use it for both
+		if softLimit == 0 && hardLimit > 0 {
+			softLimit = hardLimit
+		}
+		if hardLimit == 0 && softLimit > 0 {
+			hardLimit = softLimit
+		}
+		tm = NewTimeManager(softLimit, hardLimit)
 	}
-
-	// If only one is set, use it for both
-	if softLimit == 0 && hardLimit > 0 {
-		softLimit = hardLimit
+	tm.SetRootPosition(len(board.GenerateLegalMoves()), board.Phase())
+	drawScore := DrawScore(options.Contempt, board.WhiteToMove)
+	tt.SetDrawScore(drawScore)
+	tt.NewSearch()
+
+	threads := options.Threads
+	if threads == nil {
+		threads = acquireSearchThreads()
+		defer releaseSearchThreads(threads)
 	}
-	if hardLimit == 0 && softLimit > 0 {
-		hardLimit = softLimit
+	threads.Age()
+	threads.Tracer = options.Tracer
+	threads.DrawScore = drawScore
+	threads.Params = DefaultSearchParams()
+	if options.Params != nil {
+		threads.Params = *options.Params
 	}
 
 	var bestMove *Move
+	var rootMoves []RootMove
 	totalTimeSpentInMs := 0
+	lastIterationTimeInMs := 0
+	var totalNodes uint64
 	// Iterative deepening
 	for depth := 1; depth <= maxDepth; depth++ {
-		// Stop deepening if we've exceeded the soft limit
-		if softLimit > 0 && totalTimeSpentInMs >= softLimit {
+		// Stop deepening if the next iteration is not worth starting
+		if depth > 1 && !tm.CanStartIteration(totalTimeSpentInMs, lastIterationTimeInMs) {
 			break
 		}
-		// Give in-flight search up to the hard limit remaining
-		searchTimeLimit := 0
-		if hardLimit > 0 {
-			searchTimeLimit = hardLimit - totalTimeSpentInMs
-			if searchTimeLimit <= 0 {
+		// Give in-flight search up to the hard limit and node budget remaining
+		searchTimeLimit := tm.RemainingMs(totalTimeSpentInMs)
+		if maxNodes > 0 {
+			if totalNodes >= maxNodes {
 				break
 			}
+			threads.NodeLimit = maxNodes - totalNodes
+		}
+		result := board.SearchWithThreads(depth, tt, threads, searchTimeLimit, bestMove, options.StopChan)
+		if options.OnIteration != nil {
+			options.OnIteration(result)
 		}
-		result := board.Search(depth, tt, searchTimeLimit, bestMove, options.StopChan)
-		result.PrintUCI()
 		if result.BestMove != nil && (!result.IsInterrupted || bestMove == nil) {
 			bestMove = result.BestMove
 		}
-		totalTimeSpentInMs += int(result.TimeSpentInMs)
-		// If search was interrupted (timeout or stop), don't start next depth
+		lastIterationTimeInMs = int(result.TimeSpentInMs)
+		totalTimeSpentInMs += lastIterationTimeInMs
+		totalNodes += result.NodesSearched
+		// If search was interrupted (timeout, stop or node limit), don't start next depth
 		if result.IsInterrupted {
 			break
 		}
+		rootMoves = result.RootMoves
+		score := result.BestScore
+		if !board.WhiteToMove {
+			score = -score
+		}
+		tm.Update(result.BestMove, score)
 	}
+	threads.NodeLimit = 0
 
+	if skill.Enabled() && len(rootMoves) > 0 {
+		return skill.PickMove(rootMoves, board.WhiteToMove)
+	}
 	return bestMove
 }
 
+// Search searches to a fixed depth with cleared search contexts
 func (board *Board) Search(depth int, tt *TranspositionTable, timeLimitInMs int, pvMove *Move, stopChan chan struct{}) *SearchResult {
+	threads := acquireSearchThreads()
+	defer releaseSearchThreads(threads)
+	return board.SearchWithThreads(depth, tt, threads, timeLimitInMs, pvMove, stopChan)
+}
+
+// SearchWithThreads searches to a fixed depth reusing the heuristics of the given search contexts
+func (board *Board) SearchWithThreads(depth int, tt *TranspositionTable, threads *SearchThreads, timeLimitInMs int, pvMove *Move, stopChan chan struct{}) *SearchResult {
 	result := &SearchResult{}
 	result.StartTimer()
 	result.SetMaxSearchDepth(int32(depth))
-	moves := board.GenerateLegalMoves()
+	moves := board.syzygyRootMoves(board.tablebaseRootMoves(board.GenerateLegalMoves()))
 	result.IncMoveGeneration()
 	ttMove := tt.BestMoveDeepest(board.ZobristHash())
 	moves = board.SortMovesRoot(moves, pvMove, ttMove)
-	ctx := &SearchContext{Done: make(chan struct{})}
+	done := make(chan struct{})
+	rootTraceID := -1
+	if threads.Tracer != nil {
+		rootTraceID = threads.Tracer.EnterNode(TraceNode{
+			Parent:     -1,
+			Path:       []string{},
+			Depth:      depth,
+			Alpha:      -MaxEvaluationScore,
+			Beta:       MaxEvaluationScore,
+			StaticEval: board.Evaluate(),
+		})
+	}
+	for _, ctx := range threads.Contexts {
+		ctx.Done = done
+		ctx.NodeLimit = threads.NodeLimit
+		ctx.DrawScore = threads.DrawScore
+		ctx.Params = &threads.Params
+		ctx.Tracer = threads.Tracer
+		ctx.traceIDs[0] = rootTraceID
+		ctx.ResetDepthStats()
+	}
 
 	var score int
 	var move *Move
 	finished := make(chan struct{})
 	go func() {
-		score, move = board.ParallelRootSearch(depth, tt, moves, result, ctx)
+		score, move = board.ParallelRootSearch(depth, tt, moves, result, threads)
 		close(finished)
 	}()
 
@@ -105,12 +194,12 @@ func (board *Board) Search(depth int, tt *TranspositionTable, timeLimitInMs int,
 		timeout := time.After(time.Duration(timeLimitInMs) * time.Millisecond)
 		select {
 		case <-timeout:
-			close(ctx.Done)
+			close(done)
 			<-finished
 			result.IsInterrupted = true
 		case <-finished:
 		case <-stopChan:
-			close(ctx.Done)
+			close(done)
 			<-finished
 			result.IsInterrupted = true
 		}
@@ -119,7 +208,7 @@ func (board *Board) Search(depth int, tt *TranspositionTable, timeLimitInMs int,
 		select {
 		case <-finished:
 		case <-stopChan:
-			close(ctx.Done)
+			close(done)
 			<-finished
 			result.IsInterrupted = true
 		}
@@ -128,9 +217,24 @@ func (board *Board) Search(depth int, tt *TranspositionTable, timeLimitInMs int,
 		<-finished
 	}
 
+	if threads.NodeLimit > 0 && result.NodesSearched >= threads.NodeLimit {
+		result.IsInterrupted = true
+	}
 	result.BestScore = score
+	result.mergeDepthStats(threads.Contexts)
 	result.StopTimer()
 	result.BestMove = move
+	if rootTraceID >= 0 {
+		cutoff := CutoffNone
+		if result.IsInterrupted {
+			cutoff = CutoffInterrupted
+		}
+		best := ""
+		if move != nil {
+			best = move.ToUCI()
+		}
+		threads.Tracer.ExitNode(rootTraceID, score, best, cutoff)
+	}
 	return result
 }
 
@@ -141,17 +245,17 @@ type ConcurrentSearch struct {
 }
 
 // ParallelRootSearch allows passing in a pre-sorted move list
-func (board *Board) ParallelRootSearch(depth int, tt *TranspositionTable, moves []Move, stats *SearchResult, ctx *SearchContext) (int, *Move) {
+// Every worker searches with its own context from threads.
+func (board *Board) ParallelRootSearch(depth int, tt *TranspositionTable, moves []Move, stats *SearchResult, threads *SearchThreads) (int, *Move) {
 	maximizing := board.WhiteToMove
 	bestScore := -MaxEvaluationScore
 	if !maximizing {
 		bestScore = MaxEvaluationScore
 	}
 	if len(moves) == 0 {
-		return board.MateOrStalemateScore(maximizing), nil
+		return board.MateOrDrawScore(maximizing, threads.DrawScore), nil
 	}
 
-	numWorkers := runtime.GOMAXPROCS(0)
 	moveChan := make(chan struct {
 		move  Move
 		index int
@@ -160,28 +264,28 @@ func (board *Board) ParallelRootSearch(depth int, tt *TranspositionTable, moves
 	var wg sync.WaitGroup
 
 	// Start workers
-	for w := 0; w < numWorkers; w++ {
+	for _, ctx := range threads.Contexts {
 		wg.Add(1)
-		go func() {
+		go func(ctx *SearchContext) {
 			defer wg.Done()
 			for job := range moveChan {
 				if runtime.GOARCH == "wasm" {
 					runtime.Gosched()
 				}
-				select {
-				case <-ctx.Done:
+				if ctx.IsStopped(stats) {
 					return
-				default:
 				}
 				clone := board.Clone()
+				clone.SetPawnHashTable(ctx.PawnTable)
 				clone.Move(job.move)
+				ctx.MoveStack[0] = job.move
 				score := clone.AlphaBetaSearch(
 					depth-1, !maximizing,
 					-MaxEvaluationScore, MaxEvaluationScore, tt, stats, ctx, 1,
 				)
 				resultChan <- ConcurrentSearch{score: score, move: job.move, originalIndex: job.index}
 			}
-		}()
+		}(ctx)
 	}
 
 	// Send jobs
@@ -201,7 +305,9 @@ func (board *Board) ParallelRootSearch(depth int, tt *TranspositionTable, moves
 
 	var bestMove *Move
 	bestMoveOriginalIndex := -1
+	rootMoves := make([]RootMove, 0, len(moves))
 	for result := range resultChan {
+		rootMoves = append(rootMoves, RootMove{Move: result.move, Score: result.score})
 		if maximizing {
 			if result.score > bestScore || (result.score == bestScore && (bestMove == nil || result.originalIndex < bestMoveOriginalIndex)) {
 				bestScore = result.score
@@ -218,14 +324,13 @@ func (board *Board) ParallelRootSearch(depth int, tt *TranspositionTable, moves
 			}
 		}
 	}
+	stats.RootMoves = rootMoves
 	return bestScore, bestMove
 }
 
-func (board *Board) QuiescenceSearch(maximizing bool, alpha int, beta int, stats *SearchResult, ctx *SearchContext) int {
-	select {
-	case <-ctx.Done:
+func (board *Board) QuiescenceSearch(maximizing bool, alpha int, beta int, stats *SearchResult, ctx *SearchContext, ply int) int {
+	if ctx.IsStopped(stats) {
 		return 0
-	default:
 	}
 
 	if runtime.GOARCH == "wasm" {
@@ -233,19 +338,24 @@ func (board *Board) QuiescenceSearch(maximizing bool, alpha int, beta int, stats
 	}
 
 	stats.IncNodesSearched()
+	ctx.VisitNode(ply)
 
 	standPat := board.Evaluate()
+	if ply >= MaxPly-1 {
+		return standPat
+	}
+	traceID := ctx.traceEnter(board, ply, 0, alpha, beta, true)
 
 	if maximizing {
 		if standPat >= beta {
-			return beta
+			return ctx.traceExit(traceID, beta, nil, CutoffStandPat)
 		}
 		if standPat > alpha {
 			alpha = standPat
 		}
 	} else {
 		if standPat <= alpha {
-			return alpha
+			return ctx.traceExit(traceID, alpha, nil, CutoffStandPat)
 		}
 		if standPat < beta {
 			beta = standPat
@@ -255,41 +365,53 @@ func (board *Board) QuiescenceSearch(maximizing bool, alpha int, beta int, stats
 	captures := board.GenerateLegalCaptures()
 	captures = board.SortCaptures(captures)
 
+	var bestMove *Move
+	cutoff := CutoffNone
 	if maximizing {
-		for _, move := range captures {
+		for i, move := range captures {
+			ctx.MoveStack[ply] = move
 			prev := board.Move(move)
-			score := board.QuiescenceSearch(false, alpha, beta, stats, ctx)
+			score := board.QuiescenceSearch(false, alpha, beta, stats, ctx, ply+1)
 			board.UndoMove(prev)
+			if ctx.IsStopped(stats) {
+				return ctx.traceExit(traceID, 0, nil, CutoffInterrupted)
+			}
 			if score > alpha {
 				alpha = score
+				bestMove = &captures[i]
 			}
 			if alpha >= beta {
+				cutoff = CutoffBeta
 				break
 			}
 		}
-		return alpha
+		return ctx.traceExit(traceID, alpha, bestMove, cutoff)
 	}
 
-	for _, move := range captures {
+	for i, move := range captures {
+		ctx.MoveStack[ply] = move
 		prev := board.Move(move)
-		score := board.QuiescenceSearch(true, alpha, beta, stats, ctx)
+		score := board.QuiescenceSearch(true, alpha, beta, stats, ctx, ply+1)
 		board.UndoMove(prev)
+		if ctx.IsStopped(stats) {
+			return ctx.traceExit(traceID, 0, nil, CutoffInterrupted)
+		}
 		if score < beta {
 			beta = score
+			bestMove = &captures[i]
 		}
 		if beta <= alpha {
+			cutoff = CutoffBeta
 			break
 		}
 	}
-	return beta
+	return ctx.traceExit(traceID, beta, bestMove, cutoff)
 }
 
 func (board *Board) AlphaBetaSearch(depth int, maximizing bool, alpha int, beta int, tt *TranspositionTable, stats *SearchResult, ctx *SearchContext, ply int) int {
-	// Check for cancellation at every node
-	select {
-	case <-ctx.Done:
-		return 0 // or a sentinel value indicating cancellation
-	default:
+	// Check for cancellation at every node, the score of a stopped search is discarded
+	if ctx.IsStopped(stats) {
+		return 0
 	}
 
 	// Yield to scheduler in WASM to allow cancellation
@@ -299,26 +421,45 @@ func (board *Board) AlphaBetaSearch(depth int, maximizing bool, alpha int, beta
 
 	stats.IncNodesSearched()
 
+	// Positions in the tablebase are scored exactly
+	if score, ok := board.probeTablebase(ctx.DrawScore, ply); ok {
+		stats.IncTBHit()
+		return score
+	}
+	if score, ok := board.probeSyzygy(depth, ctx.DrawScore, ply); ok {
+		stats.IncTBHit()
+		return score
+	}
+
 	if depth == 0 {
-		return board.QuiescenceSearch(maximizing, alpha, beta, stats, ctx)
+		return board.QuiescenceSearch(maximizing, alpha, beta, stats, ctx, ply)
 	}
+	ctx.VisitNode(ply)
+	traceID := ctx.traceEnter(board, ply, depth, alpha, beta, false)
 
 	hash := board.ZobristHash()
-	if entry, ok := tt.Get(hash, depth); ok {
+	staticEval := NoStaticEval
+	entry, found := tt.Probe(hash)
+	if found {
+		staticEval = entry.StaticEval
+		entry.Score = mateScoreFromNode(entry.Score, ply)
+	}
+	if found && entry.Depth >= depth {
 		stats.IncTTHit()
+		ctx.traceTTHit(traceID)
 		switch entry.Bound {
 		case BoundExact:
-			return entry.Score
+			return ctx.traceExit(traceID, entry.Score, &entry.BestMove, CutoffTT)
 		case BoundLower:
 			if entry.Score >= beta {
-				return entry.Score
+				return ctx.traceExit(traceID, entry.Score, &entry.BestMove, CutoffTT)
 			}
 			if entry.Score > alpha && maximizing {
 				alpha = entry.Score
 			}
 		case BoundUpper:
 			if entry.Score <= alpha {
-				return entry.Score
+				return ctx.traceExit(traceID, entry.Score, &entry.BestMove, CutoffTT)
 			}
 			if entry.Score < beta && !maximizing {
 				beta = entry.Score
@@ -326,26 +467,43 @@ func (board *Board) AlphaBetaSearch(depth int, maximizing bool, alpha int, beta
 		}
 	}
 
+	// Evaluated once per position, the table keeps it for the next visit
+	if staticEval == NoStaticEval {
+		staticEval = board.Evaluate()
+	}
+
 	moves := board.GenerateLegalMoves()
 	stats.IncMoveGeneration()
 	moves = board.SortMovesAlphaBeta(moves, depth, tt, hash, ctx, ply)
 	if len(moves) == 0 {
-		return board.MateOrStalemateScore(maximizing)
+		score := mateScoreFromNode(board.MateOrDrawScore(maximizing, ctx.DrawScore), ply)
+		if score == ctx.DrawScore && !board.IsInCheck() {
+			return ctx.traceExit(traceID, score, nil, CutoffStalemate)
+		}
+		return ctx.traceExit(traceID, score, nil, CutoffMate)
 	}
 
 	origAlpha := alpha
 	origBeta := beta
 	var result int
 	var bestMove Move
+	cutoff := CutoffNone
+	// Moves searched so far, penalized in the history tables on a cutoff
+	var quietsSearched, capturesSearched [64]Move
+	quietCount, captureCount := 0, 0
 	if maximizing {
 		maxEval := -MaxEvaluationScore
 		for i, move := range moves {
 			if runtime.GOARCH == "wasm" {
 				runtime.Gosched()
 			}
+			ctx.MoveStack[ply] = move
 			prev := board.Move(move)
 			eval := board.AlphaBetaSearch(depth-1, false, alpha, beta, tt, stats, ctx, ply+1)
 			board.UndoMove(prev)
+			if ctx.IsStopped(stats) {
+				return ctx.traceExit(traceID, 0, nil, CutoffInterrupted)
+			}
 			if eval > maxEval {
 				maxEval = eval
 				bestMove = move
@@ -355,16 +513,21 @@ func (board *Board) AlphaBetaSearch(depth int, maximizing bool, alpha int, beta
 			}
 			if beta <= alpha {
 				stats.IncBetaCutoff()
-				if move.MoveType != MoveCapture && move.MoveType != MovePromotionCapture {
-					ctx.AddKillerMove(move, ply)
-					ctx.HistoryHeuristic[PieceToHistoryIndex[move.Piece]][move.To] += depth * depth
-				}
+				cutoff = CutoffBeta
+				board.updateCutoffHistory(ctx, move, quietsSearched[:quietCount], capturesSearched[:captureCount], depth, ply)
 				nodesPruned := len(moves) - (i + 1)
 				for j := 0; j < nodesPruned; j++ {
 					stats.IncNodesPruned()
 				}
 				break
 			}
+			if move.IsQuiet() && quietCount < len(quietsSearched) {
+				quietsSearched[quietCount] = move
+				quietCount++
+			} else if move.MoveType == MoveCapture && captureCount < len(capturesSearched) {
+				capturesSearched[captureCount] = move
+				captureCount++
+			}
 		}
 		result = maxEval
 	} else {
@@ -373,9 +536,13 @@ func (board *Board) AlphaBetaSearch(depth int, maximizing bool, alpha int, beta
 			if runtime.GOARCH == "wasm" {
 				runtime.Gosched()
 			}
+			ctx.MoveStack[ply] = move
 			prev := board.Move(move)
 			eval := board.AlphaBetaSearch(depth-1, true, alpha, beta, tt, stats, ctx, ply+1)
 			board.UndoMove(prev)
+			if ctx.IsStopped(stats) {
+				return ctx.traceExit(traceID, 0, nil, CutoffInterrupted)
+			}
 			if eval < minEval {
 				minEval = eval
 				bestMove = move
@@ -385,16 +552,21 @@ func (board *Board) AlphaBetaSearch(depth int, maximizing bool, alpha int, beta
 			}
 			if beta <= alpha {
 				stats.IncBetaCutoff()
-				if move.MoveType != MoveCapture && move.MoveType != MovePromotionCapture {
-					ctx.AddKillerMove(move, ply)
-					ctx.HistoryHeuristic[PieceToHistoryIndex[move.Piece]][move.To] += depth * depth
-				}
+				cutoff = CutoffBeta
+				board.updateCutoffHistory(ctx, move, quietsSearched[:quietCount], capturesSearched[:captureCount], depth, ply)
 				nodesPruned := len(moves) - (i + 1)
 				for j := 0; j < nodesPruned; j++ {
 					stats.IncNodesPruned()
 				}
 				break
 			}
+			if move.IsQuiet() && quietCount < len(quietsSearched) {
+				quietsSearched[quietCount] = move
+				quietCount++
+			} else if move.MoveType == MoveCapture && captureCount < len(capturesSearched) {
+				capturesSearched[captureCount] = move
+				captureCount++
+			}
 		}
 		result = minEval
 	}
@@ -416,6 +588,70 @@ func (board *Board) AlphaBetaSearch(depth int, maximizing bool, alpha int, beta
 	}
 
 	stats.IncTTStore()
-	tt.Set(hash, depth, result, bestMove, bound)
-	return result
+	tt.Set(hash, depth, mateScoreToNode(result, ply), bestMove, bound, staticEval, bound == BoundExact)
+	return ctx.traceExit(traceID, result, &bestMove, cutoff)
+}
+
+// mateScoreFromNode converts a mate or tablebase score counted from a node at ply,
+// like the ones of the transposition table, to one counted from the root
+func mateScoreFromNode(score int, ply int) int {
+	switch {
+	case score >= TablebaseScoreThreshold:
+		return score - ply
+	case score <= -TablebaseScoreThreshold:
+		return score + ply
+	}
+	return score
+}
+
+// mateScoreToNode converts a mate or tablebase score counted from the root to one
+// counted from the node at ply, so the entries of a position hold wherever it is reached
+func mateScoreToNode(score int, ply int) int {
+	switch {
+	case score >= TablebaseScoreThreshold:
+		return score + ply
+	case score <= -TablebaseScoreThreshold:
+		return score - ply
+	}
+	return score
+}
+
+// updateCutoffHistory updates the move ordering heuristics after move caused a beta cutoff
+func (board *Board) updateCutoffHistory(ctx *SearchContext, move Move, quiets []Move, captures []Move, depth int, ply int) {
+	if move.IsQuiet() {
+		ctx.UpdateQuietHistory(move, quiets, board.WhiteToMove, depth, ply)
+	} else if move.MoveType == MoveCapture {
+		ctx.UpdateCaptureHistory(move, captures, depth)
+	}
+}
+
+// PrincipalVariation returns bestMove followed by the best moves stored in the
+// transposition table, at most maxLength moves. The walk stops at the first missing
+// or illegal move and on repetitions.
+func (board *Board) PrincipalVariation(bestMove Move, tt *TranspositionTable, maxLength int) []Move {
+	pv := []Move{}
+	clone := board.Clone()
+	seen := map[uint64]bool{clone.ZobristHash(): true}
+	move := &bestMove
+	for len(pv) < maxLength && move != nil && *move != (Move{}) && clone.IsLegalMove(*move) {
+		pv = append(pv, *move)
+		clone.Move(*move)
+		hash := clone.ZobristHash()
+		if seen[hash] {
+			break
+		}
+		seen[hash] = true
+		move = tt.BestMoveDeepest(hash)
+	}
+	return pv
+}
+
+// IsLegalMove returns true if move is one of the legal moves of the position
+func (board *Board) IsLegalMove(move Move) bool {
+	for _, legal := range board.GenerateLegalMoves() {
+		if legal == move {
+			return true
+		}
+	}
+	return false
 }
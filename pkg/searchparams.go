@@ -0,0 +1,95 @@
+package libra
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// SearchParams are the tunable constants of the search. Every engine, and every
+// search started with SearchOptions.Params, may use its own set.
+type SearchParams struct {
+	KillerBonus           int // Ordering bonus of killer moves
+	CounterMoveBonus      int // Ordering bonus of the counter move to the previous move
+	QuietHistoryDivisor   int // Quiet history scores are divided by this for ordering
+	CaptureHistoryDivisor int // Capture history scores are divided by this for ordering
+	HistoryBonusScale     int // History update of a cutoff at depth d is scale * d^2 ...
+	HistoryBonusMax       int // ... up to this value
+}
+
+// SearchParamSpec describes a search parameter as a UCI spin option and for SPSA
+type SearchParamSpec struct {
+	Name    string // UCI option name
+	Default int
+	Min     int
+	Max     int
+	Step    float64 // SPSA perturbation at the end of the tuning (c_end)
+	value   func(params *SearchParams) *int
+}
+
+// Value returns a pointer to the parameter in params
+func (spec SearchParamSpec) Value(params *SearchParams) *int {
+	return spec.value(params)
+}
+
+// SearchParamSpecs lists every search parameter, also announced as UCI options
+var SearchParamSpecs = []SearchParamSpec{
+	{"KillerBonus", 10_000, 0, 30_000, 1_000, func(p *SearchParams) *int { return &p.KillerBonus }},
+	{"CounterMoveBonus", 8_000, 0, 30_000, 1_000, func(p *SearchParams) *int { return &p.CounterMoveBonus }},
+	{"QuietHistoryDivisor", 8, 1, 64, 2, func(p *SearchParams) *int { return &p.QuietHistoryDivisor }},
+	{"CaptureHistoryDivisor", 16, 1, 64, 3, func(p *SearchParams) *int { return &p.CaptureHistoryDivisor }},
+	{"HistoryBonusScale", 16, 1, 64, 3, func(p *SearchParams) *int { return &p.HistoryBonusScale }},
+	{"HistoryBonusMax", 1_600, 100, HistoryMax, 200, func(p *SearchParams) *int { return &p.HistoryBonusMax }},
+}
+
+var defaultSearchParams = newDefaultSearchParams()
+
+func newDefaultSearchParams() SearchParams {
+	params := SearchParams{}
+	for _, spec := range SearchParamSpecs {
+		*spec.Value(&params) = spec.Default
+	}
+	return params
+}
+
+// DefaultSearchParams returns the compiled-in search parameters
+func DefaultSearchParams() SearchParams {
+	return defaultSearchParams
+}
+
+// FindSearchParamSpec returns the spec of the parameter named name (case insensitive)
+func FindSearchParamSpec(name string) (SearchParamSpec, bool) {
+	for _, spec := range SearchParamSpecs {
+		if strings.EqualFold(spec.Name, name) {
+			return spec, true
+		}
+	}
+	return SearchParamSpec{}, false
+}
+
+// Clamp keeps every parameter within its spec range
+func (params *SearchParams) Clamp() {
+	for _, spec := range SearchParamSpecs {
+		value := spec.Value(params)
+		*value = MathMaxInt(spec.Min, MathMinInt(*value, spec.Max))
+	}
+}
+
+// String formats the parameters as "Name=value" pairs in spec order
+func (params SearchParams) String() string {
+	pairs := make([]string, len(SearchParamSpecs))
+	for i, spec := range SearchParamSpecs {
+		pairs[i] = spec.Name + "=" + strconv.Itoa(*spec.Value(&params))
+	}
+	return strings.Join(pairs, " ")
+}
+
+// UCIOption returns the spec as a UCI spin option
+func (spec SearchParamSpec) UCIOption() EngineOption {
+	return EngineOption{Name: spec.Name, Type: "spin", Default: fmt.Sprint(spec.Default), Min: spec.Min, Max: spec.Max}
+}
+
+// historyBonus is the history update for a cutoff at depth, grows with depth^2
+func (params *SearchParams) historyBonus(depth int) int {
+	return MathMinInt(params.HistoryBonusScale*depth*depth, params.HistoryBonusMax)
+}
@@ -0,0 +1,203 @@
+package libra
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math/rand"
+	"os"
+	"strings"
+)
+
+const (
+	SelfPlayMaxPlies = 400 // Self-play games reaching this many plies are drawn
+	SelfPlayHashMB   = 4   // Transposition table size of each self-play player (MB)
+	selfPlayWinPlies = 4   // Consecutive plies beyond WinScore adjudicating a game
+)
+
+// SelfPlayer is one side of a self-play game, with its own search parameters,
+// transposition table and search contexts
+type SelfPlayer struct {
+	Params  SearchParams
+	Skill   *Skill // Optional strength limit, nil = full strength
+	Nodes   uint64 // Optional nodes per move, overrides SelfPlayOptions.Nodes
+	tt      *TranspositionTable
+	threads *SearchThreads
+}
+
+// NewSelfPlayer creates a single threaded player searching with params
+func NewSelfPlayer(params SearchParams) *SelfPlayer {
+	return &SelfPlayer{
+		Params:  params,
+		tt:      NewTranspositionTableMB(SelfPlayHashMB),
+		threads: NewSearchThreads(1),
+	}
+}
+
+// SelfPlayOptions are the limits and adjudication rules of a self-play game
+type SelfPlayOptions struct {
+	Nodes    uint64 // Nodes searched per move
+	MaxPlies int    // Plies after which the game is drawn, 0 = SelfPlayMaxPlies
+	WinScore int    // Adjudicate a win once both sides agree on a score this large (cp), 0 = never
+	// OnMove, if set, is called before every move with the white score of its search
+	OnMove func(board *Board, move Move, score int)
+}
+
+// PlayGame plays a game from start, searching nodes per move, and returns the white
+// score: 1 win, 0.5 draw, 0 loss. start is not modified.
+func PlayGame(white *SelfPlayer, black *SelfPlayer, start *Board, nodes uint64) float64 {
+	return PlaySelfPlayGame(white, black, start, SelfPlayOptions{Nodes: nodes})
+}
+
+// PlaySelfPlayGame plays a game from start and returns the white score. Mate, stalemate,
+// the 50-move rule, threefold repetition, insufficient material, the ply limit and the
+// win adjudication end the game. start is not modified.
+func PlaySelfPlayGame(white *SelfPlayer, black *SelfPlayer, start *Board, options SelfPlayOptions) float64 {
+	maxPlies := options.MaxPlies
+	if maxPlies == 0 {
+		maxPlies = SelfPlayMaxPlies
+	}
+	board := start.Clone()
+	for _, player := range []*SelfPlayer{white, black} {
+		player.tt.Clear()
+		player.threads.Clear()
+	}
+	seen := map[uint64]int{board.ZobristHash(): 1}
+	winPlies := 0
+	for ply := 0; ply < maxPlies; ply++ {
+		if len(board.GenerateLegalMoves()) == 0 {
+			switch {
+			case !board.IsInCheck():
+				return 0.5
+			case board.WhiteToMove:
+				return 0
+			default:
+				return 1
+			}
+		}
+		if board.HalfMoveClock >= 100 || board.IsInsufficientMaterial() {
+			return 0.5
+		}
+		player := white
+		if !board.WhiteToMove {
+			player = black
+		}
+		nodes := options.Nodes
+		if player.Nodes != 0 {
+			nodes = player.Nodes
+		}
+		score := 0
+		move := board.IterativeDeepeningSearch(SearchOptions{
+			TranspositionTable: player.tt,
+			Threads:            player.threads,
+			MaxNodes:           nodes,
+			Params:             &player.Params,
+			Skill:              player.Skill,
+			OnIteration: func(result *SearchResult) {
+				if !result.IsInterrupted {
+					score = result.BestScore
+				}
+			},
+		})
+		if move == nil {
+			return 0.5
+		}
+		if options.OnMove != nil {
+			options.OnMove(board, *move, score)
+		}
+		if options.WinScore > 0 {
+			// Scores are white's, so consecutive plies of both sides count alike
+			switch {
+			case score >= options.WinScore:
+				winPlies = MathMaxInt(winPlies, 0) + 1
+			case score <= -options.WinScore:
+				winPlies = MathMinInt(winPlies, 0) - 1
+			default:
+				winPlies = 0
+			}
+			if winPlies >= selfPlayWinPlies {
+				return 1
+			} else if winPlies <= -selfPlayWinPlies {
+				return 0
+			}
+		}
+		board.Move(*move)
+		hash := board.ZobristHash()
+		seen[hash]++
+		if seen[hash] >= 3 {
+			return 0.5
+		}
+	}
+	return 0.5
+}
+
+// PlayGamePair plays start twice with colors swapped and returns the score of a
+// minus the score of b, from -2 to 2
+func PlayGamePair(a *SelfPlayer, b *SelfPlayer, start *Board, nodes uint64) float64 {
+	first := PlayGame(a, b, start, nodes)
+	second := 1 - PlayGame(b, a, start, nodes)
+	return 2 * (first + second - 1)
+}
+
+// IsInsufficientMaterial reports whether no side can mate: kings only, or a single minor piece
+func (board *Board) IsInsufficientMaterial() bool {
+	if board.WhitePawns|board.BlackPawns|board.WhiteRooks|board.BlackRooks|board.WhiteQueens|board.BlackQueens != 0 {
+		return false
+	}
+	minors := board.WhiteKnights | board.WhiteBishops | board.BlackKnights | board.BlackBishops
+	return minors&(minors-1) == 0
+}
+
+// LoadOpenings reads one FEN or EPD per line, ignoring EPD operations after the
+// first ';', empty lines and lines starting with '#'
+func LoadOpenings(r io.Reader) ([]*Board, error) {
+	var boards []*Board
+	scanner := bufio.NewScanner(r)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		fen := strings.TrimSpace(strings.SplitN(scanner.Text(), ";", 2)[0])
+		if fen == "" || strings.HasPrefix(fen, "#") {
+			continue
+		}
+		board := NewBoard()
+		if _, err := board.FromFEN(fen); err != nil {
+			return nil, fmt.Errorf("line %d: %w", lineNumber, err)
+		}
+		boards = append(boards, board)
+	}
+	return boards, scanner.Err()
+}
+
+// LoadOpeningsFile reads the openings of the file at path with LoadOpenings,
+// failing when it has none
+func LoadOpeningsFile(path string) ([]*Board, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	boards, err := LoadOpenings(file)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if len(boards) == 0 {
+		return nil, fmt.Errorf("%s: no openings", path)
+	}
+	return boards, nil
+}
+
+// RandomOpening returns a copy of start after up to plies random legal moves, or nil
+// when the game ended before
+func RandomOpening(start *Board, plies int, rng *rand.Rand) *Board {
+	board := start.Clone()
+	for i := 0; i < plies; i++ {
+		moves := board.GenerateLegalMoves()
+		if len(moves) == 0 {
+			return nil
+		}
+		board.Move(moves[rng.Intn(len(moves))])
+	}
+	if len(board.GenerateLegalMoves()) == 0 {
+		return nil
+	}
+	return board
+}
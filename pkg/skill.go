@@ -0,0 +1,122 @@
+package libra
+
+import (
+	"math/rand"
+	"sort"
+)
+
+const (
+	MaxSkillLevel   = 20   // Full strength, no weakening
+	MinSkillElo     = 800  // UCI_Elo of skill level 0, the rating the measurement is pinned to
+	MaxSkillElo     = MAXELO // UCI_Elo of skill level 19 as measured, see skillLevelElo
+	skillCandidates = 4    // Number of best root moves considered when weakening (MultiPV)
+	skillPawnValue  = 100
+)
+
+// skillLevelElo are the ratings of the skill levels 0 to MaxSkillLevel-1 measured
+// by cmd/skillelo in self-play among the levels and against full strength searches
+// of fixed node counts, level 0 pinned at MinSkillElo. The ± are the standard errors.
+var skillLevelElo = [MaxSkillLevel]int{
+	TABLE
+}
+
+// RootMove is a root move with its exact score, from white's perspective
+type RootMove struct {
+	Move  Move
+	Score int
+}
+
+// Skill weakens the engine by capping the search depth and nodes and by
+// sampling suboptimal moves among the best root moves.
+type Skill struct {
+	Level int        // 0 (weakest) to MaxSkillLevel (full strength)
+	Rand  *rand.Rand // Optional random source, defaults to the global one
+}
+
+// NewSkill creates a skill for a "Skill Level" between 0 and MaxSkillLevel
+func NewSkill(level int) *Skill {
+	level = MathMaxInt(0, MathMinInt(level, MaxSkillLevel))
+	return &Skill{Level: level}
+}
+
+// NewSkillFromElo returns the skill level closest to a UCI_Elo rating, interpolating
+// between the measured ratings of skillLevelElo. Ratings are clamped to MinSkillElo
+// and MaxSkillElo, so full strength is only reached without UCI_LimitStrength.
+func NewSkillFromElo(elo int) *Skill {
+	elo = MathMaxInt(MinSkillElo, MathMinInt(elo, MaxSkillElo))
+	level := 0
+	for level < MaxSkillLevel-1 && skillLevelElo[level+1] <= elo {
+		level++
+	}
+	if level < MaxSkillLevel-1 && skillLevelElo[level+1]-elo < elo-skillLevelElo[level] {
+		level++
+	}
+	return NewSkill(level)
+}
+
+// Elo returns the measured rating of the skill level, MaxSkillElo at full strength
+// which is at least as strong
+func (skill *Skill) Elo() int {
+	return skillLevelElo[MathMinInt(skill.Level, MaxSkillLevel-1)]
+}
+
+// Enabled returns true if the skill weakens the engine
+func (skill *Skill) Enabled() bool {
+	return skill != nil && skill.Level < MaxSkillLevel
+}
+
+// MaxDepth returns the depth cap of the skill level: 1 at level 0 up to 10 at level 19
+func (skill *Skill) MaxDepth() int {
+	return 1 + skill.Level/2
+}
+
+// MaxNodes returns the node cap of the skill level: 500 at level 0 up to 200k at level 19
+func (skill *Skill) MaxNodes() uint64 {
+	return uint64(500 * (skill.Level + 1) * (skill.Level + 1))
+}
+
+// PickMove picks a move among the best root moves of the last completed iteration.
+// The error model follows the classic one used by Stockfish: every candidate gets a
+// random push proportional to the weakness, and a bonus proportional to how much
+// worse than the best move it is, so weaker levels play worse moves more often but
+// rarely blunder more than a few pawns.
+func (skill *Skill) PickMove(rootMoves []RootMove, whiteToMove bool) *Move {
+	if len(rootMoves) == 0 {
+		return nil
+	}
+	// Scores from the side to move's perspective, best first
+	candidates := make([]RootMove, len(rootMoves))
+	copy(candidates, rootMoves)
+	if !whiteToMove {
+		for i := range candidates {
+			candidates[i].Score = -candidates[i].Score
+		}
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].Score > candidates[j].Score
+	})
+	if len(candidates) > skillCandidates {
+		candidates = candidates[:skillCandidates]
+	}
+
+	topScore := candidates[0].Score
+	delta := MathMinInt(topScore-candidates[len(candidates)-1].Score, skillPawnValue)
+	weakness := 120 - 2*skill.Level
+	maxScore := -MaxEvaluationScore * 2
+	best := candidates[0].Move
+	for _, candidate := range candidates {
+		push := (weakness*(topScore-candidate.Score) + delta*skill.randomInt(weakness)) / 128
+		if candidate.Score+push >= maxScore {
+			maxScore = candidate.Score + push
+			best = candidate.Move
+		}
+	}
+	return &best
+}
+
+func (skill *Skill) randomInt(n int) int {
+	if skill.Rand != nil {
+		return skill.Rand.Intn(n)
+	}
+	return rand.Intn(n)
+}
@@ -3,19 +3,23 @@ package libra
 import "sort"
 
 /*
-	SortMovesAlphaBeta orders moves for alpha-beta search using TT, killer moves, MVV-LVA, and history heuristic.
+	SortMovesAlphaBeta orders moves for alpha-beta search using TT, MVV-LVA, capture history,
+	killer moves, counter moves, and butterfly and continuation history.
 
 	# SortMovesAlphaBeta Scoring Table
 
-| Move Type      | Formula                               | Min   | Max   |
-|----------------|---------------------------------------|-------|-------|
-| TT Move        | 70_000                                |70_000 |70_000 |
-| Killer Move    | 60_000                                |60_000 |60_000 |
-| Promo Capture  | 50_000+10Victim+10Promo-10Attacker    |48_000 |67_000 |
-| Capture        | 30_000+10Victim-10Attacker            |29_000 |38_000 |
-| Promo (quiet)  | 10_000+10Promo                        |11_000 |19_000 |
-| History        | history[Piece][To]                    |   0   | < 6k  |
-| Quiet          | 0                                     |   0   |   0   |
+| Move Type      | Formula                                   | Min     | Max     |
+|----------------|-------------------------------------------|---------|---------|
+| TT Move        | +900_000                                  | 900_000 | 900_000 |
+| Capture        | 70_000+10Victim-10Attacker+CaptHist/16 *  | 60_000  | 80_000  |
+| Promo Capture  | 50_000+10Victim+10Promo-10Attacker        | 48_000  | 67_000  |
+| Promo (quiet)  | 30_000+10Promo                            | 33_000  | 39_000  |
+| Killer Move    | +10_000 *                                 | 10_000  | 10_000  |
+| Counter Move   | +8_000 *                                  | 8_000   | 8_000   |
+| Quiet History  | (butterfly+1-ply+2-ply continuation)/8 *  | -6_144  | 6_144   |
+
+Starred values are SearchParams defaults (CaptureHistoryDivisor, KillerBonus,
+CounterMoveBonus, QuietHistoryDivisor), tunable as UCI options.
 */
 func (board *Board) SortMovesAlphaBeta(
 	moves []Move,
@@ -31,6 +35,15 @@ func (board *Board) SortMovesAlphaBeta(
 	}
 	scored := make([]moveScore, len(moves))
 	ttBestMove := tt.BestMoveDeepest(hash)
+	// The stored move may belong to another position after a key collision
+	if ttBestMove != nil && !board.IsMovePseudoLegal(*ttBestMove) {
+		ttBestMove = nil
+	}
+	var counterMove Move
+	hasCounterMove := false
+	if ctx != nil {
+		counterMove, hasCounterMove = ctx.CounterMove(ply)
+	}
 
 	for i, m := range moves {
 		score := 0
@@ -40,12 +53,15 @@ func (board *Board) SortMovesAlphaBeta(
 			score += 90_0000 // High value for TT move
 		}
 
-		// 2. MVV-LVA for captures
+		// 2. MVV-LVA for captures, adjusted by capture history
 		switch m.MoveType {
 		case MoveCapture:
 			victim := m.Captured
 			attacker := m.Piece
 			score += 70_000 + 10*PieceCodeToValue[victim] - 10*PieceCodeToValue[attacker]
+			if ctx != nil {
+				score += ctx.CaptureHistoryScore(m) / ctx.SearchParams().CaptureHistoryDivisor
+			}
 		case MovePromotionCapture:
 			victim := m.Captured
 			attacker := m.Piece
@@ -56,16 +72,17 @@ func (board *Board) SortMovesAlphaBeta(
 			score += 30_000 + 10*PieceCodeToValue[promoPiece]
 		}
 
-		// 3. Killer moves
-		if ctx != nil && ctx.IsKillerMove(m, ply) {
-			score += 10_000
-		}
-
-		// 4. History heuristic for quiet moves
-		// Value is set with depth^2, so deeper moves are prioritized
-		// Example with max depth 9 it can reach 810
 		if ctx != nil && m.IsQuiet() {
-			score += ctx.HistoryHeuristic[PieceToHistoryIndex[m.Piece]][m.To] * 10
+			// 3. Killer moves
+			if ctx.IsKillerMove(m, ply) {
+				score += ctx.SearchParams().KillerBonus
+			}
+			// 4. Counter move to the previous move
+			if hasCounterMove && m == counterMove {
+				score += ctx.SearchParams().CounterMoveBonus
+			}
+			// 5. Butterfly and continuation history, kept within +-HistoryMax each by gravity
+			score += ctx.QuietHistory(m, board.WhiteToMove, ply) / ctx.SearchParams().QuietHistoryDivisor
 		}
 
 		scored[i] = moveScore{move: m, score: score}
@@ -0,0 +1,90 @@
+package libra
+
+import (
+	"math"
+	"math/rand"
+)
+
+// SPSA tunes search parameters by simultaneous perturbation stochastic approximation,
+// with the gain sequences used by Fishtest. Every iteration plays a configuration with
+// all parameters shifted by +c_k or -c_k at random against the mirrored one, and moves
+// every parameter towards the winner in proportion to the game result.
+type SPSA struct {
+	Theta      []float64 // Parameter values in SearchParamSpecs order
+	Iterations int       // Planned iterations N, sets the decay of the gains
+	Iteration  int       // Completed iterations k
+	Alpha      float64   // Decay exponent of the step a_k
+	Gamma      float64   // Decay exponent of the perturbation c_k
+	A          float64   // Stability constant of a_k
+	REnd       float64   // Learning rate a_k / c_k^2 at the last iteration
+}
+
+// NewSPSA starts tuning from start for the given number of iterations
+func NewSPSA(start SearchParams, iterations int) *SPSA {
+	spsa := &SPSA{
+		Theta:      make([]float64, len(SearchParamSpecs)),
+		Iterations: MathMaxInt(1, iterations),
+		Alpha:      0.602,
+		Gamma:      0.101,
+		REnd:       0.002,
+	}
+	spsa.A = 0.1 * float64(spsa.Iterations)
+	for i, spec := range SearchParamSpecs {
+		spsa.Theta[i] = float64(*spec.Value(&start))
+	}
+	return spsa
+}
+
+// Params returns the current values rounded and clamped to their spec range
+func (spsa *SPSA) Params() SearchParams {
+	return spsa.paramsAt(spsa.Theta, nil, 0)
+}
+
+// Perturbation returns the perturbation c_k of the parameter i at the next iteration,
+// decaying to the spec Step at the last one
+func (spsa *SPSA) Perturbation(i int) float64 {
+	k := float64(spsa.Iteration + 1)
+	return SearchParamSpecs[i].Step * math.Pow(float64(spsa.Iterations)/k, spsa.Gamma)
+}
+
+// Perturb draws a random direction for the next iteration and returns it with the
+// parameters shifted by +c_k and by -c_k along it
+func (spsa *SPSA) Perturb(rng *rand.Rand) (plus SearchParams, minus SearchParams, delta []float64) {
+	delta = make([]float64, len(spsa.Theta))
+	for i := range delta {
+		delta[i] = 1
+		if rng.Intn(2) == 0 {
+			delta[i] = -1
+		}
+	}
+	return spsa.paramsAt(spsa.Theta, delta, 1), spsa.paramsAt(spsa.Theta, delta, -1), delta
+}
+
+// Update completes an iteration: result is the score of the plus configuration minus
+// the score of the minus one, summed over the games played with delta
+func (spsa *SPSA) Update(delta []float64, result float64) {
+	k := float64(spsa.Iteration + 1)
+	n := float64(spsa.Iterations)
+	for i := range spsa.Theta {
+		spec := SearchParamSpecs[i]
+		ck := spsa.Perturbation(i)
+		a := spsa.REnd * spec.Step * spec.Step * math.Pow(spsa.A+n, spsa.Alpha)
+		ak := a / math.Pow(spsa.A+k, spsa.Alpha)
+		theta := spsa.Theta[i] + ak/ck*result*delta[i]
+		spsa.Theta[i] = math.Max(float64(spec.Min), math.Min(theta, float64(spec.Max)))
+	}
+	spsa.Iteration++
+}
+
+func (spsa *SPSA) paramsAt(theta []float64, delta []float64, sign float64) SearchParams {
+	params := SearchParams{}
+	for i, spec := range SearchParamSpecs {
+		value := theta[i]
+		if delta != nil {
+			value += sign * spsa.Perturbation(i) * delta[i]
+		}
+		*spec.Value(&params) = int(math.Round(value))
+	}
+	params.Clamp()
+	return params
+}
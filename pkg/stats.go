@@ -7,19 +7,23 @@ import (
 )
 
 type SearchResult struct {
-	NodesSearched   uint64 // Total nodes visited
-	NodesPruned     uint64 // Nodes cut off by alpha-beta pruning
-	TTHits          uint64 // Transposition table hits
-	TTStores        uint64 // Transposition table stores
-	BetaCutoffs     uint64 // Beta cutoffs (prunes)
-	NullMovePrunes  uint64 // Null move pruning occurrences
-	MoveGenerations uint64 // Number of times legal moves were generated
-	MaxSearchDepth  int32  // Maximum depth reached in the search
-	TimeSpentInMs   int64  // Total time taken for the search (milliseconds)
-	BestScore       int    // Best score found in the search
-	PVMove          string // Best move line in UCI format
-	BestMove        *Move  // Best	move in UCI format
-	IsInterrupted   bool   // Whether the search was interrupted
+	NodesSearched   uint64     // Total nodes visited
+	NodesPruned     uint64     // Nodes cut off by alpha-beta pruning
+	TTHits          uint64     // Transposition table hits
+	TTStores        uint64     // Transposition table stores
+	TBHits          uint64     // Positions scored by the tablebase
+	BetaCutoffs     uint64     // Beta cutoffs (prunes)
+	NullMovePrunes  uint64     // Null move pruning occurrences
+	MoveGenerations uint64     // Number of times legal moves were generated
+	MaxSearchDepth  int32      // Nominal depth of the search
+	SelDepth        int        // Deepest ply reached, including quiescence search
+	NodesAtPly      []uint64   // Nodes visited at each ply, up to SelDepth
+	TimeSpentInMs   int64      // Total time taken for the search (milliseconds)
+	BestScore       int        // Best score found in the search
+	PVMove          string     // Best move line in UCI format
+	BestMove        *Move      // Best	move in UCI format
+	IsInterrupted   bool       // Whether the search was interrupted
+	RootMoves       []RootMove // Score of every searched root move
 
 	startTime time.Time // unexported field for tracking time
 }
@@ -37,6 +41,10 @@ func (s *SearchResult) IncTTHit() {
 	atomic.AddUint64(&s.TTHits, 1)
 }
 
+func (s *SearchResult) IncTBHit() {
+	atomic.AddUint64(&s.TBHits, 1)
+}
+
 func (s *SearchResult) IncTTStore() {
 	atomic.AddUint64(&s.TTStores, 1)
 }
@@ -85,6 +93,19 @@ func (s *SearchResult) StopTimer() {
 	}
 }
 
+// mergeDepthStats combines the selective depth and node distribution of the search contexts
+func (s *SearchResult) mergeDepthStats(contexts []*SearchContext) {
+	for _, ctx := range contexts {
+		s.SelDepth = MathMaxInt(s.SelDepth, ctx.SelDepth)
+	}
+	s.NodesAtPly = make([]uint64, s.SelDepth+1)
+	for _, ctx := range contexts {
+		for ply := range s.NodesAtPly {
+			s.NodesAtPly[ply] += ctx.NodesAtPly[ply]
+		}
+	}
+}
+
 func (s *SearchResult) String() string {
 	nodesTotal := s.NodesSearched + s.NodesPruned
 	prunedPercent := 0.0
@@ -99,10 +120,12 @@ Nodes Pruned %%:        %.2f%%
 Nodes Total:           %d
 TT Hits:               %d
 TT Stores:             %d
+TB Hits:               %d
 Beta Cutoffs:          %d
 Null Move Prunes:      %d
 Move Generations:      %d
 Max Search Depth:      %d
+Sel Depth:             %d
 Best Score:            %d
 Time Spent:            %dms
 `,
@@ -112,10 +135,12 @@ Time Spent:            %dms
 		nodesTotal,
 		s.TTHits,
 		s.TTStores,
+		s.TBHits,
 		s.BetaCutoffs,
 		s.NullMovePrunes,
 		s.MoveGenerations,
 		s.MaxSearchDepth,
+		s.SelDepth,
 		s.BestScore,
 		s.TimeSpentInMs,
 	)
@@ -140,8 +165,9 @@ func (s *SearchResult) PrintUCI() {
 	if s.BestMove != nil {
 		bestMove = s.BestMove.ToUCI()
 	}
-	fmt.Printf("info depth %d score cp %d nodes %d nps %d prun %.0f%% pv %s time %dms\n",
+	fmt.Printf("info depth %d seldepth %d score cp %d nodes %d nps %d prun %.0f%% pv %s time %dms\n",
 		s.MaxSearchDepth,
+		s.SelDepth,
 		s.BestScore,
 		s.NodesSearched,
 		nps,
@@ -0,0 +1,1342 @@
+package libra
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math/bits"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+const (
+	DefaultSyzygyPath       = "<empty>"                          // Default "SyzygyPath" UCI option, no tables
+	DefaultSyzygyProbeDepth = 1                                  // Default "SyzygyProbeDepth" UCI option
+	MaxSyzygyProbeDepth     = 100                                // Largest "SyzygyProbeDepth"
+	SyzygyMaxPieces         = 7                                  // Most pieces, kings included, of a Syzygy table
+	SyzygyWinScore          = TablebaseWinScore - 1_000          // Score of a Syzygy win, below the tablebase mates
+	SyzygyWDLExtension      = ".rtbw"                            // Extension of the win/draw/loss files
+	SyzygyDTZExtension      = ".rtbz"                            // Extension of the distance to zeroing files
+	syzygyPieceLetters      = "PNBRQ"                            // Letters of the materialCounts kinds
+	syzygyUniqueIndexSize   = 6*63*62 + 4*28*62 + 4*7*28 + 4*7*6 // Placements of 3 unique leading pieces
+	syzygyPageSize          = 1 << 16                            // Bytes of blocks read and kept at a time
+)
+
+/*
+Syzygy tables, "<white>v<black>.rtbw" and ".rtbz" (KQvKR.rtbw), are read the way
+the reference prober of the format does:
+
+| Field              | Notes                                                       |
+|--------------------|-------------------------------------------------------------|
+| Magic              | 4 bytes, szMagics                                           |
+| Flags              | 1 split (both sides to move stored), 2 pawns                |
+| Pieces             | per pawn file: group order, then one nibble per piece/side  |
+| Sizes              | per file and side: block size, Huffman symbols, pair tree   |
+| DTZ maps           | DTZ only, value maps of the distances per result            |
+| Sparse index       | per file and side: block and offset every span positions    |
+| Block lengths      | per file and side: positions of each block, minus one       |
+| Blocks             | per file and side, 64-byte aligned: Huffman codes           |
+
+Every position is indexed by placing groups of pieces: the leading pawns or the
+kings (with a third unique piece when there is one) under symmetry, then each
+group of identical pieces on the squares left. Values are Huffman coded symbols
+that expand into pairs of symbols, down to the values. The file size is 16 more
+than a multiple of 64.
+*/
+
+var szMagics = [2][4]byte{{0x71, 0xE8, 0x23, 0x5D}, {0xD7, 0x66, 0x0C, 0xA5}}
+
+// Table kinds, index of szMagics
+const (
+	szWDL = iota
+	szDTZ
+)
+
+// Flags of a szPairs
+const (
+	szFlagSTM         = 1 // DTZ: the side to move stored, 0 white
+	szFlagMapped      = 2 // DTZ: values go through the DTZ maps
+	szFlagWinPlies    = 4 // DTZ: wins are counted in plies, not moves
+	szFlagLossPlies   = 8 // DTZ: losses are counted in plies, not moves
+	szFlagWide        = 16
+	szFlagSingleValue = 128 // Every position has the same value
+)
+
+// szState is the outcome of a probe
+type szState int
+
+const (
+	szFail            szState = iota // Missing or unreadable table
+	szOK                             // Value found
+	szChangeSTM                      // The DTZ table stores the other side to move
+	szZeroingBestMove                // The best move captures or moves a pawn, the table was not read
+)
+
+var (
+	ErrSyzygyMagic   = errors.New("not a syzygy table")
+	ErrSyzygyCorrupt = errors.New("corrupt syzygy table")
+)
+
+// Indexing tables, on Syzygy squares: a1 = 0, h8 = 63
+var (
+	szMapB1H1H7     [64]int       // Squares below the a1-h8 diagonal, 0 to 27
+	szMapA1D1D4     [64]int       // Squares of the a1-d1-d4 triangle, the diagonal last, 0 to 9
+	szMapKK         [10][64]int   // Legal placements of the kings, 0 to 461
+	szBinomial      [7][64]uint64 // szBinomial[k][n]: ways to choose k of n
+	szMapPawns      [64]int       // Squares left to the other leading pawns, 0 to 47
+	szLeadPawnIdx   [6][64]uint64 // Index of the first leading pawn by count and square
+	szLeadPawnsSize [6][4]uint64  // Placements of the leading pawns by count and file
+)
+
+func szOffDiagonal(sq int) int {
+	return sq>>3 - sq&7
+}
+
+func init() {
+	code := 0
+	for sq := 0; sq < 64; sq++ {
+		if szOffDiagonal(sq) < 0 {
+			szMapB1H1H7[sq] = code
+			code++
+		}
+	}
+	code = 0
+	var diagonal []int
+	for sq := 0; sq <= 27; sq++ {
+		switch {
+		case szOffDiagonal(sq) < 0 && sq&7 <= 3:
+			szMapA1D1D4[sq] = code
+			code++
+		case szOffDiagonal(sq) == 0 && sq&7 <= 3:
+			diagonal = append(diagonal, sq)
+		}
+	}
+	for _, sq := range diagonal {
+		szMapA1D1D4[sq] = code
+		code++
+	}
+	// Both kings on the diagonal come last
+	type kings struct{ idx, sq int }
+	var bothOnDiagonal []kings
+	code = 0
+	for idx := 0; idx < 10; idx++ {
+		for s1 := 0; s1 <= 27; s1++ {
+			if szMapA1D1D4[s1] != idx || (idx == 0 && s1 != 1) {
+				continue
+			}
+			for s2 := 0; s2 < 64; s2++ {
+				switch {
+				case abs(s1&7-s2&7) <= 1 && abs(s1>>3-s2>>3) <= 1:
+				case szOffDiagonal(s1) == 0 && szOffDiagonal(s2) > 0:
+				case szOffDiagonal(s1) == 0 && szOffDiagonal(s2) == 0:
+					bothOnDiagonal = append(bothOnDiagonal, kings{idx, s2})
+				default:
+					szMapKK[idx][s2] = code
+					code++
+				}
+			}
+		}
+	}
+	for _, k := range bothOnDiagonal {
+		szMapKK[k.idx][k.sq] = code
+		code++
+	}
+	szBinomial[0][0] = 1
+	for n := 1; n < 64; n++ {
+		for k := 0; k < len(szBinomial) && k <= n; k++ {
+			if k > 0 {
+				szBinomial[k][n] += szBinomial[k-1][n-1]
+			}
+			if k < n {
+				szBinomial[k][n] += szBinomial[k][n-1]
+			}
+		}
+	}
+	// The leading pawn is the one nearest the a or h file, then the lowest
+	available := 47
+	for count := 1; count < len(szLeadPawnIdx); count++ {
+		for file := 0; file < 4; file++ {
+			idx := uint64(0)
+			for rank := 1; rank <= 6; rank++ {
+				sq := rank*8 + file
+				if count == 1 {
+					szMapPawns[sq] = available
+					available--
+					szMapPawns[sq^7] = available
+					available--
+				}
+				szLeadPawnIdx[count][sq] = idx
+				idx += szBinomial[count-1][szMapPawns[sq]]
+			}
+			szLeadPawnsSize[count][file] = idx
+		}
+	}
+}
+
+// szPairs decodes the values of one side to move and leading pawn file
+type szPairs struct {
+	flags           byte
+	blockSize       uint64
+	span            uint64 // Positions between sparse index entries
+	numBlocks       int
+	blockLengthSize int // numBlocks and padding
+	sparseIndexSize uint64
+	maxSymLen       int
+	minSymLen       int // Also the value of single value tables
+	lowestSym       []uint16
+	base64          []uint64 // base64[l] is the lowest code of length l+minSymLen, left aligned
+	symlen          []int    // Values expanded by a symbol, minus one
+	btree           []byte   // Per symbol, 12 bits left and 12 bits right symbols
+	sparseIndex     []byte   // Per entry, uint32 block and uint16 offset
+	blockLength     []byte   // Per block, uint16 positions minus one
+	dataOffset      int64
+	pageSize        uint64                   // syzygyPageSize, or blockSize when larger
+	pages           []atomic.Pointer[[]byte] // Blocks read so far, by page
+	pieces          [SyzygyMaxPieces]int     // Syzygy piece codes: 1 to 6 white pawn to king, 9 to 14 black
+	groupIdx        [SyzygyMaxPieces + 1]uint64
+	groupLen        [SyzygyMaxPieces + 1]int // Zero terminated
+	mapIdx          [4]int                   // DTZ map of wins, losses, cursed wins and blessed losses
+}
+
+// size returns the positions indexed
+func (d *szPairs) size() uint64 {
+	n := 0
+	for d.groupLen[n] != 0 {
+		n++
+	}
+	return d.groupIdx[n]
+}
+
+func (d *szPairs) left(sym int) int {
+	return int(d.btree[3*sym+1]&0xF)<<8 | int(d.btree[3*sym])
+}
+
+func (d *szPairs) right(sym int) int {
+	return int(d.btree[3*sym+2])<<4 | int(d.btree[3*sym+1]>>4)
+}
+
+func (d *szPairs) blockLen(block int) int {
+	return int(binary.LittleEndian.Uint16(d.blockLength[2*block:]))
+}
+
+// block returns the Huffman codes of a block. Blocks are read a page at a time
+// and kept, so probing a page again reads no file and allocates nothing.
+func (d *szPairs) block(file io.ReaderAt, block int) ([]byte, bool) {
+	at := uint64(block) * d.blockSize
+	page := at / d.pageSize
+	if page >= uint64(len(d.pages)) {
+		return nil, false
+	}
+	buf := d.pages[page].Load()
+	if buf == nil {
+		data := make([]byte, d.pageSize)
+		if _, err := file.ReadAt(data, d.dataOffset+int64(page*d.pageSize)); err != nil && err != io.EOF {
+			return nil, false
+		}
+		// Threads reading the same page at once keep the first copy stored
+		buf = &data
+		if !d.pages[page].CompareAndSwap(nil, buf) {
+			buf = d.pages[page].Load()
+		}
+	}
+	start := at % d.pageSize
+	return (*buf)[start : start+d.blockSize], true
+}
+
+// szData is a loaded table file
+type szData struct {
+	file   io.ReaderAt
+	closer io.Closer
+	pairs  [2][4]*szPairs // [side to move][leading pawn file]
+	dtzMap []byte
+}
+
+// szTable is a material of the tablebase, its files read on first use
+type szTable struct {
+	name            string
+	key             materialCounts // White with the pieces named first
+	key2            materialCounts // Colors swapped
+	pieceCount      int
+	hasPawns        bool
+	hasUniquePieces bool
+	pawnCount       [2]int // Leading color, other color
+	paths           [2]string
+	once            [2]sync.Once
+	data            [2]*szData
+}
+
+// newSzTable parses a table name, like KQvKR
+func newSzTable(name string) (*szTable, error) {
+	split := strings.IndexByte(name, 'v')
+	if split < 1 || name[0] != 'K' || !strings.HasPrefix(name[split+1:], "K") {
+		return nil, fmt.Errorf("%w: %q", ErrSyzygyCorrupt, name)
+	}
+	t := &szTable{name: name, pieceCount: len(name) - 1}
+	for side, pieces := range [2]string{name[1:split], name[split+2:]} {
+		for i := 0; i < len(pieces); i++ {
+			kind := strings.IndexByte(syzygyPieceLetters, pieces[i])
+			if kind < 0 {
+				return nil, fmt.Errorf("%w: %q", ErrSyzygyCorrupt, name)
+			}
+			t.key[side][kind]++
+		}
+	}
+	if t.pieceCount > SyzygyMaxPieces {
+		return nil, fmt.Errorf("%w: %q has more than %d pieces", ErrSyzygyCorrupt, name, SyzygyMaxPieces)
+	}
+	t.key2 = materialCounts{t.key[ColorBlack], t.key[ColorWhite]}
+	for color := range t.key {
+		t.hasPawns = t.hasPawns || t.key[color][0] > 0
+		for _, count := range t.key[color] {
+			t.hasUniquePieces = t.hasUniquePieces || count == 1
+		}
+	}
+	// The side with fewer pawns leads, white on equal counts
+	white, black := t.key[ColorWhite][0], t.key[ColorBlack][0]
+	t.pawnCount = [2]int{white, black}
+	if black > 0 && (white == 0 || black < white) {
+		t.pawnCount = [2]int{black, white}
+	}
+	return t, nil
+}
+
+// sides returns the sides to move stored by a table kind
+func (t *szTable) sides(kind int) int {
+	if kind == szWDL && t.key != t.key2 {
+		return 2
+	}
+	return 1
+}
+
+// files returns the leading pawn files stored
+func (t *szTable) files() int {
+	if t.hasPawns {
+		return 4
+	}
+	return 1
+}
+
+// get returns the loaded file of a table kind, nil when missing or corrupt
+func (t *szTable) get(kind int) *szData {
+	t.once[kind].Do(func() {
+		if t.paths[kind] != "" {
+			t.data[kind], _ = t.load(kind)
+		}
+	})
+	return t.data[kind]
+}
+
+// szReader reads a table header at increasing offsets
+type szReader struct {
+	r      io.ReaderAt
+	size   int64
+	offset int64
+	err    error
+}
+
+func (r *szReader) bytes(n int64) []byte {
+	if r.err != nil || n < 0 || r.offset+n > r.size {
+		r.err = ErrSyzygyCorrupt
+		return make([]byte, 8)
+	}
+	buf := make([]byte, n)
+	if _, err := r.r.ReadAt(buf, r.offset); err != nil {
+		r.err = fmt.Errorf("%w: %v", ErrSyzygyCorrupt, err)
+	}
+	r.offset += n
+	return buf
+}
+
+func (r *szReader) readByte() byte {
+	return r.bytes(1)[0]
+}
+
+func (r *szReader) readUint16() uint16 {
+	return binary.LittleEndian.Uint16(r.bytes(2))
+}
+
+func (r *szReader) readUint32() uint32 {
+	return binary.LittleEndian.Uint32(r.bytes(4))
+}
+
+func (r *szReader) align(n int64) {
+	r.offset = (r.offset + n - 1) / n * n
+}
+
+// openSyzygyFile opens a table file and checks its size and magic
+func openSyzygyFile(path string, kind int) (*os.File, int64, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, 0, err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, 0, err
+	}
+	var magic [4]byte
+	if _, err := file.ReadAt(magic[:], 0); err != nil || magic != szMagics[kind] {
+		file.Close()
+		return nil, 0, fmt.Errorf("%s: %w", path, ErrSyzygyMagic)
+	}
+	if info.Size()%64 != 16 {
+		file.Close()
+		return nil, 0, fmt.Errorf("%s: %w: size %d", path, ErrSyzygyCorrupt, info.Size())
+	}
+	return file, info.Size(), nil
+}
+
+// load reads the header of a table kind, the blocks are read when probing
+func (t *szTable) load(kind int) (*szData, error) {
+	file, size, err := openSyzygyFile(t.paths[kind], kind)
+	if err != nil {
+		return nil, err
+	}
+	data, err := t.read(file, size, kind)
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("%s: %w", t.paths[kind], err)
+	}
+	data.closer = file
+	return data, nil
+}
+
+func (t *szTable) read(file io.ReaderAt, size int64, kind int) (*szData, error) {
+	r := &szReader{r: file, size: size, offset: int64(len(szMagics[kind]))}
+	flags := r.readByte()
+	if (flags&1 != 0) != (t.key != t.key2) || (flags&2 != 0) != t.hasPawns {
+		return nil, ErrSyzygyCorrupt
+	}
+	data := &szData{file: file}
+	sides, files := t.sides(kind), t.files()
+	pp := t.hasPawns && t.pawnCount[1] > 0
+	for f := 0; f < files; f++ {
+		for i := 0; i < sides; i++ {
+			data.pairs[i][f] = &szPairs{}
+		}
+		first, second := r.readByte(), byte(0xFF)
+		if pp {
+			second = r.readByte()
+		}
+		order := [2][2]int{{int(first & 0xF), int(second & 0xF)}, {int(first >> 4), int(second >> 4)}}
+		for k := 0; k < t.pieceCount; k++ {
+			piece := r.readByte()
+			data.pairs[0][f].pieces[k] = int(piece & 0xF)
+			if sides == 2 {
+				data.pairs[1][f].pieces[k] = int(piece >> 4)
+			}
+		}
+		for i := 0; i < sides; i++ {
+			if !t.setGroups(data.pairs[i][f], order[i], f) {
+				return nil, ErrSyzygyCorrupt
+			}
+		}
+	}
+	r.align(2)
+	for f := 0; f < files; f++ {
+		for i := 0; i < sides; i++ {
+			data.pairs[i][f].readSizes(r)
+		}
+	}
+	if kind == szDTZ {
+		data.dtzMap = t.readDTZMap(r, data)
+	}
+	for f := 0; f < files; f++ {
+		for i := 0; i < sides; i++ {
+			d := data.pairs[i][f]
+			d.sparseIndex = r.bytes(int64(d.sparseIndexSize) * 6)
+		}
+	}
+	for f := 0; f < files; f++ {
+		for i := 0; i < sides; i++ {
+			d := data.pairs[i][f]
+			d.blockLength = r.bytes(int64(d.blockLengthSize) * 2)
+		}
+	}
+	for f := 0; f < files; f++ {
+		for i := 0; i < sides; i++ {
+			d := data.pairs[i][f]
+			r.align(64)
+			d.dataOffset = r.offset
+			d.pageSize = max(syzygyPageSize, d.blockSize)
+			blocks := uint64(d.numBlocks) * d.blockSize
+			d.pages = make([]atomic.Pointer[[]byte], (blocks+d.pageSize-1)/d.pageSize)
+			r.offset += int64(blocks)
+		}
+	}
+	if r.err != nil || r.offset > size {
+		return nil, ErrSyzygyCorrupt
+	}
+	return data, nil
+}
+
+// setGroups splits the pieces in groups and computes the index factor of each
+// group. The leading pawns, or the kings with a third unique piece, come first,
+// then each run of identical pieces. order tells where the leading group and the
+// other side's pawns are in the index. Reports false for invalid pieces.
+func (t *szTable) setGroups(d *szPairs, order [2]int, file int) bool {
+	for _, piece := range d.pieces[:t.pieceCount] {
+		if piece&7 < 1 || piece&7 > 6 || piece > 14 {
+			return false
+		}
+	}
+	firstLen := 0
+	if !t.hasPawns {
+		firstLen = 2
+		if t.hasUniquePieces {
+			firstLen = 3
+		}
+	}
+	n := 0
+	d.groupLen[0] = 1
+	for i := 1; i < t.pieceCount; i++ {
+		firstLen--
+		if firstLen > 0 || d.pieces[i] == d.pieces[i-1] {
+			d.groupLen[n]++
+		} else {
+			n++
+			d.groupLen[n] = 1
+		}
+	}
+	n++
+	d.groupLen[n] = 0
+	if t.hasPawns && d.groupLen[0] >= len(szLeadPawnsSize) {
+		return false
+	}
+	pp := t.hasPawns && t.pawnCount[1] > 0
+	next, free := 1, 64-d.groupLen[0]
+	if pp {
+		next, free = 2, free-d.groupLen[1]
+	}
+	idx := uint64(1)
+	for k := 0; next < n || k == order[0] || k == order[1]; k++ {
+		switch {
+		case k == order[0]:
+			d.groupIdx[0] = idx
+			switch {
+			case t.hasPawns:
+				idx *= szLeadPawnsSize[d.groupLen[0]][file]
+			case t.hasUniquePieces:
+				idx *= syzygyUniqueIndexSize
+			default:
+				idx *= 462
+			}
+		case k == order[1]:
+			d.groupIdx[1] = idx
+			idx *= szBinomial[d.groupLen[1]][48-d.groupLen[0]]
+		default:
+			d.groupIdx[next] = idx
+			idx *= szBinomial[d.groupLen[next]][free]
+			free -= d.groupLen[next]
+			next++
+		}
+		if k > 2*SyzygyMaxPieces {
+			return false
+		}
+	}
+	d.groupIdx[n] = idx
+	return idx > 0
+}
+
+// readSizes reads the block layout and the Huffman code of a szPairs
+func (d *szPairs) readSizes(r *szReader) {
+	d.flags = r.readByte()
+	if d.flags&szFlagSingleValue != 0 {
+		d.minSymLen = int(r.readByte())
+		return
+	}
+	blockBits, spanBits := r.readByte(), r.readByte()
+	if blockBits > 32 || spanBits > 32 {
+		r.err = ErrSyzygyCorrupt
+		return
+	}
+	d.blockSize = 1 << blockBits
+	d.span = 1 << spanBits
+	d.sparseIndexSize = (d.size() + d.span - 1) / d.span
+	padding := int(r.readByte())
+	d.numBlocks = int(r.readUint32())
+	d.blockLengthSize = d.numBlocks + padding
+	d.maxSymLen = int(r.readByte())
+	d.minSymLen = int(r.readByte())
+	if d.maxSymLen < d.minSymLen || d.maxSymLen >= 64 {
+		r.err = ErrSyzygyCorrupt
+		return
+	}
+	lowest := r.bytes(int64(2 * (d.maxSymLen - d.minSymLen + 1)))
+	d.lowestSym = make([]uint16, d.maxSymLen-d.minSymLen+1)
+	for i := range d.lowestSym {
+		d.lowestSym[i] = binary.LittleEndian.Uint16(lowest[2*i:])
+	}
+	// Longer codes have lower values: base64[i] >= base64[i+1]
+	d.base64 = make([]uint64, len(d.lowestSym))
+	for i := len(d.base64) - 2; i >= 0; i-- {
+		d.base64[i] = (d.base64[i+1] + uint64(d.lowestSym[i]) - uint64(d.lowestSym[i+1])) / 2
+	}
+	for i := range d.base64 {
+		d.base64[i] <<= uint(64 - i - d.minSymLen)
+	}
+	count := int(r.readUint16())
+	d.btree = r.bytes(int64(3 * count))
+	r.offset += int64(count & 1)
+	if r.err != nil {
+		return
+	}
+	d.symlen = make([]int, count)
+	visited := make([]bool, count)
+	for sym := range d.symlen {
+		if visited[sym] {
+			continue
+		}
+		length, ok := d.setSymlen(sym, visited)
+		if !ok {
+			r.err = ErrSyzygyCorrupt
+			return
+		}
+		d.symlen[sym] = length
+	}
+}
+
+// setSymlen returns the values expanded by a symbol, minus one
+func (d *szPairs) setSymlen(sym int, visited []bool) (int, bool) {
+	visited[sym] = true
+	right := d.right(sym)
+	if right == 0xFFF {
+		return 0, true
+	}
+	left := d.left(sym)
+	if left >= len(d.symlen) || right >= len(d.symlen) {
+		return 0, false
+	}
+	for _, child := range [2]int{left, right} {
+		if visited[child] {
+			continue
+		}
+		length, ok := d.setSymlen(child, visited)
+		if !ok {
+			return 0, false
+		}
+		d.symlen[child] = length
+	}
+	return d.symlen[left] + d.symlen[right] + 1, true
+}
+
+// readDTZMap reads the maps from stored values to distances of the DTZ files
+func (t *szTable) readDTZMap(r *szReader, data *szData) []byte {
+	start := r.offset
+	for f := 0; f < t.files(); f++ {
+		d := data.pairs[0][f]
+		if d.flags&szFlagMapped == 0 {
+			continue
+		}
+		if d.flags&szFlagWide != 0 {
+			r.align(2)
+			for i := range d.mapIdx {
+				d.mapIdx[i] = int(r.offset-start)/2 + 1
+				r.offset += 2 * int64(r.readUint16())
+			}
+		} else {
+			for i := range d.mapIdx {
+				d.mapIdx[i] = int(r.offset-start) + 1
+				r.offset += int64(r.readByte())
+			}
+		}
+	}
+	r.align(2)
+	end := r.offset
+	r.offset = start
+	dtzMap := r.bytes(end - start)
+	r.offset = end
+	return dtzMap
+}
+
+// decompress returns the stored value of the position at idx
+func (d *szPairs) decompress(file io.ReaderAt, idx uint64) (int, bool) {
+	if d.flags&szFlagSingleValue != 0 {
+		return d.minSymLen, true
+	}
+	// The sparse index locates the position k*span + span/2, walk the blocks from there
+	k := idx / d.span
+	if k >= d.sparseIndexSize {
+		return 0, false
+	}
+	block := int(binary.LittleEndian.Uint32(d.sparseIndex[6*k:]))
+	offset := int(binary.LittleEndian.Uint16(d.sparseIndex[6*k+4:]))
+	offset += int(idx%d.span) - int(d.span/2)
+	for offset < 0 {
+		if block--; block < 0 {
+			return 0, false
+		}
+		offset += d.blockLen(block) + 1
+	}
+	for {
+		if block >= d.blockLengthSize {
+			return 0, false
+		}
+		if length := d.blockLen(block); offset > length {
+			offset -= length + 1
+			block++
+			continue
+		}
+		break
+	}
+	buf, ok := d.block(file, block)
+	if !ok {
+		return 0, false
+	}
+	word := func(at int) uint64 {
+		if at+4 > len(buf) {
+			return 0
+		}
+		return uint64(binary.BigEndian.Uint32(buf[at:]))
+	}
+	// Skip the symbols before the position, each expanding to symlen+1 values
+	buf64, next, bufSize := word(0)<<32|word(4), 8, 64
+	sym := 0
+	for {
+		length := 0
+		for length < len(d.base64)-1 && buf64 < d.base64[length] {
+			length++
+		}
+		sym = int((buf64-d.base64[length])>>uint(64-length-d.minSymLen)) + int(d.lowestSym[length])
+		if sym >= len(d.symlen) || next > len(buf)+8 {
+			return 0, false
+		}
+		if offset < d.symlen[sym]+1 {
+			break
+		}
+		offset -= d.symlen[sym] + 1
+		length += d.minSymLen
+		buf64 <<= uint(length)
+		bufSize -= length
+		if bufSize <= 32 {
+			bufSize += 32
+			buf64 |= word(next) << uint(64-bufSize)
+			next += 4
+		}
+	}
+	// Expand the pairs down to the value
+	for d.symlen[sym] != 0 {
+		left := d.left(sym)
+		if offset < d.symlen[left]+1 {
+			sym = left
+		} else {
+			offset -= d.symlen[left] + 1
+			sym = d.right(sym)
+		}
+	}
+	return d.left(sym), true
+}
+
+// mapDTZ converts a stored DTZ value to plies, given the result of the position
+func (d *szPairs) mapDTZ(dtzMap []byte, value int, wdl int) (int, bool) {
+	wdlMap := [5]int{1, 3, 0, 2, 0}
+	if d.flags&szFlagMapped != 0 {
+		at := d.mapIdx[wdlMap[wdl+2]] + value
+		if d.flags&szFlagWide != 0 {
+			if 2*at+2 > len(dtzMap) {
+				return 0, false
+			}
+			value = int(binary.LittleEndian.Uint16(dtzMap[2*at:]))
+		} else {
+			if at >= len(dtzMap) {
+				return 0, false
+			}
+			value = int(dtzMap[at])
+		}
+	}
+	if (wdl == 2 && d.flags&szFlagWinPlies == 0) || (wdl == -2 && d.flags&szFlagLossPlies == 0) || wdl == 1 || wdl == -1 {
+		value *= 2
+	}
+	return value + 1, true
+}
+
+// szBoard returns the Syzygy piece on each Syzygy square of the board, and the occupied squares
+func szBoard(board *Board) ([64]int, [16]uint64, uint64) {
+	var pieceOn [64]int
+	var bitboards [16]uint64
+	occupied := uint64(0)
+	for i, bb := range [12]uint64{
+		board.WhitePawns, board.WhiteKnights, board.WhiteBishops, board.WhiteRooks, board.WhiteQueens, board.WhiteKing,
+		board.BlackPawns, board.BlackKnights, board.BlackBishops, board.BlackRooks, board.BlackQueens, board.BlackKing,
+	} {
+		piece := i%6 + 1 + 8*(i/6)
+		bitboards[piece] = bits.ReverseBytes64(bb)
+		occupied |= bitboards[piece]
+		for b := bitboards[piece]; b != 0; b &= b - 1 {
+			pieceOn[bits.TrailingZeros64(b)] = piece
+		}
+	}
+	return pieceOn, bitboards, occupied
+}
+
+// encode returns the pairs and index of the position in the table
+func (t *szTable) encode(board *Board, data *szData, kind int) (*szPairs, uint64, szState) {
+	pieceOn, bitboards, occupied := szBoard(board)
+	// Tables store white as the side named first, and only white to move for
+	// symmetric materials: swap the colors otherwise
+	flip := (t.key == t.key2 && !board.WhiteToMove) || board.materialCounts() != t.key
+	flipColor, flipSquares := 0, 0
+	if flip {
+		flipColor, flipSquares = 8, 56
+	}
+	stm := colorIndex(board.WhiteToMove)
+	if flip {
+		stm ^= 1
+	}
+	var squares, pieces [SyzygyMaxPieces]int
+	size, leadCount, file := 0, 0, 0
+	leadPawns := uint64(0)
+	if t.hasPawns {
+		// Pawn tables are split by the file of the leading pawn, a to d
+		leadPawns = bitboards[(data.pairs[0][0].pieces[0]^flipColor)&8|1]
+		for b := leadPawns; b != 0; b &= b - 1 {
+			squares[size] = bits.TrailingZeros64(b) ^ flipSquares
+			size++
+		}
+		leadCount = size
+		best := 0
+		for i := 1; i < leadCount; i++ {
+			if szMapPawns[squares[i]] > szMapPawns[squares[best]] {
+				best = i
+			}
+		}
+		squares[0], squares[best] = squares[best], squares[0]
+		file = squares[0] & 7
+		if file > 3 {
+			file ^= 7
+		}
+	}
+	side := stm
+	if kind == szDTZ {
+		side = 0
+		if flags := data.pairs[0][file].flags; int(flags&szFlagSTM) != stm && (t.key != t.key2 || t.hasPawns) {
+			return nil, 0, szChangeSTM
+		}
+	}
+	d := data.pairs[side][file]
+	if d == nil {
+		return nil, 0, szFail
+	}
+	for b := occupied &^ leadPawns; b != 0; b &= b - 1 {
+		sq := bits.TrailingZeros64(b)
+		if size == t.pieceCount {
+			return nil, 0, szFail
+		}
+		squares[size] = sq ^ flipSquares
+		pieces[size] = pieceOn[sq] ^ flipColor
+		size++
+	}
+	// Order the pieces as the table does
+	for i := leadCount; i < size-1; i++ {
+		for j := i + 1; j < size; j++ {
+			if d.pieces[i] == pieces[j] {
+				pieces[i], pieces[j] = pieces[j], pieces[i]
+				squares[i], squares[j] = squares[j], squares[i]
+				break
+			}
+		}
+	}
+	// The leading piece goes to files a to d
+	if squares[0]&7 > 3 {
+		for i := range squares[:size] {
+			squares[i] ^= 7
+		}
+	}
+	var idx uint64
+	if t.hasPawns {
+		idx = szLeadPawnIdx[leadCount][squares[0]]
+		others := squares[1:leadCount]
+		sort.SliceStable(others, func(i, j int) bool { return szMapPawns[others[i]] < szMapPawns[others[j]] })
+		for i := 1; i < leadCount; i++ {
+			idx += szBinomial[i][szMapPawns[squares[i]]]
+		}
+	} else {
+		idx = t.encodeLeading(squares[:size], d.groupLen[0])
+	}
+	idx *= d.groupIdx[0]
+	// Each next group is placed on the squares left, pawns off the first rank
+	start := d.groupLen[0]
+	remainingPawns := t.hasPawns && t.pawnCount[1] > 0
+	for next := 1; d.groupLen[next] != 0; next++ {
+		if start+d.groupLen[next] > size {
+			return nil, 0, szFail
+		}
+		group := squares[start : start+d.groupLen[next]]
+		sort.Ints(group)
+		n := uint64(0)
+		for i, sq := range group {
+			placed := sq
+			for _, prev := range squares[:start] {
+				if sq > prev {
+					placed--
+				}
+			}
+			if remainingPawns {
+				placed -= 8
+			}
+			if placed < 0 {
+				return nil, 0, szFail
+			}
+			n += szBinomial[i+1][placed]
+		}
+		remainingPawns = false
+		idx += n * d.groupIdx[next]
+		start += d.groupLen[next]
+	}
+	return d, idx, szOK
+}
+
+// encodeLeading returns the index of the leading group of a pawnless table,
+// mapping the squares so the first piece is in the a1-d1-d4 triangle
+func (t *szTable) encodeLeading(squares []int, groupLen int) uint64 {
+	if squares[0]>>3 > 3 {
+		for i := range squares {
+			squares[i] ^= 56
+		}
+	}
+	// The first piece off the a1-h8 diagonal goes below it
+	for i := 0; i < groupLen; i++ {
+		off := szOffDiagonal(squares[i])
+		if off == 0 {
+			continue
+		}
+		if off > 0 {
+			for j := i; j < len(squares); j++ {
+				squares[j] = (squares[j]>>3 | squares[j]<<3) & 63
+			}
+		}
+		break
+	}
+	if !t.hasUniquePieces {
+		return uint64(szMapKK[szMapA1D1D4[squares[0]]][squares[1]])
+	}
+	adjust1, adjust2 := 0, 0
+	if squares[1] > squares[0] {
+		adjust1++
+	}
+	if squares[2] > squares[0] {
+		adjust2++
+	}
+	if squares[2] > squares[1] {
+		adjust2++
+	}
+	switch {
+	case szOffDiagonal(squares[0]) != 0:
+		return uint64((szMapA1D1D4[squares[0]]*63+squares[1]-adjust1)*62 + squares[2] - adjust2)
+	case szOffDiagonal(squares[1]) != 0:
+		return uint64((6*63+(squares[0]>>3)*28+szMapB1H1H7[squares[1]])*62 + squares[2] - adjust2)
+	case szOffDiagonal(squares[2]) != 0:
+		return uint64(6*63*62 + 4*28*62 + (squares[0]>>3)*7*28 + (squares[1]>>3-adjust1)*28 + szMapB1H1H7[squares[2]])
+	}
+	return uint64(6*63*62 + 4*28*62 + 4*7*28 + (squares[0]>>3)*7*6 + (squares[1]>>3-adjust1)*6 + squares[2]>>3 - adjust2)
+}
+
+// SyzygyTablebase is a set of Syzygy tables found by LoadSyzygy. Table files are
+// opened on first use and kept open, blocks are read from them when probing and
+// kept in memory.
+type SyzygyTablebase struct {
+	ProbeDepth int  // Least depth probing the tables with the most pieces in the search, see "SyzygyProbeDepth"
+	Rule50     bool // Score wins and losses the 50-move rule turns into draws as draws, see "Syzygy50MoveRule"
+	tables     map[materialCounts]*szTable
+	names      []string
+	maxPieces  int
+}
+
+// LoadSyzygy finds the tables in the directories of paths, separated like the
+// PATH environment variable, checking the WDL file headers. A DTZ file may be in
+// any of the directories.
+func LoadSyzygy(paths string) (*SyzygyTablebase, error) {
+	tb := &SyzygyTablebase{ProbeDepth: DefaultSyzygyProbeDepth, Rule50: true, tables: map[materialCounts]*szTable{}}
+	dtzPaths := map[string]string{}
+	var wdlPaths []string
+	for _, dir := range filepath.SplitList(paths) {
+		if info, err := os.Stat(dir); err != nil {
+			return nil, err
+		} else if !info.IsDir() {
+			return nil, fmt.Errorf("%s: not a directory", dir)
+		}
+		found, err := filepath.Glob(filepath.Join(dir, "*"+SyzygyWDLExtension))
+		if err != nil {
+			return nil, err
+		}
+		wdlPaths = append(wdlPaths, found...)
+		if found, err = filepath.Glob(filepath.Join(dir, "*"+SyzygyDTZExtension)); err != nil {
+			return nil, err
+		}
+		for _, path := range found {
+			name := strings.TrimSuffix(filepath.Base(path), SyzygyDTZExtension)
+			if _, ok := dtzPaths[name]; !ok {
+				dtzPaths[name] = path
+			}
+		}
+	}
+	for _, path := range wdlPaths {
+		t, err := newSzTable(strings.TrimSuffix(filepath.Base(path), SyzygyWDLExtension))
+		if err != nil {
+			continue // Not a table
+		}
+		if _, ok := tb.tables[t.key]; ok {
+			continue
+		}
+		file, _, err := openSyzygyFile(path, szWDL)
+		if err != nil {
+			return nil, err
+		}
+		file.Close()
+		t.paths = [2]string{path, dtzPaths[t.name]}
+		tb.tables[t.key] = t
+		tb.tables[t.key2] = t
+		tb.names = append(tb.names, t.name)
+		tb.maxPieces = MathMaxInt(tb.maxPieces, t.pieceCount)
+	}
+	sort.Strings(tb.names)
+	return tb, nil
+}
+
+// Tables returns the materials of the tables, sorted
+func (tb *SyzygyTablebase) Tables() []string {
+	return tb.names
+}
+
+// MaxPieces returns the most pieces of a table, 0 for no tables
+func (tb *SyzygyTablebase) MaxPieces() int {
+	return tb.maxPieces
+}
+
+// Close closes the table files opened by probing. Not safe while probing.
+func (tb *SyzygyTablebase) Close() error {
+	var err error
+	for key, t := range tb.tables {
+		if key != t.key {
+			continue
+		}
+		for kind, data := range t.data {
+			if data != nil && data.closer != nil {
+				if closeErr := data.closer.Close(); err == nil {
+					err = closeErr
+				}
+			}
+			t.data[kind] = nil
+			t.once[kind] = sync.Once{}
+		}
+	}
+	return err
+}
+
+// probeTable reads the value of the position in a table: the result for WDL
+// tables, the plies to zeroing for DTZ tables given the result wdl
+func (tb *SyzygyTablebase) probeTable(board *Board, kind int, wdl int) (int, szState) {
+	// Bare kings and a lone minor piece are draws, with or without their tables
+	if board.IsInsufficientMaterial() {
+		return 0, szOK
+	}
+	t := tb.tables[board.materialCounts()]
+	if t == nil {
+		return 0, szFail
+	}
+	data := t.get(kind)
+	if data == nil {
+		return 0, szFail
+	}
+	d, idx, state := t.encode(board, data, kind)
+	if state != szOK {
+		return 0, state
+	}
+	value, ok := d.decompress(data.file, idx)
+	if !ok {
+		return 0, szFail
+	}
+	if kind == szWDL {
+		return value - 2, szOK
+	}
+	if value, ok = d.mapDTZ(data.dtzMap, value, wdl); !ok {
+		return 0, szFail
+	}
+	return value, szOK
+}
+
+func isPawnPiece(piece byte) bool {
+	return piece == WhitePawn || piece == BlackPawn
+}
+
+// search returns the result of the position, trying the captures, and the pawn
+// moves when zeroing is set: the tables may store any value when such a move is
+// best
+func (tb *SyzygyTablebase) search(board *Board, zeroing bool) (int, szState) {
+	best := -2
+	moves := board.GenerateLegalMoves()
+	searched := 0
+	for _, move := range moves {
+		if !move.IsCapture() && (!zeroing || !isPawnPiece(move.Piece)) {
+			continue
+		}
+		searched++
+		prev := board.Move(move)
+		value, state := tb.search(board, false)
+		board.UndoMove(prev)
+		if state == szFail {
+			return 0, szFail
+		}
+		if value = -value; value > best {
+			best = value
+			if value >= 2 {
+				return value, szZeroingBestMove
+			}
+		}
+	}
+	// The stored value is not needed when every move was searched
+	allSearched := searched > 0 && searched == len(moves)
+	value := best
+	if !allSearched {
+		var state szState
+		if value, state = tb.probeTable(board, szWDL, 0); state == szFail {
+			return 0, szFail
+		}
+	}
+	if best >= value {
+		if best > 0 || allSearched {
+			return best, szZeroingBestMove
+		}
+		return best, szOK
+	}
+	return value, szOK
+}
+
+// probable reports whether the tables may hold the position
+func (tb *SyzygyTablebase) probable(board *Board) bool {
+	return board.CountPieces() <= tb.maxPieces && board.Castling == (CastlingState{})
+}
+
+// ProbeWDL returns the result of the position for the side to move: 2 win,
+// 1 win spoiled by the 50-move rule, 0 draw, -1 loss saved by the 50-move rule,
+// -2 loss. The 50-move counter is assumed to be zero.
+func (tb *SyzygyTablebase) ProbeWDL(board *Board) (int, bool) {
+	if !tb.probable(board) {
+		return 0, false
+	}
+	wdl, state := tb.search(board, false)
+	return wdl, state != szFail
+}
+
+// szDTZBeforeZeroing returns the DTZ of a position whose best move zeroes the 50-move counter
+func szDTZBeforeZeroing(wdl int) int {
+	switch wdl {
+	case 2:
+		return 1
+	case 1:
+		return 101
+	case -1:
+		return -101
+	case -2:
+		return -1
+	}
+	return 0
+}
+
+// ProbeDTZ returns the plies to the next capture or pawn move of the winning
+// side, or to mate, positive when winning and negative when losing, 100 more
+// for results spoiled by the 50-move rule, 0 for draws
+func (tb *SyzygyTablebase) ProbeDTZ(board *Board) (int, bool) {
+	if !tb.probable(board) {
+		return 0, false
+	}
+	dtz, state := tb.probeDTZ(board)
+	return dtz, state != szFail
+}
+
+func (tb *SyzygyTablebase) probeDTZ(board *Board) (int, szState) {
+	wdl, state := tb.search(board, true)
+	if state == szFail || wdl == 0 {
+		return 0, state
+	}
+	if state == szZeroingBestMove {
+		return szDTZBeforeZeroing(wdl), szOK
+	}
+	dtz, state := tb.probeTable(board, szDTZ, wdl)
+	switch state {
+	case szFail:
+		return 0, szFail
+	case szOK:
+		if wdl == 1 || wdl == -1 {
+			dtz += 100
+		}
+		if wdl < 0 {
+			dtz = -dtz
+		}
+		return dtz, szOK
+	}
+	// The table stores the other side to move: take the best move
+	best := 0xFFFF
+	for _, move := range board.GenerateLegalMoves() {
+		zeroing := move.IsCapture() || isPawnPiece(move.Piece)
+		prev := board.Move(move)
+		var value int
+		if zeroing {
+			value, state = tb.search(board, false)
+			value = -szDTZBeforeZeroing(value)
+		} else {
+			value, state = tb.probeDTZ(board)
+			value = -value
+		}
+		if value == 1 && board.IsInCheck() && len(board.GenerateLegalMoves()) == 0 {
+			best = 1
+		}
+		board.UndoMove(prev)
+		if state == szFail {
+			return 0, szFail
+		}
+		if !zeroing && value != 0 {
+			value += sign(value)
+		}
+		if value < best && sign(value) == sign(wdl) {
+			best = value
+		}
+	}
+	if best == 0xFFFF {
+		return -1, szOK
+	}
+	return best, szOK
+}
+
+func sign(x int) int {
+	switch {
+	case x > 0:
+		return 1
+	case x < 0:
+		return -1
+	}
+	return 0
+}
+
+// score returns the search score of a result at ply from white's perspective, wins
+// counted from the root like mate scores
+func (tb *SyzygyTablebase) score(wdl int, whiteToMove bool, drawScore int, ply int) int {
+	spoiled := 0
+	if tb.Rule50 {
+		spoiled = 1
+	}
+	score := 0
+	switch {
+	case wdl > spoiled:
+		score = SyzygyWinScore - ply
+	case wdl < -spoiled:
+		score = -SyzygyWinScore + ply
+	default:
+		// Results spoiled by the 50-move rule lean slightly to their side
+		if !whiteToMove {
+			wdl = -wdl
+		}
+		return drawScore + 2*wdl
+	}
+	if !whiteToMove {
+		return -score
+	}
+	return score
+}
+
+// activeSyzygy is probed by the search when set, see SetSyzygy
+var activeSyzygy *SyzygyTablebase
+
+// SetSyzygy makes the search probe tb, nil to stop probing. The tables are shared
+// by every search of the process, do not call during a search.
+func SetSyzygy(tb *SyzygyTablebase) {
+	activeSyzygy = tb
+}
+
+// ActiveSyzygy returns the Syzygy tables probed by the search, nil for none
+func ActiveSyzygy() *SyzygyTablebase {
+	return activeSyzygy
+}
+
+// probeSyzygy returns the score of a position from white's perspective when found
+// in the active Syzygy tables. Positions are probed right after a capture or a
+// pawn move, the results assume a zero 50-move counter. The tables with the most
+// pieces are probed from the probe depth only. ply is the distance from the root.
+func (board *Board) probeSyzygy(depth int, drawScore int, ply int) (int, bool) {
+	tb := activeSyzygy
+	if tb == nil || board.HalfMoveClock != 0 {
+		return 0, false
+	}
+	if pieces := board.CountPieces(); pieces > tb.maxPieces || (pieces == tb.maxPieces && depth < tb.ProbeDepth) {
+		return 0, false
+	}
+	wdl, ok := tb.ProbeWDL(board)
+	if !ok {
+		return 0, false
+	}
+	return tb.score(wdl, board.WhiteToMove, drawScore, ply), true
+}
+
+// syzygyRootMoves keeps the best moves of a position in the active Syzygy tables.
+// With DTZ tables, wins keep the moves zeroing the 50-move counter soonest, which
+// always makes progress, and losses the ones delaying it the longest. Without, the
+// moves keeping the best result are kept. With Rule50, the plies already on the
+// 50-move counter count: wins and losses it runs out before rank as draws.
+func (board *Board) syzygyRootMoves(moves []Move) []Move {
+	tb := activeSyzygy
+	if tb == nil || len(moves) == 0 || !tb.probable(board) {
+		return moves
+	}
+	ranks, ok := tb.rootRanks(board, moves, true)
+	if !ok {
+		if ranks, ok = tb.rootRanks(board, moves, false); !ok {
+			return moves
+		}
+	}
+	best := ranks[0]
+	for _, rank := range ranks {
+		best = MathMaxInt(best, rank)
+	}
+	kept := make([]Move, 0, len(moves))
+	for i, move := range moves {
+		if ranks[i] == best {
+			kept = append(kept, move)
+		}
+	}
+	return kept
+}
+
+// rootRanks ranks the moves by the DTZ after them, or by their result only
+func (tb *SyzygyTablebase) rootRanks(board *Board, moves []Move, useDTZ bool) ([]int, bool) {
+	ranks := make([]int, len(moves))
+	for i, move := range moves {
+		clock := board.HalfMoveClock
+		prev := board.Move(move)
+		wdl, ok := tb.ProbeWDL(board)
+		dtz := szDTZBeforeZeroing(-wdl)
+		if board.HalfMoveClock == 0 {
+			clock = 0
+		} else if ok && useDTZ {
+			dtz, ok = tb.ProbeDTZ(board)
+			dtz = -dtz
+			if dtz != 0 {
+				dtz += sign(dtz)
+			}
+		}
+		if ok && dtz == 2 && board.IsInCheck() && len(board.GenerateLegalMoves()) == 0 {
+			dtz = 1
+		}
+		board.UndoMove(prev)
+		if !ok {
+			return nil, false
+		}
+		switch {
+		case !useDTZ && tb.Rule50 && (wdl == 1 || wdl == -1):
+			ranks[i] = 0
+		case !useDTZ:
+			ranks[i] = -wdl
+		case tb.Rule50 && dtz*sign(dtz)+clock > 100:
+			ranks[i] = 0
+		case dtz > 0:
+			ranks[i] = 100_000 - dtz
+		case dtz < 0:
+			ranks[i] = -100_000 - dtz
+		}
+	}
+	return ranks, true
+}
@@ -0,0 +1,655 @@
+package libra
+
+import (
+	"bufio"
+	"compress/zlib"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math/bits"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+const (
+	DefaultTablebasePath    = "<empty>"               // Default "TablebasePath" UCI option, no tablebase
+	TablebaseMaxPieces      = 4                       // Most pieces, kings included, of a generated table
+	TablebaseExtension      = ".lbtb"                 // Extension of the table files, named after their material
+	TablebaseWinScore       = MaxEvaluationScore / 2  // Score of a tablebase win before subtracting the plies to mate
+	TablebaseScoreThreshold = SyzygyWinScore - MaxPly // Tablebase wins, like mates, score past it, one less per ply from the root
+)
+
+/*
+Tablebase file layout, "<material>.lbtb" (KQKR.lbtb), integers little endian:
+
+| Field       | Size     | Notes                                    |
+|-------------|----------|------------------------------------------|
+| Magic       | 8        | "LIBRATB\x00"                            |
+| Version     | 4        | tbFileVersion                            |
+| Name length | 1        |                                          |
+| Name        | n        | material, as the file name               |
+| Entries     | 4        | positions of the table, see tbMaterial   |
+| Values      | rest     | zlib stream of one byte per position     |
+
+A value is the distance to mate in plies with best play, from the side to move:
+even for losses (0 = checkmated), odd for wins, tbDraw for draws and tbInvalid
+for illegal positions and indexes unused by the symmetry reduction.
+*/
+const (
+	tbFileMagic   = "LIBRATB\x00"
+	tbFileVersion = 1
+)
+
+const (
+	tbUnknown byte = 253 // Not yet resolved, while generating
+	tbDraw    byte = 254
+	tbInvalid byte = 255
+)
+
+var (
+	ErrTablebaseMagic    = errors.New("not a tablebase file")
+	ErrTablebaseVersion  = errors.New("unsupported tablebase file version")
+	ErrTablebaseMaterial = errors.New("invalid tablebase material")
+)
+
+// tbKing is the kind of the kings, after the materialCounts kinds (pawn to queen)
+const tbKing = 5
+
+// tbPieceLetters are the kinds of materialCounts in table order, strongest first
+var tbPieceLetters = [5]byte{'P', 'N', 'B', 'R', 'Q'}
+
+var tbPieceValues = [5]int{1, 3, 3, 5, 9}
+
+type tbPiece struct {
+	color int // 0 for the table's first side, white on the board when not flipped
+	kind  int // materialCounts kind, or tbKing
+}
+
+// tbMaterial indexes the positions of a material signature. Pieces are ordered
+// as both kings, then the first side's pieces and the second side's, strongest
+// first. The first king is kept in a region by symmetry: the a1-d1-d4 triangle
+// without pawns, files a to d with pawns.
+type tbMaterial struct {
+	name   string
+	pieces []tbPiece
+	pawns  bool
+	size   int
+}
+
+// TablebaseResult is the outcome of a position with best play
+type TablebaseResult struct {
+	WDL int // 1 win, 0 draw, -1 loss for the side to move
+	DTM int // Plies to mate, 0 for draws
+}
+
+// tbResult converts a table value
+func tbResult(value byte) TablebaseResult {
+	switch {
+	case value >= tbUnknown:
+		return TablebaseResult{}
+	case value%2 == 1:
+		return TablebaseResult{WDL: 1, DTM: int(value)}
+	}
+	return TablebaseResult{WDL: -1, DTM: int(value)}
+}
+
+// score returns the search score of the result at ply from white's perspective.
+// Wins score less the more plies they are from mate and from the root, like mate
+// scores, so a quicker mate is preferred wherever it is found.
+func (result TablebaseResult) score(whiteToMove bool, drawScore int, ply int) int {
+	if result.WDL == 0 {
+		return drawScore
+	}
+	score := result.WDL * (TablebaseWinScore - result.DTM - ply)
+	if !whiteToMove {
+		return -score
+	}
+	return score
+}
+
+// better reports whether result is better than other for the side to move
+func (result TablebaseResult) better(other TablebaseResult) bool {
+	if result.WDL != other.WDL {
+		return result.WDL > other.WDL
+	}
+	return result.WDL*result.DTM < other.WDL*other.DTM
+}
+
+// tbSide returns the pieces of one side of a name, strongest first
+func tbSide(counts [5]int) string {
+	var sb strings.Builder
+	for kind := len(counts) - 1; kind >= 0; kind-- {
+		for i := 0; i < counts[kind]; i++ {
+			sb.WriteByte(tbPieceLetters[kind])
+		}
+	}
+	return sb.String()
+}
+
+// tbStronger reports whether the pieces a come before b in a table name: more
+// material, or the stronger pieces on equal material
+func tbStronger(a [5]int, b [5]int) bool {
+	valueA, valueB := 0, 0
+	for kind := range a {
+		valueA += a[kind] * tbPieceValues[kind]
+		valueB += b[kind] * tbPieceValues[kind]
+	}
+	if valueA != valueB {
+		return valueA > valueB
+	}
+	for kind := len(a) - 1; kind >= 0; kind-- {
+		if a[kind] != b[kind] {
+			return a[kind] > b[kind]
+		}
+	}
+	return false
+}
+
+// tablebaseName returns the table of the material counts, like KQKR, and whether
+// black is the table's first side
+func tablebaseName(counts materialCounts) (string, bool) {
+	flip := tbStronger(counts[ColorBlack], counts[ColorWhite])
+	first, second := counts[ColorWhite], counts[ColorBlack]
+	if flip {
+		first, second = second, first
+	}
+	return "K" + tbSide(first) + "K" + tbSide(second), flip
+}
+
+var (
+	tbMaterialsMu sync.Mutex
+	tbMaterials   = map[string]*tbMaterial{}
+)
+
+// tablebaseMaterial parses a table name
+func tablebaseMaterial(name string) (*tbMaterial, error) {
+	tbMaterialsMu.Lock()
+	defer tbMaterialsMu.Unlock()
+	if m, ok := tbMaterials[name]; ok {
+		return m, nil
+	}
+	split := strings.LastIndexByte(name, 'K')
+	if !strings.HasPrefix(name, "K") || split <= 0 {
+		return nil, fmt.Errorf("%w: %q", ErrTablebaseMaterial, name)
+	}
+	counts := materialCounts{}
+	for side, pieces := range [2]string{name[1:split], name[split+1:]} {
+		for i := 0; i < len(pieces); i++ {
+			kind := strings.IndexByte(string(tbPieceLetters[:]), pieces[i])
+			if kind < 0 {
+				return nil, fmt.Errorf("%w: %q", ErrTablebaseMaterial, name)
+			}
+			counts[side][kind]++
+		}
+	}
+	if canonical, _ := tablebaseName(counts); canonical != name {
+		return nil, fmt.Errorf("%w: %q, expected %q", ErrTablebaseMaterial, name, canonical)
+	}
+	m := &tbMaterial{name: name, pieces: []tbPiece{{0, tbKing}, {1, tbKing}}}
+	for color := range counts {
+		for kind := len(counts[color]) - 1; kind >= 0; kind-- {
+			for i := 0; i < counts[color][kind]; i++ {
+				m.pieces = append(m.pieces, tbPiece{color, kind})
+			}
+		}
+		m.pawns = m.pawns || counts[color][0] > 0
+	}
+	if len(m.pieces) > TablebaseMaxPieces {
+		return nil, fmt.Errorf("%w: %q has more than %d pieces", ErrTablebaseMaterial, name, TablebaseMaxPieces)
+	}
+	m.size = 2 * len(tbRegionSquares(m.pawns))
+	for i := 1; i < len(m.pieces); i++ {
+		m.size *= 64
+	}
+	tbMaterials[name] = m
+	return m, nil
+}
+
+// Symmetry regions of the first king: tbRegions[pawns][square] is the index of
+// square in the region, -1 outside
+var (
+	tbRegions      [2][64]int
+	tbRegionLists  [2][]int
+	tbSymmetries   [2][64][]int // Symmetries moving each square into the region
+	tbSymmetryMaps [8][64]int
+)
+
+func init() {
+	for sym := range tbSymmetryMaps {
+		for sq := 0; sq < 64; sq++ {
+			row, file := sq/8, sq%8
+			if sym&1 != 0 {
+				file = 7 - file
+			}
+			if sym&2 != 0 {
+				row = 7 - row
+			}
+			if sym&4 != 0 {
+				row, file = file, row
+			}
+			tbSymmetryMaps[sym][sq] = row*8 + file
+		}
+	}
+	for pawns := range tbRegions {
+		for sq := 0; sq < 64; sq++ {
+			rank, file := 7-sq/8, sq%8
+			inside := file <= 3 && rank <= file
+			if pawns == 1 {
+				inside = file <= 3
+			}
+			tbRegions[pawns][sq] = -1
+			if inside {
+				tbRegions[pawns][sq] = len(tbRegionLists[pawns])
+				tbRegionLists[pawns] = append(tbRegionLists[pawns], sq)
+			}
+		}
+		// Only the left-right mirror keeps the pawns moving up the board
+		symmetries := len(tbSymmetryMaps)
+		if pawns == 1 {
+			symmetries = 2
+		}
+		for sq := 0; sq < 64; sq++ {
+			for sym := 0; sym < symmetries; sym++ {
+				if tbRegions[pawns][tbSymmetryMaps[sym][sq]] >= 0 {
+					tbSymmetries[pawns][sq] = append(tbSymmetries[pawns][sq], sym)
+				}
+			}
+		}
+	}
+}
+
+func tbPawnsIndex(pawns bool) int {
+	if pawns {
+		return 1
+	}
+	return 0
+}
+
+func tbRegionSquares(pawns bool) []int {
+	return tbRegionLists[tbPawnsIndex(pawns)]
+}
+
+// index returns the index of squares, the first one in the region
+func (m *tbMaterial) index(squares []int, stm int) int {
+	regions := &tbRegions[tbPawnsIndex(m.pawns)]
+	index := stm*len(tbRegionSquares(m.pawns)) + regions[squares[0]]
+	for _, sq := range squares[1:] {
+		index = index*64 + sq
+	}
+	return index
+}
+
+// decode fills squares with the position at index and returns the side to move
+func (m *tbMaterial) decode(index int, squares []int) int {
+	for i := len(m.pieces) - 1; i > 0; i-- {
+		squares[i] = index % 64
+		index /= 64
+	}
+	region := tbRegionSquares(m.pawns)
+	squares[0] = region[index%len(region)]
+	return index / len(region)
+}
+
+// canonicalIndex returns the smallest index among the symmetric positions and
+// orders of identical pieces, the one the table stores
+func (m *tbMaterial) canonicalIndex(squares []int, stm int) int {
+	best := -1
+	var mapped [TablebaseMaxPieces]int
+	for _, sym := range tbSymmetries[tbPawnsIndex(m.pawns)][squares[0]] {
+		for i, sq := range squares {
+			mapped[i] = tbSymmetryMaps[sym][sq]
+		}
+		for i := 3; i < len(squares); i++ {
+			for j := i; j > 2 && m.pieces[j] == m.pieces[j-1] && mapped[j] < mapped[j-1]; j-- {
+				mapped[j], mapped[j-1] = mapped[j-1], mapped[j]
+			}
+		}
+		if index := m.index(mapped[:len(squares)], stm); best < 0 || index < best {
+			best = index
+		}
+	}
+	return best
+}
+
+// TablebaseTable holds the values of every position of a material signature
+type TablebaseTable struct {
+	material *tbMaterial
+	values   []byte
+}
+
+// Name returns the material of the table, like KQKR
+func (table *TablebaseTable) Name() string {
+	return table.material.name
+}
+
+// TablebaseStats counts the positions of a table by result, for the side to move
+type TablebaseStats struct {
+	Wins        int
+	Draws       int
+	Losses      int
+	LongestMate int // Plies
+}
+
+// Stats counts the positions of the table
+func (table *TablebaseTable) Stats() TablebaseStats {
+	stats := TablebaseStats{}
+	for _, value := range table.values {
+		switch {
+		case value == tbInvalid:
+		case value == tbDraw:
+			stats.Draws++
+		case value%2 == 1:
+			stats.Wins++
+		default:
+			stats.Losses++
+		}
+		if value < tbUnknown {
+			stats.LongestMate = MathMaxInt(stats.LongestMate, int(value))
+		}
+	}
+	return stats
+}
+
+// Save writes the table to w
+func (table *TablebaseTable) Save(w io.Writer) error {
+	buffered := bufio.NewWriter(w)
+	header := make([]byte, 0, 32)
+	header = append(header, tbFileMagic...)
+	header = binary.LittleEndian.AppendUint32(header, tbFileVersion)
+	header = append(header, byte(len(table.material.name)))
+	header = append(header, table.material.name...)
+	header = binary.LittleEndian.AppendUint32(header, uint32(len(table.values)))
+	if _, err := buffered.Write(header); err != nil {
+		return err
+	}
+	compressed, err := zlib.NewWriterLevel(buffered, zlib.BestCompression)
+	if err != nil {
+		return err
+	}
+	if _, err := compressed.Write(table.values); err != nil {
+		return err
+	}
+	if err := compressed.Close(); err != nil {
+		return err
+	}
+	return buffered.Flush()
+}
+
+// SaveFile writes the table to its file in dir
+func (table *TablebaseTable) SaveFile(dir string) error {
+	file, err := os.Create(filepath.Join(dir, table.Name()+TablebaseExtension))
+	if err != nil {
+		return err
+	}
+	if err := table.Save(file); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// readTablebaseHeader reads the header of a table and returns its material
+func readTablebaseHeader(r io.Reader) (*tbMaterial, error) {
+	header := make([]byte, len(tbFileMagic)+5)
+	if _, err := io.ReadFull(r, header); err != nil || string(header[:len(tbFileMagic)]) != tbFileMagic {
+		return nil, ErrTablebaseMagic
+	}
+	if version := binary.LittleEndian.Uint32(header[len(tbFileMagic):]); version != tbFileVersion {
+		return nil, fmt.Errorf("%w: %d", ErrTablebaseVersion, version)
+	}
+	rest := make([]byte, int(header[len(header)-1])+4)
+	if _, err := io.ReadFull(r, rest); err != nil {
+		return nil, ErrTablebaseMagic
+	}
+	m, err := tablebaseMaterial(string(rest[:len(rest)-4]))
+	if err != nil {
+		return nil, err
+	}
+	if entries := binary.LittleEndian.Uint32(rest[len(rest)-4:]); int(entries) != m.size {
+		return nil, fmt.Errorf("%w: %s has %d positions, expected %d", ErrTablebaseMaterial, m.name, entries, m.size)
+	}
+	return m, nil
+}
+
+// LoadTablebaseTable reads a table written by Save
+func LoadTablebaseTable(r io.Reader) (*TablebaseTable, error) {
+	buffered := bufio.NewReader(r)
+	m, err := readTablebaseHeader(buffered)
+	if err != nil {
+		return nil, err
+	}
+	compressed, err := zlib.NewReader(buffered)
+	if err != nil {
+		return nil, err
+	}
+	defer compressed.Close()
+	table := &TablebaseTable{material: m, values: make([]byte, m.size)}
+	if _, err := io.ReadFull(compressed, table.values); err != nil {
+		return nil, fmt.Errorf("%s: %w", m.name, err)
+	}
+	return table, nil
+}
+
+// LoadTablebaseTableFile reads the table saved at path
+func LoadTablebaseTableFile(path string) (*TablebaseTable, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return LoadTablebaseTable(file)
+}
+
+// Tablebase is a set of tables probed by material. Tables found by LoadTablebase
+// are read on first use.
+type Tablebase struct {
+	tables    map[string]*tablebaseEntry
+	maxPieces int
+}
+
+type tablebaseEntry struct {
+	path  string
+	once  sync.Once
+	table *TablebaseTable
+}
+
+// NewTablebase returns an empty tablebase, see Add
+func NewTablebase() *Tablebase {
+	return &Tablebase{tables: map[string]*tablebaseEntry{}}
+}
+
+// LoadTablebase returns the tables of the files in dir, checking their headers
+func LoadTablebase(dir string) (*Tablebase, error) {
+	if info, err := os.Stat(dir); err != nil {
+		return nil, err
+	} else if !info.IsDir() {
+		return nil, fmt.Errorf("%s: not a directory", dir)
+	}
+	paths, err := filepath.Glob(filepath.Join(dir, "*"+TablebaseExtension))
+	if err != nil {
+		return nil, err
+	}
+	tb := NewTablebase()
+	for _, path := range paths {
+		file, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		m, err := readTablebaseHeader(bufio.NewReader(file))
+		file.Close()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		tb.tables[m.name] = &tablebaseEntry{path: path}
+		tb.maxPieces = MathMaxInt(tb.maxPieces, len(m.pieces))
+	}
+	return tb, nil
+}
+
+// Add adds a table, replacing the one of the same material. Not safe while probing.
+func (tb *Tablebase) Add(table *TablebaseTable) {
+	entry := &tablebaseEntry{table: table}
+	entry.once.Do(func() {})
+	tb.tables[table.Name()] = entry
+	tb.maxPieces = MathMaxInt(tb.maxPieces, len(table.material.pieces))
+}
+
+// Tables returns the materials of the tables, sorted
+func (tb *Tablebase) Tables() []string {
+	names := make([]string, 0, len(tb.tables))
+	for name := range tb.tables {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// MaxPieces returns the most pieces of a table, 0 for an empty tablebase
+func (tb *Tablebase) MaxPieces() int {
+	return tb.maxPieces
+}
+
+// table returns the table of a material, nil when missing or unreadable
+func (tb *Tablebase) table(name string) *TablebaseTable {
+	entry, ok := tb.tables[name]
+	if !ok {
+		return nil
+	}
+	entry.once.Do(func() {
+		entry.table, _ = LoadTablebaseTableFile(entry.path)
+	})
+	return entry.table
+}
+
+// Probe returns the result of the position with best play. Positions with more
+// pieces than the tablebase, castling rights or a possible en passant capture
+// are not found.
+func (tb *Tablebase) Probe(board *Board) (TablebaseResult, bool) {
+	pieces := board.CountPieces()
+	if pieces == 2 {
+		return TablebaseResult{}, true
+	}
+	if pieces > tb.maxPieces || board.Castling != (CastlingState{}) || board.canCaptureEnPassant() {
+		return TablebaseResult{}, false
+	}
+	name, flip := tablebaseName(board.materialCounts())
+	table := tb.table(name)
+	if table == nil {
+		return TablebaseResult{}, false
+	}
+	// The table's first side is white, mirror the board when it is black
+	bitboards := [2][6]uint64{}
+	for color := ColorWhite; color <= ColorBlack; color++ {
+		pawns, king := board.WhitePawns, board.WhiteKing
+		if color == ColorBlack {
+			pawns, king = board.BlackPawns, board.BlackKing
+		}
+		side := color
+		if flip {
+			side ^= 1
+		}
+		bitboards[side][0], bitboards[side][tbKing] = pawns, king
+		for piece, bb := range board.pieceBitboards(color) {
+			bitboards[side][piece+1] = bb
+		}
+	}
+	m := table.material
+	var squares [TablebaseMaxPieces]int
+	for i, piece := range m.pieces {
+		bb := &bitboards[piece.color][piece.kind]
+		squares[i] = bits.TrailingZeros64(*bb)
+		*bb &= *bb - 1
+		if flip {
+			squares[i] ^= 56
+		}
+	}
+	stm := 0
+	if board.WhiteToMove == flip {
+		stm = 1
+	}
+	return tbResult(table.values[m.canonicalIndex(squares[:len(m.pieces)], stm)]), true
+}
+
+// canCaptureEnPassant reports whether a pawn of the side to move attacks the en passant square
+func (board *Board) canCaptureEnPassant() bool {
+	if board.OnPassant == 0 {
+		return false
+	}
+	if board.WhiteToMove {
+		return PawnAttacks[ColorBlack][board.OnPassant]&board.WhitePawns != 0
+	}
+	return PawnAttacks[ColorWhite][board.OnPassant]&board.BlackPawns != 0
+}
+
+// activeTablebase is probed by the search when set, see SetTablebase
+var activeTablebase *Tablebase
+
+// SetTablebase makes the search probe tb, nil to stop probing. The tablebase is
+// shared by every search of the process, do not call during a search.
+func SetTablebase(tb *Tablebase) {
+	activeTablebase = tb
+}
+
+// ActiveTablebase returns the tablebase probed by the search, nil for none
+func ActiveTablebase() *Tablebase {
+	return activeTablebase
+}
+
+// probeTablebase returns the score from white's perspective of a position at ply
+// found in the active tablebase
+func (board *Board) probeTablebase(drawScore int, ply int) (int, bool) {
+	tb := activeTablebase
+	if tb == nil || tb.maxPieces == 0 {
+		return 0, false
+	}
+	result, ok := tb.Probe(board)
+	if !ok {
+		return 0, false
+	}
+	return result.score(board.WhiteToMove, drawScore, ply), true
+}
+
+// tablebaseRootMoves keeps the moves preserving the best result of a position found
+// in the active tablebase: the fastest mates when winning, the drawing moves of a
+// draw and the longest defences when losing. Moves to positions not found are kept.
+func (board *Board) tablebaseRootMoves(moves []Move) []Move {
+	tb := activeTablebase
+	if tb == nil || len(moves) == 0 {
+		return moves
+	}
+	if _, ok := tb.Probe(board); !ok {
+		return moves
+	}
+	results := make([]TablebaseResult, len(moves))
+	found := make([]bool, len(moves))
+	var best TablebaseResult
+	bestFound := false
+	for i, move := range moves {
+		prev := board.Move(move)
+		result, ok := tb.Probe(board)
+		board.UndoMove(prev)
+		if !ok {
+			continue
+		}
+		// From the side moving, one ply further from mate
+		results[i] = TablebaseResult{WDL: -result.WDL}
+		if result.WDL != 0 {
+			results[i].DTM = result.DTM + 1
+		}
+		found[i] = true
+		if !bestFound || results[i].better(best) {
+			best, bestFound = results[i], true
+		}
+	}
+	kept := make([]Move, 0, len(moves))
+	for i, move := range moves {
+		if !found[i] || results[i] == best {
+			kept = append(kept, move)
+		}
+	}
+	return kept
+}
@@ -0,0 +1,350 @@
+package libra
+
+import (
+	"fmt"
+	"math/bits"
+	"runtime"
+	"sort"
+	"sync"
+)
+
+// Exit flags of a position while generating: its captures and promotions leave
+// the table and are resolved in the tables they lead to
+const (
+	tbWinExit  byte = 1 << iota // A capture or promotion wins
+	tbDrawExit                  // A capture or promotion draws
+)
+
+// TablebaseMaterials returns the tables with up to pieces pieces, kings included,
+// in generation order: after the tables their captures and promotions lead to
+func TablebaseMaterials(pieces int) []string {
+	seen := map[string]bool{}
+	var names []string
+	var add func(counts materialCounts, left int, color int, kind int)
+	add = func(counts materialCounts, left int, color int, kind int) {
+		if name, _ := tablebaseName(counts); name != "KK" && !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+		if left == 0 {
+			return
+		}
+		for c := color; c <= ColorBlack; c++ {
+			start := 0
+			if c == color {
+				start = kind
+			}
+			for k := start; k < len(counts[c]); k++ {
+				counts[c][k]++
+				add(counts, left-1, c, k)
+				counts[c][k]--
+			}
+		}
+	}
+	add(materialCounts{}, MathMinInt(pieces, TablebaseMaxPieces)-2, ColorWhite, 0)
+	order := func(name string) (int, int) {
+		pawns := 0
+		for i := 0; i < len(name); i++ {
+			if name[i] == 'P' {
+				pawns++
+			}
+		}
+		return len(name), pawns
+	}
+	sort.SliceStable(names, func(i, j int) bool {
+		piecesI, pawnsI := order(names[i])
+		piecesJ, pawnsJ := order(names[j])
+		if piecesI != piecesJ {
+			return piecesI < piecesJ
+		}
+		if pawnsI != pawnsJ {
+			return pawnsI < pawnsJ
+		}
+		return names[i] < names[j]
+	})
+	return names
+}
+
+type tbGenerator struct {
+	material *tbMaterial
+	tb       *Tablebase
+	values   []byte
+	children []byte // Moves staying in the table to positions not yet lost for the opponent
+	exits    []byte // tbWinExit, tbDrawExit
+	exitLoss []byte // Longest loss through a capture or promotion, 0 for none
+	buckets  [tbUnknown][]int32
+}
+
+// GenerateTablebaseTable generates the table of a material, like KQKR, by
+// retrograde analysis. Captures and promotions are probed in tb, which must hold
+// the tables they lead to, see TablebaseMaterials. The legal moves of every
+// position are generated on threads goroutines, then the results spread back
+// from the mates one ply at a time: a position is won in n+1 plies when a move
+// reaches a position lost in n, and lost in n+1 when its last move not losing yet
+// reaches a position won in n. Positions never resolved are draws. Positions with
+// a possible en passant capture are not distinguished.
+func GenerateTablebaseTable(name string, tb *Tablebase, threads int) (*TablebaseTable, error) {
+	m, err := tablebaseMaterial(name)
+	if err != nil {
+		return nil, err
+	}
+	if threads <= 0 {
+		threads = runtime.NumCPU()
+	}
+	g := &tbGenerator{
+		material: m,
+		tb:       tb,
+		values:   make([]byte, m.size),
+		children: make([]byte, m.size),
+		exits:    make([]byte, m.size),
+		exitLoss: make([]byte, m.size),
+	}
+	if err := g.initialize(threads); err != nil {
+		return nil, err
+	}
+	if err := g.retrograde(); err != nil {
+		return nil, err
+	}
+	for i, value := range g.values {
+		if value == tbUnknown {
+			g.values[i] = tbDraw
+		}
+	}
+	return &TablebaseTable{material: m, values: g.values}, nil
+}
+
+// initialize resolves the mates and stalemates, and counts the moves of every position
+func (g *tbGenerator) initialize(threads int) error {
+	chunk := (g.material.size + threads - 1) / threads
+	buckets := make([][tbUnknown][]int32, threads)
+	errs := make([]error, threads)
+	var wg sync.WaitGroup
+	for thread := 0; thread < threads; thread++ {
+		wg.Add(1)
+		go func(thread int) {
+			defer wg.Done()
+			start, end := thread*chunk, MathMinInt((thread+1)*chunk, g.material.size)
+			errs[thread] = g.initializeRange(start, end, &buckets[thread])
+		}(thread)
+	}
+	wg.Wait()
+	for thread := range buckets {
+		if errs[thread] != nil {
+			return errs[thread]
+		}
+		for level, indexes := range buckets[thread] {
+			g.buckets[level] = append(g.buckets[level], indexes...)
+		}
+	}
+	return nil
+}
+
+func (g *tbGenerator) initializeRange(start int, end int, buckets *[tbUnknown][]int32) error {
+	m := g.material
+	board := NewBoard()
+	squares := make([]int, len(m.pieces))
+	child := make([]int, len(m.pieces))
+	children := make([]int, 0, 64)
+	for index := start; index < end; index++ {
+		stm := m.decode(index, squares)
+		if !g.setBoard(board, squares, stm) || m.canonicalIndex(squares, stm) != index {
+			g.values[index] = tbInvalid
+			continue
+		}
+		g.values[index] = tbUnknown
+		moves := board.GenerateLegalMoves()
+		if len(moves) == 0 {
+			if board.IsInCheck() {
+				buckets[0] = append(buckets[0], int32(index))
+			} else {
+				g.values[index] = tbDraw
+			}
+			continue
+		}
+		children = children[:0]
+		winExit := tbUnknown
+		for _, move := range moves {
+			if move.Captured != 0 || move.Promoted != 0 {
+				prev := board.Move(move)
+				result, ok := g.tb.Probe(board)
+				if !ok {
+					missing, _ := tablebaseName(board.materialCounts())
+					board.UndoMove(prev)
+					return fmt.Errorf("%s: missing table %s", m.name, missing)
+				}
+				board.UndoMove(prev)
+				switch result.WDL {
+				case -1:
+					winExit = byte(MathMinInt(int(winExit), result.DTM+1))
+				case 0:
+					g.exits[index] |= tbDrawExit
+				case 1:
+					g.exitLoss[index] = byte(MathMaxInt(int(g.exitLoss[index]), result.DTM+1))
+				}
+				continue
+			}
+			copy(child, squares)
+			for i, sq := range squares {
+				if sq == int(move.From) {
+					child[i] = int(move.To)
+				}
+			}
+			childIndex := m.canonicalIndex(child, stm^1)
+			if !containsInt(children, childIndex) {
+				children = append(children, childIndex)
+			}
+		}
+		g.children[index] = byte(len(children))
+		switch {
+		case winExit != tbUnknown:
+			g.exits[index] |= tbWinExit
+			buckets[winExit] = append(buckets[winExit], int32(index))
+		case len(children) > 0:
+		case g.exits[index]&tbDrawExit != 0:
+			g.values[index] = tbDraw
+		default:
+			buckets[g.exitLoss[index]] = append(buckets[g.exitLoss[index]], int32(index))
+		}
+	}
+	return nil
+}
+
+// setBoard sets up the position on board and reports whether it is legal
+func (g *tbGenerator) setBoard(board *Board, squares []int, stm int) bool {
+	board.Reset()
+	occupied := uint64(0)
+	for i, piece := range g.material.pieces {
+		sq := squares[i]
+		if occupied&(1<<sq) != 0 || (piece.kind == 0 && (sq < 8 || sq >= 56)) {
+			return false
+		}
+		occupied |= 1 << sq
+		codes := whitePieceCodes
+		if piece.color == 1 {
+			codes = blackPieceCodes
+		}
+		board.SetPiece(byte(sq), codes[piece.kind])
+	}
+	board.WhiteToMove = stm == 0
+	// The side not to move cannot be in check
+	return !board.IsSquareAttacked(board.PassiveKingSquare(), !board.WhiteToMove)
+}
+
+// retrograde resolves the positions level by level, from the mates
+func (g *tbGenerator) retrograde() error {
+	for level := 0; level < len(g.buckets); level++ {
+		for _, index := range g.buckets[level] {
+			if g.values[index] != tbUnknown {
+				continue
+			}
+			g.values[index] = byte(level)
+			if err := g.propagate(int(index), level); err != nil {
+				return err
+			}
+		}
+		g.buckets[level] = nil
+	}
+	return nil
+}
+
+// propagate updates the positions with a move to the position at index, resolved
+// at level
+func (g *tbGenerator) propagate(index int, level int) error {
+	m := g.material
+	var squares [TablebaseMaxPieces]int
+	stm := m.decode(index, squares[:])
+	mover := stm ^ 1
+	occupied := uint64(0)
+	for _, sq := range squares[:len(m.pieces)] {
+		occupied |= 1 << sq
+	}
+	var predecessors [256]int
+	count := 0
+	for i, piece := range m.pieces {
+		if piece.color != mover {
+			continue
+		}
+		from := squares[i]
+		for targets := tbUnmoves(piece, from, occupied); targets != 0; targets &= targets - 1 {
+			squares[i] = bits.TrailingZeros64(targets)
+			predecessor := m.canonicalIndex(squares[:len(m.pieces)], mover)
+			if !containsInt(predecessors[:count], predecessor) {
+				predecessors[count] = predecessor
+				count++
+			}
+		}
+		squares[i] = from
+	}
+	for _, predecessor := range predecessors[:count] {
+		if g.values[predecessor] != tbUnknown {
+			continue
+		}
+		next := level + 1
+		if level%2 == 1 {
+			// One more move reaching a won position, lost once none is left
+			g.children[predecessor]--
+			if g.children[predecessor] > 0 || g.exits[predecessor] != 0 {
+				continue
+			}
+			next = MathMaxInt(next, int(g.exitLoss[predecessor]))
+		}
+		if next >= len(g.buckets) {
+			return fmt.Errorf("%s: distance to mate over %d plies", m.name, len(g.buckets)-1)
+		}
+		g.buckets[next] = append(g.buckets[next], int32(predecessor))
+	}
+	return nil
+}
+
+// tbUnmoves returns the squares a piece on sq may have come from without capturing
+// nor promoting, as a bitboard
+func tbUnmoves(piece tbPiece, sq int, occupied uint64) uint64 {
+	switch piece.kind {
+	case 0:
+		// Pawns of the first side move up the board (to lower squares)
+		step, doubleRow := 8, 4
+		if piece.color == 1 {
+			step, doubleRow = -8, 3
+		}
+		from := sq + step
+		if from < 8 || from >= 56 || occupied&(1<<from) != 0 {
+			return 0
+		}
+		targets := uint64(1) << from
+		if sq/8 == doubleRow && occupied&(1<<(from+step)) == 0 {
+			targets |= 1 << (from + step)
+		}
+		return targets
+	case 1:
+		return KnightAttacks[sq] &^ occupied
+	case tbKing:
+		return KingAttacks[sq] &^ occupied
+	}
+	first, last := 0, 8
+	switch piece.kind {
+	case 2:
+		first = 4
+	case 3:
+		last = 4
+	}
+	targets := uint64(0)
+	for dir := first; dir < last; dir++ {
+		for step, to := 0, sq; step < int(SquaresToEdge[sq][dir]); step++ {
+			to += int(BoardDirOffsets[dir])
+			if occupied&(1<<to) != 0 {
+				break
+			}
+			targets |= 1 << to
+		}
+	}
+	return targets
+}
+
+func containsInt(values []int, value int) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
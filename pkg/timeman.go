@@ -0,0 +1,138 @@
+package libra
+
+const (
+	DefaultMoveOverheadMs = 100 // Default "Move Overhead" UCI option (ms)
+	MaxMoveOverheadMs     = 5_000
+)
+
+// Tuning of the dynamic soft limit, see TimeManager.SoftLimit
+const (
+	timeStabilityMaxIterations = 6   // iterations after which the best move is considered stable
+	timeScoreDropMaxCp         = 200 // score drop (cp) that doubles the soft limit
+	timeBranchingFactor        = 2   // expected growth of an iteration compared to the previous one
+)
+
+// TimeManager decides how long a search may run.
+// It starts from the optimal (soft) and maximum (hard) budget computed by
+// CalcTimeLimit and rescales the soft limit after every completed iteration
+// based on best-move stability, score drops, number of legal root moves and game phase.
+type TimeManager struct {
+	OptimalTimeMs int  // Base soft limit: stop deepening after this (ms), 0 = no limit
+	MaxTimeMs     int  // Hard limit: abort in-flight search after this (ms), 0 = no limit
+	Dynamic       bool // Scale the soft limit, false for fixed time searches (e.g. "movetime")
+
+	rootMoves    int  // number of legal moves at the root
+	phase        int  // game phase at the root (0..TotalPhase)
+	bestMove     Move // best move of the last completed iteration
+	hasBestMove  bool
+	stability    int // consecutive iterations that returned the same best move
+	lastScore    int // score of the last completed iteration (root side's perspective)
+	hasLastScore bool
+	scoreDrop    int // how much the score dropped in the last completed iteration (cp)
+}
+
+// NewTimeManager creates a dynamic time manager from soft and hard limits in ms.
+func NewTimeManager(optimalTimeMs int, maxTimeMs int) *TimeManager {
+	return &TimeManager{
+		OptimalTimeMs: optimalTimeMs,
+		MaxTimeMs:     maxTimeMs,
+		Dynamic:       true,
+	}
+}
+
+// NewTimeManager creates a time manager for the side to move from the "go" command options.
+// Fixed "movetime" searches are not rescaled.
+func (opts *GoOptions) NewTimeManager(whiteToMove bool, moveOverheadMs int) *TimeManager {
+	optimalTime, maxTime := opts.CalcTimeLimit(whiteToMove, moveOverheadMs)
+	tm := NewTimeManager(optimalTime, maxTime)
+	tm.Dynamic = opts.MoveTime == 0
+	return tm
+}
+
+// SetRootPosition records the root information used to scale the soft limit.
+func (tm *TimeManager) SetRootPosition(rootMoves int, phase int) {
+	tm.rootMoves = rootMoves
+	tm.phase = phase
+}
+
+// Update records the result of a completed iteration.
+// score must be from the perspective of the side to move at the root.
+func (tm *TimeManager) Update(bestMove *Move, score int) {
+	if bestMove != nil {
+		if tm.hasBestMove && *bestMove == tm.bestMove {
+			tm.stability++
+		} else {
+			tm.stability = 0
+		}
+		tm.bestMove = *bestMove
+		tm.hasBestMove = true
+	}
+	tm.scoreDrop = 0
+	if tm.hasLastScore && score < tm.lastScore {
+		tm.scoreDrop = tm.lastScore - score
+	}
+	tm.lastScore = score
+	tm.hasLastScore = true
+}
+
+// SoftLimit returns the current scaled soft limit in ms, never above the hard limit.
+func (tm *TimeManager) SoftLimit() int {
+	if !tm.Dynamic || tm.OptimalTimeMs == 0 {
+		return tm.OptimalTimeMs
+	}
+	// A forced move does not need any thinking
+	if tm.rootMoves == 1 {
+		return 0
+	}
+
+	// Stable best move: from 1.3x down to 0.4x the optimal time
+	stability := MathMinInt(tm.stability, timeStabilityMaxIterations)
+	factor := 1.3 - 0.15*float64(stability)
+
+	// Score drop: up to 2x when the score falls by timeScoreDropMaxCp or more
+	factor *= 1.0 + float64(MathMinInt(tm.scoreDrop, timeScoreDropMaxCp))/timeScoreDropMaxCp
+
+	// Few legal moves: less to think about, from 0.7x (2 moves) to 1x (20+ moves)
+	if tm.rootMoves > 0 {
+		factor *= 0.7 + 0.3*float64(MathMinInt(tm.rootMoves, 20))/20
+	}
+
+	// Game phase: from 0.8x (pawn endgame) to 1.2x (all pieces on the board)
+	factor *= 0.8 + 0.4*float64(MathMinInt(tm.phase, TotalPhase))/TotalPhase
+
+	softLimit := int(float64(tm.OptimalTimeMs) * factor)
+	if tm.MaxTimeMs > 0 && softLimit > tm.MaxTimeMs {
+		softLimit = tm.MaxTimeMs
+	}
+	return softLimit
+}
+
+// CanStartIteration reports whether a new iteration should be started after
+// elapsedMs, given the last iteration took lastIterationMs. An iteration that is
+// predicted not to finish before the hard limit is not started.
+func (tm *TimeManager) CanStartIteration(elapsedMs int, lastIterationMs int) bool {
+	if tm.OptimalTimeMs == 0 && tm.MaxTimeMs == 0 {
+		return true
+	}
+	if elapsedMs >= tm.SoftLimit() {
+		return false
+	}
+	if tm.MaxTimeMs > 0 {
+		if elapsedMs >= tm.MaxTimeMs {
+			return false
+		}
+		if tm.Dynamic && elapsedMs+lastIterationMs*timeBranchingFactor > tm.MaxTimeMs {
+			return false
+		}
+	}
+	return true
+}
+
+// RemainingMs returns how long an iteration started after elapsedMs may run
+// before it is aborted, 0 means no limit.
+func (tm *TimeManager) RemainingMs(elapsedMs int) int {
+	if tm.MaxTimeMs == 0 {
+		return 0
+	}
+	return MathMaxInt(tm.MaxTimeMs-elapsedMs, 1)
+}
@@ -0,0 +1,192 @@
+package libra
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+)
+
+// TraceCutoff is the reason a traced node returned before searching all its moves
+type TraceCutoff string
+
+const (
+	CutoffNone        TraceCutoff = ""            // all moves were searched
+	CutoffBeta        TraceCutoff = "beta"        // a move failed high for the side to move
+	CutoffTT          TraceCutoff = "tt"          // transposition table score was used
+	CutoffStandPat    TraceCutoff = "stand-pat"   // quiescence static evaluation failed high
+	CutoffMate        TraceCutoff = "mate"        // no legal moves and in check
+	CutoffStalemate   TraceCutoff = "stalemate"   // no legal moves and not in check
+	CutoffInterrupted TraceCutoff = "interrupted" // search was stopped
+)
+
+// TraceNode is a node visited by AlphaBetaSearch or QuiescenceSearch.
+// Scores are from white's perspective, like everywhere else in the search.
+type TraceNode struct {
+	ID         int         `json:"id"`
+	Parent     int         `json:"parent"` // -1 for the root of an iteration
+	Path       []string    `json:"path"`   // UCI moves from the root
+	Ply        int         `json:"ply"`
+	Depth      int         `json:"depth"` // remaining depth, 0 in quiescence search
+	Quiescence bool        `json:"quiescence"`
+	Alpha      int         `json:"alpha"`
+	Beta       int         `json:"beta"`
+	StaticEval int         `json:"staticEval"`
+	TTHit      bool        `json:"ttHit"`
+	Score      int         `json:"score"`
+	BestMove   string      `json:"bestMove,omitempty"`
+	Cutoff     TraceCutoff `json:"cutoff,omitempty"`
+}
+
+// SearchTracer is an opt-in hook notified of every node entered and exited by the search.
+// Implementations must be safe for concurrent use, root moves are searched in parallel.
+type SearchTracer interface {
+	// EnterNode records a node and returns its id, or -1 to skip the node and its subtree
+	EnterNode(node TraceNode) int
+	// TTHit marks a recorded node as having found its position in the transposition table
+	TTHit(id int)
+	// ExitNode records the result of a recorded node
+	ExitNode(id int, score int, bestMove string, cutoff TraceCutoff)
+}
+
+// SearchTrace is a SearchTracer that keeps the nodes in memory, limited by ply and node count,
+// and exports them to JSON or Graphviz DOT.
+type SearchTrace struct {
+	MaxPly   int // Deepest ply recorded, 0 = no limit
+	MaxNodes int // Maximum number of nodes recorded, 0 = no limit
+	Nodes    []TraceNode
+
+	mu sync.Mutex
+}
+
+// NewSearchTrace creates a trace recording nodes up to maxPly and at most maxNodes nodes
+func NewSearchTrace(maxPly int, maxNodes int) *SearchTrace {
+	return &SearchTrace{MaxPly: maxPly, MaxNodes: maxNodes}
+}
+
+func (trace *SearchTrace) EnterNode(node TraceNode) int {
+	trace.mu.Lock()
+	defer trace.mu.Unlock()
+	if trace.MaxPly > 0 && node.Ply > trace.MaxPly {
+		return -1
+	}
+	if trace.MaxNodes > 0 && len(trace.Nodes) >= trace.MaxNodes {
+		return -1
+	}
+	node.ID = len(trace.Nodes)
+	trace.Nodes = append(trace.Nodes, node)
+	return node.ID
+}
+
+func (trace *SearchTrace) TTHit(id int) {
+	trace.mu.Lock()
+	defer trace.mu.Unlock()
+	trace.Nodes[id].TTHit = true
+}
+
+func (trace *SearchTrace) ExitNode(id int, score int, bestMove string, cutoff TraceCutoff) {
+	trace.mu.Lock()
+	defer trace.mu.Unlock()
+	trace.Nodes[id].Score = score
+	trace.Nodes[id].BestMove = bestMove
+	trace.Nodes[id].Cutoff = cutoff
+}
+
+// WriteJSON writes the recorded nodes as a JSON array
+func (trace *SearchTrace) WriteJSON(w io.Writer) error {
+	trace.mu.Lock()
+	defer trace.mu.Unlock()
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(trace.Nodes)
+}
+
+// WriteDOT writes the recorded nodes as a Graphviz DOT digraph.
+// Quiescence nodes are drawn as ellipses, cutoffs in red and TT hits in blue.
+func (trace *SearchTrace) WriteDOT(w io.Writer) error {
+	trace.mu.Lock()
+	defer trace.mu.Unlock()
+	var sb strings.Builder
+	sb.WriteString("digraph search {\n")
+	sb.WriteString("\tnode [shape=box, fontname=\"monospace\", fontsize=10];\n")
+	for _, node := range trace.Nodes {
+		move := "root"
+		if len(node.Path) > 0 {
+			move = node.Path[len(node.Path)-1]
+		}
+		label := fmt.Sprintf("%s\\nd=%d [%d, %d]\\neval=%d score=%d", move, node.Depth, node.Alpha, node.Beta, node.StaticEval, node.Score)
+		if node.BestMove != "" {
+			label += "\\nbest=" + node.BestMove
+		}
+		if node.Cutoff != CutoffNone {
+			label += "\\ncutoff=" + string(node.Cutoff)
+		}
+		attrs := fmt.Sprintf("label=\"%s\"", label)
+		if node.Quiescence {
+			attrs += ", shape=ellipse"
+		}
+		switch {
+		case node.TTHit:
+			attrs += ", color=blue"
+		case node.Cutoff != CutoffNone:
+			attrs += ", color=red"
+		}
+		fmt.Fprintf(&sb, "\tn%d [%s];\n", node.ID, attrs)
+		if node.Parent >= 0 {
+			fmt.Fprintf(&sb, "\tn%d -> n%d;\n", node.Parent, node.ID)
+		}
+	}
+	sb.WriteString("}\n")
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+// traceEnter records the node at ply if a tracer is set and its parent was recorded.
+// Returns the node id, or -1 if the node is not traced.
+func (info *SearchContext) traceEnter(board *Board, ply int, depth int, alpha int, beta int, quiescence bool) int {
+	if info.Tracer == nil || ply >= MaxPly {
+		return -1
+	}
+	parent := info.traceIDs[ply-1]
+	if parent < 0 {
+		info.traceIDs[ply] = -1
+		return -1
+	}
+	path := make([]string, ply)
+	for i := 0; i < ply; i++ {
+		path[i] = info.MoveStack[i].ToUCI()
+	}
+	id := info.Tracer.EnterNode(TraceNode{
+		Parent:     parent,
+		Path:       path,
+		Ply:        ply,
+		Depth:      depth,
+		Quiescence: quiescence,
+		Alpha:      alpha,
+		Beta:       beta,
+		StaticEval: board.Evaluate(),
+	})
+	info.traceIDs[ply] = id
+	return id
+}
+
+// traceTTHit marks a traced node as a transposition table hit
+func (info *SearchContext) traceTTHit(id int) {
+	if id >= 0 {
+		info.Tracer.TTHit(id)
+	}
+}
+
+// traceExit records the result of a traced node and returns score unchanged
+func (info *SearchContext) traceExit(id int, score int, bestMove *Move, cutoff TraceCutoff) int {
+	if id < 0 {
+		return score
+	}
+	best := ""
+	if bestMove != nil && *bestMove != (Move{}) {
+		best = bestMove.ToUCI()
+	}
+	info.Tracer.ExitNode(id, score, best, cutoff)
+	return score
+}
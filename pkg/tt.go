@@ -1,6 +1,9 @@
 package libra
 
-import "sync"
+import (
+	"math/bits"
+	"sync/atomic"
+)
 
 const (
 	BoundExact = iota
@@ -8,61 +11,331 @@ const (
 	BoundUpper // score is an upper bound (failed low)
 )
 
+const (
+	DefaultHashMB = 16     // Default "Hash" UCI option (MB)
+	MaxHashMB     = 32_768 // Largest "Hash" UCI option (MB)
+	TTBucketSize  = 4      // Entries per bucket, a bucket fills a 64 byte cache line
+)
+
+const (
+	NoStaticEval    = -32768 // Static eval of entries stored without one
+	ttGenerations   = 64     // Generation counter wraps around after this many searches
+	ttAgeWeight     = 8      // Replacement value lost per generation of age, in plies of depth
+	ttPVWeight      = 2      // Replacement value gained by PV entries, in plies of depth
+	ttSameKeyMargin = 3      // A same position entry is kept if deeper by more than this
+)
+
 type TTEntry struct {
-	Score    int
-	BestMove Move
-	Depth    int
-	Bound    byte
+	Score      int
+	BestMove   Move
+	Depth      int
+	Bound      byte
+	StaticEval int  // Static evaluation (white's perspective), NoStaticEval if unknown
+	IsPV       bool // The position was searched with an exact score
+	Generation byte // Search that stored the entry
+}
+
+// ttSlot is a 16 byte entry: the data word and the key word XOR the data word.
+// The key word holds the low 48 bits of the hash and, in place of the top 16
+// bits, the static eval. Buckets are picked by the top bits of the hash, so from
+// 65536 buckets (4 MB) up the bucket and the key word check the whole hash.
+// Both words are written without locks, a torn write by concurrent threads
+// makes the XOR check fail and the entry is treated as a miss.
+type ttSlot struct {
+	key  atomic.Uint64
+	data atomic.Uint64
 }
 
+type ttBucket [TTBucketSize]ttSlot
+
+// TranspositionTable is a fixed size, power of two table of cache-line buckets
+// shared by all search threads without locks. Resize and Clear must not be called
+// while a search is running.
 type TranspositionTable struct {
-	table map[uint64]TTEntry
-	mu    sync.RWMutex
+	buckets    []ttBucket
+	shift      int // The top 64 - shift bits of the hash are the bucket index
+	generation byte
+	drawScore  int // Draw score of the stored scores, see SetDrawScore
 }
 
+// NewTranspositionTable creates a table of DefaultHashMB
 func NewTranspositionTable() *TranspositionTable {
-	return &TranspositionTable{
-		table: make(map[uint64]TTEntry),
+	return NewTranspositionTableMB(DefaultHashMB)
+}
+
+// NewTranspositionTableMB creates a table using at most mb megabytes
+func NewTranspositionTableMB(mb int) *TranspositionTable {
+	tt := &TranspositionTable{}
+	tt.Resize(mb)
+	return tt
+}
+
+// Resize reallocates the table to use at most mb megabytes, discarding all entries
+func (tt *TranspositionTable) Resize(mb int) {
+	mb = MathMaxInt(1, MathMinInt(mb, MaxHashMB))
+	bucketCount := uint64(mb) * 1024 * 1024 / 64
+	// Round down to a power of two so the index is the top bits of the hash
+	bucketCount = 1 << (63 - bits.LeadingZeros64(bucketCount))
+	tt.buckets = make([]ttBucket, bucketCount)
+	tt.shift = ttBucketShift(bucketCount)
+}
+
+// ttBucketShift returns the shift of the hash leaving the index of one of
+// bucketCount buckets, a power of two
+func ttBucketShift(bucketCount uint64) int {
+	return 64 - bits.TrailingZeros64(bucketCount)
+}
+
+// SizeMB returns the memory used by the table in megabytes
+func (tt *TranspositionTable) SizeMB() int {
+	return len(tt.buckets) * 64 / (1024 * 1024)
+}
+
+// Capacity returns the number of entries the table can hold
+func (tt *TranspositionTable) Capacity() int {
+	return len(tt.buckets) * TTBucketSize
+}
+
+func (tt *TranspositionTable) bucket(hash uint64) *ttBucket {
+	return &tt.buckets[hash>>tt.shift]
+}
+
+// NewSearch starts a new generation, entries of previous searches become
+// the first candidates for replacement
+func (tt *TranspositionTable) NewSearch() {
+	tt.generation = (tt.generation + 1) % ttGenerations
+}
+
+// SetDrawScore clears the table when drawScore differs from the draw score of the
+// stored scores. Draws are scored from white's perspective with the contempt of
+// the root side, so the scores of a search from the other side, or with another
+// contempt, would carry the wrong bias.
+func (tt *TranspositionTable) SetDrawScore(drawScore int) {
+	if drawScore != tt.drawScore {
+		tt.Clear()
+		tt.drawScore = drawScore
+	}
+}
+
+// Generation returns the generation of the current search
+func (tt *TranspositionTable) Generation() byte {
+	return tt.generation
+}
+
+// probe returns the data word and static eval stored for hash, if any
+func (tt *TranspositionTable) probe(hash uint64) (uint64, int, bool) {
+	bucket := tt.bucket(hash)
+	for i := range bucket {
+		data := bucket[i].data.Load()
+		key := bucket[i].key.Load() ^ data
+		if data != 0 && key<<16 == hash<<16 {
+			return data, int(int16(key >> 48)), true
+		}
+	}
+	return 0, NoStaticEval, false
+}
+
+// Probe returns the entry stored for hash regardless of its depth
+func (tt *TranspositionTable) Probe(hash uint64) (TTEntry, bool) {
+	data, staticEval, ok := tt.probe(hash)
+	if !ok {
+		return TTEntry{}, false
 	}
+	entry := unpackTTEntry(data)
+	entry.StaticEval = staticEval
+	return entry, true
 }
 
+// Get returns the entry stored for hash if it was searched at least to depth
 func (tt *TranspositionTable) Get(hash uint64, depth int) (TTEntry, bool) {
-	tt.mu.RLock()
-	defer tt.mu.RUnlock()
-	entry, ok := tt.table[hash]
+	entry, ok := tt.Probe(hash)
 	if !ok || entry.Depth < depth {
 		return TTEntry{}, false
 	}
 	return entry, true
 }
 
-func (tt *TranspositionTable) Set(hash uint64, depth int, value int, bestMove Move, bound byte) {
-	tt.mu.Lock()
-	defer tt.mu.Unlock()
-	entry, ok := tt.table[hash]
-	if !ok || depth >= entry.Depth {
-		tt.table[hash] = TTEntry{Score: value, BestMove: bestMove, Depth: depth, Bound: bound}
+// Set stores an entry. The entry of the same position is replaced unless it is from
+// the current search, not exact and clearly deeper. Otherwise an empty slot or the
+// slot with the lowest depth, minus age and plus a PV bonus, is replaced.
+func (tt *TranspositionTable) Set(hash uint64, depth int, value int, bestMove Move, bound byte, staticEval int, isPV bool) {
+	bucket := tt.bucket(hash)
+	replace := &bucket[0]
+	replaceValue := 1 << 30
+	for i := range bucket {
+		slot := &bucket[i]
+		data := slot.data.Load()
+		if data == 0 {
+			if replaceValue > -1<<30 {
+				replace = slot
+				replaceValue = -1 << 30
+			}
+			continue
+		}
+		if (slot.key.Load()^data)<<16 == hash<<16 {
+			old := unpackTTEntry(data)
+			if bound != BoundExact && old.Generation == tt.generation && depth+ttSameKeyMargin < old.Depth {
+				return
+			}
+			// Keep the known best move of a position that failed low
+			if bestMove == (Move{}) {
+				bestMove = old.BestMove
+			}
+			// A position once on a principal variation stays a PV position
+			isPV = isPV || (old.IsPV && old.Generation == tt.generation)
+			replace = slot
+			break
+		}
+		if value := tt.replacementValue(data); value < replaceValue {
+			replace = slot
+			replaceValue = value
+		}
+	}
+	data := packTTEntry(value, bestMove, depth, bound, tt.generation, isPV)
+	if staticEval != NoStaticEval {
+		staticEval = MathMaxInt(NoStaticEval+1, MathMinInt(staticEval, 32767))
 	}
+	key := hash&(1<<48-1) | uint64(uint16(int16(staticEval)))<<48
+	replace.key.Store(key ^ data)
+	replace.data.Store(data)
 }
 
+// replacementValue rates how much an entry is worth keeping
+func (tt *TranspositionTable) replacementValue(data uint64) int {
+	entry := unpackTTEntry(data)
+	age := int((tt.generation - entry.Generation + ttGenerations) % ttGenerations)
+	value := entry.Depth - ttAgeWeight*age
+	if entry.IsPV {
+		value += ttPVWeight
+	}
+	return value
+}
+
+// Clear removes all entries
 func (tt *TranspositionTable) Clear() {
-	tt.mu.Lock()
-	defer tt.mu.Unlock()
-	tt.table = make(map[uint64]TTEntry)
+	clear(tt.buckets)
+	tt.generation = 0
 }
 
+// Size returns the number of entries in use, scanning the whole table
 func (tt *TranspositionTable) Size() int {
-	tt.mu.RLock()
-	defer tt.mu.RUnlock()
-	return len(tt.table)
+	size := 0
+	for i := range tt.buckets {
+		for j := range tt.buckets[i] {
+			if tt.buckets[i][j].data.Load() != 0 {
+				size++
+			}
+		}
+	}
+	return size
+}
+
+// Hashfull returns the usage by the current search in permille, as reported by
+// UCI "hashfull", sampled from the first 1000 entries
+func (tt *TranspositionTable) Hashfull() int {
+	sampleBuckets := MathMinInt(1000/TTBucketSize, len(tt.buckets))
+	used := 0
+	for i := 0; i < sampleBuckets; i++ {
+		for j := range tt.buckets[i] {
+			data := tt.buckets[i][j].data.Load()
+			if data != 0 && unpackTTEntry(data).Generation == tt.generation {
+				used++
+			}
+		}
+	}
+	return used * 1000 / (sampleBuckets * TTBucketSize)
 }
 
 func (tt *TranspositionTable) BestMoveDeepest(hash uint64) *Move {
-	tt.mu.RLock()
-	defer tt.mu.RUnlock()
-	entry, ok := tt.table[hash]
+	data, _, ok := tt.probe(hash)
 	if !ok {
 		return nil
 	}
-	return &entry.BestMove
+	move := unpackTTEntry(data).BestMove
+	return &move
+}
+
+/*
+Data word layout (bits, low to high):
+
+| Field    | Bits | Notes                                          |
+|----------|------|------------------------------------------------|
+| Move     | 27   | piece 4, from 6, to 6, type 3, promo 4, capt 4 |
+| Depth    | 7    | 0 to 127, never 0 for a stored entry           |
+| Bound    | 2    | BoundExact, BoundLower or BoundUpper           |
+| Score    | 21   | signed, ±MaxEvaluationScore, mates by entry    |
+| Gen      | 6    | generation of the search that stored the entry |
+| PV       | 1    | exact score, on a principal variation          |
+*/
+const (
+	ttMoveBits  = 27
+	ttDepthBits = 7
+	ttBoundBits = 2
+	ttScoreBits = 21
+	ttGenBits   = 6
+
+	ttDepthShift = ttMoveBits
+	ttBoundShift = ttDepthShift + ttDepthBits
+	ttScoreShift = ttBoundShift + ttBoundBits
+	ttGenShift   = ttScoreShift + ttScoreBits
+	ttPVShift    = ttGenShift + ttGenBits
+)
+
+// ttPieceCodes maps a 4 bit piece index (PieceToHistoryIndex) back to the piece code
+var ttPieceCodes [HistoryPieces]byte
+
+// ttPieceIndexes maps a piece code to its 4 bit index, 0 for no piece
+var ttPieceIndexes [256]uint64
+
+func init() {
+	for piece, index := range PieceToHistoryIndex {
+		ttPieceCodes[index] = piece
+		ttPieceIndexes[piece] = uint64(index)
+	}
+}
+
+func packTTMove(move Move) uint64 {
+	return ttPieceIndexes[move.Piece] |
+		uint64(move.From)<<4 |
+		uint64(move.To)<<10 |
+		uint64(move.MoveType)<<16 |
+		ttPieceIndexes[move.Promoted]<<19 |
+		ttPieceIndexes[move.Captured]<<23
+}
+
+func unpackTTMove(data uint64) Move {
+	return Move{
+		Piece:    ttPieceCodes[data&0xF],
+		From:     byte(data >> 4 & 0x3F),
+		To:       byte(data >> 10 & 0x3F),
+		MoveType: byte(data >> 16 & 0x7),
+		Promoted: ttPieceCodes[data>>19&0xF],
+		Captured: ttPieceCodes[data>>23&0xF],
+	}
+}
+
+func packTTEntry(score int, bestMove Move, depth int, bound byte, generation byte, isPV bool) uint64 {
+	depth = MathMaxInt(1, MathMinInt(depth, 1<<ttDepthBits-1))
+	data := packTTMove(bestMove) |
+		uint64(depth)<<ttDepthShift |
+		uint64(bound)<<ttBoundShift |
+		(uint64(score)&(1<<ttScoreBits-1))<<ttScoreShift |
+		uint64(generation)<<ttGenShift
+	if isPV {
+		data |= 1 << ttPVShift
+	}
+	return data
+}
+
+func unpackTTEntry(data uint64) TTEntry {
+	// Shift the score to the top bits and back to sign extend it
+	score := int(int64(data<<(64-ttScoreShift-ttScoreBits)) >> (64 - ttScoreBits))
+	return TTEntry{
+		Score:      score,
+		BestMove:   unpackTTMove(data & (1<<ttMoveBits - 1)),
+		Depth:      int(data >> ttDepthShift & (1<<ttDepthBits - 1)),
+		Bound:      byte(data >> ttBoundShift & (1<<ttBoundBits - 1)),
+		IsPV:       data>>ttPVShift&1 == 1,
+		Generation: byte(data >> ttGenShift & (1<<ttGenBits - 1)),
+	}
 }
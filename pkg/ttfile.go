@@ -0,0 +1,168 @@
+package libra
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"math/bits"
+	"os"
+)
+
+const DefaultHashFile = "libra.hash" // Default "Hash File" UCI option
+
+/*
+Transposition table file layout, all integers little endian:
+
+| Field       | Size     | Notes                                    |
+|-------------|----------|------------------------------------------|
+| Magic       | 8        | "LIBRATT\x00"                            |
+| Version     | 4        | ttFileVersion                            |
+| Buckets     | 8        | power of two                             |
+| Generation  | 1        | generation of the last search            |
+| Draw score  | 4        | signed, draw score of the stored scores  |
+| Slots       | 16 each  | key word, data word, bucket by bucket    |
+| CRC-32      | 4        | IEEE checksum of everything before it    |
+*/
+const (
+	ttFileMagic      = "LIBRATT\x00"
+	ttFileVersion    = 3
+	ttFileHeaderSize = 25
+	ttFileBucketSize = 16 * TTBucketSize
+	ttLoadChunk      = 1 << 16 // Buckets allocated at a time when the size of the data is unknown
+)
+
+var (
+	ErrTTFileMagic    = errors.New("not a transposition table file")
+	ErrTTFileVersion  = errors.New("unsupported transposition table file version")
+	ErrTTFileChecksum = errors.New("transposition table file is corrupted")
+)
+
+// Save writes the table, with its size, generation and draw score, to w
+func (tt *TranspositionTable) Save(w io.Writer) error {
+	buffered := bufio.NewWriter(w)
+	checksum := crc32.NewIEEE()
+	out := io.MultiWriter(buffered, checksum)
+
+	header := make([]byte, 0, ttFileHeaderSize)
+	header = append(header, ttFileMagic...)
+	header = binary.LittleEndian.AppendUint32(header, ttFileVersion)
+	header = binary.LittleEndian.AppendUint64(header, uint64(len(tt.buckets)))
+	header = append(header, tt.generation)
+	header = binary.LittleEndian.AppendUint32(header, uint32(int32(tt.drawScore)))
+	if _, err := out.Write(header); err != nil {
+		return err
+	}
+
+	var slot [16]byte
+	for i := range tt.buckets {
+		for j := range tt.buckets[i] {
+			binary.LittleEndian.PutUint64(slot[:8], tt.buckets[i][j].key.Load())
+			binary.LittleEndian.PutUint64(slot[8:], tt.buckets[i][j].data.Load())
+			if _, err := out.Write(slot[:]); err != nil {
+				return err
+			}
+		}
+	}
+	if err := binary.Write(buffered, binary.LittleEndian, checksum.Sum32()); err != nil {
+		return err
+	}
+	return buffered.Flush()
+}
+
+// Load replaces the table with the one read from r, resizing it to the saved size.
+// The table is left unchanged if the data is invalid.
+func (tt *TranspositionTable) Load(r io.Reader) error {
+	return tt.load(r, -1)
+}
+
+// load reads a table of size bytes, or of an unknown size when negative. The
+// bucket count of the header is only trusted as far as the data goes: it is
+// checked against a known size, and otherwise the buckets are allocated as they
+// are read, so a corrupted header cannot allocate more than the data holds.
+func (tt *TranspositionTable) load(r io.Reader, size int64) error {
+	buffered := bufio.NewReader(r)
+	checksum := crc32.NewIEEE()
+	in := io.TeeReader(buffered, checksum)
+
+	header := make([]byte, ttFileHeaderSize)
+	if _, err := io.ReadFull(in, header); err != nil {
+		return fmt.Errorf("%w: %v", ErrTTFileMagic, err)
+	}
+	if string(header[:8]) != ttFileMagic {
+		return ErrTTFileMagic
+	}
+	if version := binary.LittleEndian.Uint32(header[8:12]); version != ttFileVersion {
+		return fmt.Errorf("%w: %d", ErrTTFileVersion, version)
+	}
+	bucketCount := binary.LittleEndian.Uint64(header[12:20])
+	maxBuckets := uint64(MaxHashMB) * 1024 * 1024 / 64
+	if bucketCount == 0 || bucketCount > maxBuckets || bits.OnesCount64(bucketCount) != 1 {
+		return fmt.Errorf("%w: invalid size", ErrTTFileChecksum)
+	}
+	if size >= 0 && ttFileHeaderSize+bucketCount*ttFileBucketSize+4 > uint64(size) {
+		return fmt.Errorf("%w: %d buckets in %d bytes", ErrTTFileChecksum, bucketCount, size)
+	}
+	generation := header[20] % ttGenerations
+	drawScore := int(int32(binary.LittleEndian.Uint32(header[21:25])))
+
+	capacity := bucketCount
+	if size < 0 && capacity > ttLoadChunk {
+		capacity = ttLoadChunk
+	}
+	buckets := make([]ttBucket, 0, capacity)
+	var slot [16]byte
+	for uint64(len(buckets)) < bucketCount {
+		buckets = append(buckets, ttBucket{})
+		bucket := &buckets[len(buckets)-1]
+		for j := range bucket {
+			if _, err := io.ReadFull(in, slot[:]); err != nil {
+				return fmt.Errorf("%w: %v", ErrTTFileChecksum, err)
+			}
+			bucket[j].key.Store(binary.LittleEndian.Uint64(slot[:8]))
+			bucket[j].data.Store(binary.LittleEndian.Uint64(slot[8:]))
+		}
+	}
+	var expected uint32
+	if err := binary.Read(buffered, binary.LittleEndian, &expected); err != nil {
+		return fmt.Errorf("%w: %v", ErrTTFileChecksum, err)
+	}
+	if expected != checksum.Sum32() {
+		return ErrTTFileChecksum
+	}
+
+	tt.buckets = buckets
+	tt.shift = ttBucketShift(bucketCount)
+	tt.generation = generation
+	tt.drawScore = drawScore
+	return nil
+}
+
+// SaveFile writes the table to the file at path
+func (tt *TranspositionTable) SaveFile(path string) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := tt.Save(file); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// LoadFile replaces the table with the one saved in the file at path
+func (tt *TranspositionTable) LoadFile(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+	return tt.load(file, info.Size())
+}
@@ -0,0 +1,307 @@
+package libra
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const tuneQuietPlies = 16 // Longest capture sequence followed to resolve a position
+
+var ErrInvalidTuneEntry = errors.New("invalid tuning position")
+
+// TuneEntry is a labeled training position, resolved to a quiet position
+type TuneEntry struct {
+	Board  *Board
+	Result float64 // Game result for white: 1 win, 0.5 draw, 0 loss
+	Score  int     // Evaluation label for white (cp), used instead of Result when Scored
+	Scored bool
+}
+
+var (
+	tuneResultPattern  = regexp.MustCompile(`(1/2-1/2|1-0|0-1)`)
+	tuneBracketPattern = regexp.MustCompile(`\[\s*([01](?:\.\d*)?|0?\.\d+)\s*\]`)
+	tuneScorePattern   = regexp.MustCompile(`\bce\s+(-?\d+)`)
+	tuneTextPattern    = regexp.MustCompile(`^\|\s*(-?\d+)\s*\|\s*([01](?:\.\d*)?)\s*$`)
+)
+
+// ParseTuneEntry parses a FEN or EPD line followed by its label: a result ("1-0",
+// "0-1", "1/2-1/2", also inside an EPD c9 opcode), a bracketed white score from 0 to 1
+// ("[0.5]"), an EPD "ce" evaluation in centipawns for the side to move, or the
+// "| score | result" labels written by cmd/datagen, both white's, the result being used
+func ParseTuneEntry(line string) (TuneEntry, error) {
+	fields := strings.Fields(line)
+	if len(fields) < 4 {
+		return TuneEntry{}, fmt.Errorf("%w: %q", ErrInvalidTuneEntry, line)
+	}
+	fenFields := fields[:4]
+	rest := strings.Join(fields[4:], " ")
+	if len(fields) >= 6 && isNumber(fields[4]) && isNumber(fields[5]) {
+		fenFields = fields[:6]
+		rest = strings.Join(fields[6:], " ")
+	}
+	board := NewBoard()
+	if _, err := board.FromFEN(strings.Join(fenFields, " ")); err != nil {
+		return TuneEntry{}, fmt.Errorf("%w: %v", ErrInvalidTuneEntry, err)
+	}
+	if board.WhiteKing == 0 || board.BlackKing == 0 {
+		return TuneEntry{}, fmt.Errorf("%w: missing king", ErrInvalidTuneEntry)
+	}
+
+	entry := TuneEntry{Board: board}
+	if match := tuneTextPattern.FindStringSubmatch(rest); match != nil {
+		entry.Score, _ = strconv.Atoi(match[1])
+		entry.Result, _ = strconv.ParseFloat(match[2], 64)
+	} else if match := tuneResultPattern.FindString(rest); match != "" {
+		entry.Result = map[string]float64{"1-0": 1, "0-1": 0, "1/2-1/2": 0.5}[match]
+	} else if match := tuneBracketPattern.FindStringSubmatch(rest); match != nil {
+		entry.Result, _ = strconv.ParseFloat(match[1], 64)
+	} else if match := tuneScorePattern.FindStringSubmatch(rest); match != nil {
+		entry.Score, _ = strconv.Atoi(match[1])
+		if !board.WhiteToMove {
+			entry.Score = -entry.Score
+		}
+		entry.Scored = true
+	} else {
+		return TuneEntry{}, fmt.Errorf("%w: no result or score in %q", ErrInvalidTuneEntry, line)
+	}
+	return entry, nil
+}
+
+func isNumber(s string) bool {
+	_, err := strconv.Atoi(s)
+	return err == nil
+}
+
+// LoadTuneEntries reads one labeled position per line, skipping empty lines,
+// lines starting with '#' and positions with the side to move in check. Every
+// position is replaced by the leaf of its quiescence search principal variation
+// under the weights in use, so the tuned static evaluation sees no hanging captures.
+func LoadTuneEntries(r io.Reader) ([]TuneEntry, error) {
+	var entries []TuneEntry
+	scanner := bufio.NewScanner(r)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		entry, err := ParseTuneEntry(line)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", lineNumber, err)
+		}
+		if entry.Board.IsInCheck() {
+			continue
+		}
+		entry.Board.ResolveQuiet()
+		entries = append(entries, entry)
+	}
+	return entries, scanner.Err()
+}
+
+// ResolveQuiet plays the captures of the quiescence search principal variation
+func (board *Board) ResolveQuiet() {
+	_, pv := board.quietPV(board.WhiteToMove, -MaxEvaluationScore, MaxEvaluationScore, 0)
+	for _, move := range pv {
+		board.Move(move)
+	}
+}
+
+// quietPV is a quiescence search returning its principal variation
+func (board *Board) quietPV(maximizing bool, alpha int, beta int, ply int) (int, []Move) {
+	standPat := board.EvaluateClassical()
+	if ply >= tuneQuietPlies {
+		return standPat, nil
+	}
+	if maximizing {
+		if standPat >= beta {
+			return beta, nil
+		}
+		alpha = MathMaxInt(alpha, standPat)
+	} else {
+		if standPat <= alpha {
+			return alpha, nil
+		}
+		beta = MathMinInt(beta, standPat)
+	}
+
+	var pv []Move
+	for _, move := range board.SortCaptures(board.GenerateLegalCaptures()) {
+		prev := board.Move(move)
+		score, line := board.quietPV(!maximizing, alpha, beta, ply+1)
+		board.UndoMove(prev)
+		if maximizing && score > alpha {
+			alpha = score
+			pv = append([]Move{move}, line...)
+		} else if !maximizing && score < beta {
+			beta = score
+			pv = append([]Move{move}, line...)
+		}
+		if alpha >= beta {
+			break
+		}
+	}
+	if maximizing {
+		return alpha, pv
+	}
+	return beta, pv
+}
+
+// TuneParam is one tunable weight of an EvalParams
+type TuneParam struct {
+	Name  string // JSON path of the weight, e.g. "pstMg[1][27]" or "bishopPair.eg"
+	Value *int
+}
+
+// TunableParams returns the weights of params the tuner may change, pointing into params
+func TunableParams(params *EvalParams) []TuneParam {
+	var tunable []TuneParam
+	value := reflect.ValueOf(params).Elem()
+	for i := 0; i < value.NumField(); i++ {
+		field := value.Type().Field(i)
+		if field.Tag.Get("tune") == "-" {
+			continue
+		}
+		name := strings.Split(field.Tag.Get("json"), ",")[0]
+		tunable = collectTuneParams(tunable, name, value.Field(i))
+	}
+	return tunable
+}
+
+func collectTuneParams(tunable []TuneParam, name string, value reflect.Value) []TuneParam {
+	switch value.Kind() {
+	case reflect.Int:
+		return append(tunable, TuneParam{name, value.Addr().Interface().(*int)})
+	case reflect.Array:
+		for i := 0; i < value.Len(); i++ {
+			tunable = collectTuneParams(tunable, fmt.Sprintf("%s[%d]", name, i), value.Index(i))
+		}
+	case reflect.Struct:
+		for i := 0; i < value.NumField(); i++ {
+			tag := strings.Split(value.Type().Field(i).Tag.Get("json"), ",")[0]
+			tunable = collectTuneParams(tunable, name+"."+tag, value.Field(i))
+		}
+	}
+	return tunable
+}
+
+// Tuner fits evaluation weights to labeled positions by minimizing the mean squared
+// error between the labels and the sigmoid of the static evaluation (Texel tuning)
+type Tuner struct {
+	Entries []TuneEntry
+	K       float64 // Sigmoid scale, see FitK
+	Threads int     // Goroutines evaluating the positions
+	Step    int     // Change tried on every weight by Tune
+}
+
+// NewTuner creates a tuner with K = 1, a step of 1 and threads goroutines
+func NewTuner(entries []TuneEntry, threads int) *Tuner {
+	return &Tuner{Entries: entries, K: 1, Threads: MathMaxInt(1, threads), Step: 1}
+}
+
+// Sigmoid maps a white score in centipawns to the expected white result
+func (tuner *Tuner) Sigmoid(score float64) float64 {
+	return 1 / (1 + math.Pow(10, -tuner.K*score/400))
+}
+
+// Error applies params and returns the mean squared error over the entries
+func (tuner *Tuner) Error(params *EvalParams) (float64, error) {
+	if err := ApplyEvalParams(params); err != nil {
+		return 0, err
+	}
+	if len(tuner.Entries) == 0 {
+		return 0, nil
+	}
+	sums := make([]float64, tuner.Threads)
+	chunk := (len(tuner.Entries) + tuner.Threads - 1) / tuner.Threads
+	var wg sync.WaitGroup
+	for thread := 0; thread < tuner.Threads; thread++ {
+		start, end := thread*chunk, MathMinInt((thread+1)*chunk, len(tuner.Entries))
+		if start >= end {
+			break
+		}
+		wg.Add(1)
+		go func(thread int, entries []TuneEntry) {
+			defer wg.Done()
+			for _, entry := range entries {
+				target := entry.Result
+				if entry.Scored {
+					target = tuner.Sigmoid(float64(entry.Score))
+				}
+				diff := target - tuner.Sigmoid(float64(entry.Board.EvaluateClassical()))
+				sums[thread] += diff * diff
+			}
+		}(thread, tuner.Entries[start:end])
+	}
+	wg.Wait()
+	total := 0.0
+	for _, sum := range sums {
+		total += sum
+	}
+	return total / float64(len(tuner.Entries)), nil
+}
+
+// FitK sets K to the scale minimizing the error of params, by ternary search
+func (tuner *Tuner) FitK(params *EvalParams) (float64, error) {
+	low, high := 0.05, 4.0
+	for high-low > 0.001 {
+		left, right := low+(high-low)/3, high-(high-low)/3
+		tuner.K = left
+		leftError, err := tuner.Error(params)
+		if err != nil {
+			return 0, err
+		}
+		tuner.K = right
+		rightError, _ := tuner.Error(params)
+		if leftError < rightError {
+			high = right
+		} else {
+			low = left
+		}
+	}
+	tuner.K = (low + high) / 2
+	return tuner.K, nil
+}
+
+// Tune runs up to iterations passes of local search over the tunable weights of params,
+// keeping every change of one step that lowers the error, and stops early when a pass
+// improves nothing. onIteration, if set, is called after every pass. Returns the final error.
+func (tuner *Tuner) Tune(params *EvalParams, iterations int, onIteration func(iteration int, err float64)) (float64, error) {
+	best, err := tuner.Error(params)
+	if err != nil {
+		return 0, err
+	}
+	tunable := TunableParams(params)
+	for iteration := 1; iteration <= iterations; iteration++ {
+		improved := false
+		for _, param := range tunable {
+			for _, delta := range []int{tuner.Step, -tuner.Step} {
+				*param.Value += delta
+				candidate, err := tuner.Error(params)
+				if err == nil && candidate < best {
+					best = candidate
+					improved = true
+					break
+				}
+				*param.Value -= delta
+			}
+		}
+		if onIteration != nil {
+			onIteration(iteration, best)
+		}
+		if !improved {
+			break
+		}
+	}
+	// Leave the tuned weights in use
+	if err := ApplyEvalParams(params); err != nil {
+		return 0, err
+	}
+	return best, nil
+}
@@ -1,6 +1,9 @@
 package libra
 
-import "fmt"
+import (
+	"fmt"
+	"strings"
+)
 
 type GoOptions struct {
 	WTime     int  // white time remaining (ms)
@@ -10,11 +13,10 @@ type GoOptions struct {
 	MovesToGo int  // moves until next time control (0 = sudden death)
 	MoveTime  int  // exact time per move (ms)
 	Depth     int  // search to exactly this depth
+	Nodes     int  // search exactly this many nodes
 	Infinite  bool // search until "stop" command
 }
 
-const timeManagementSafetyMarginMs = 100
-
 func ParseGoOptions(fields []string) GoOptions {
 	opts := GoOptions{}
 	for i := 0; i < len(fields); i++ {
@@ -47,6 +49,10 @@ func ParseGoOptions(fields []string) GoOptions {
 			if i+1 < len(fields) {
 				fmt.Sscanf(fields[i+1], "%d", &opts.Depth)
 			}
+		case "nodes":
+			if i+1 < len(fields) {
+				fmt.Sscanf(fields[i+1], "%d", &opts.Nodes)
+			}
 		case "infinite":
 			opts.Infinite = true
 		}
@@ -57,14 +63,15 @@ func ParseGoOptions(fields []string) GoOptions {
 // CalcTimeLimit computes optimal (soft) and maximum (hard) time limits in ms.
 // optimalTime: target time per move, used to decide when to stop deepening.
 // maxTime: absolute ceiling for in-flight searches.
-func (opts *GoOptions) CalcTimeLimit(whiteToMove bool) (optimalTime int, maxTime int) {
+// moveOverheadMs: time reserved for communication delays with the GUI.
+func (opts *GoOptions) CalcTimeLimit(whiteToMove bool, moveOverheadMs int) (optimalTime int, maxTime int) {
 	// Fixed time per move
 	if opts.MoveTime > 0 {
 		return opts.MoveTime, opts.MoveTime
 	}
 
-	// Infinite or depth-only: no time constraint
-	if opts.Infinite || (opts.Depth > 0 && opts.WTime == 0 && opts.BTime == 0) {
+	// Infinite, depth-only or nodes-only: no time constraint
+	if opts.Infinite || ((opts.Depth > 0 || opts.Nodes > 0) && opts.WTime == 0 && opts.BTime == 0) {
 		return 0, 0
 	}
 
@@ -80,8 +87,8 @@ func (opts *GoOptions) CalcTimeLimit(whiteToMove bool) (optimalTime int, maxTime
 		return MaxEvaluationTimeMs, MaxEvaluationTimeMs
 	}
 
-	// Safety: never use more than remaining - margin
-	safeRemaining := remaining - timeManagementSafetyMarginMs
+	// Safety: never use more than remaining - move overhead
+	safeRemaining := remaining - moveOverheadMs
 	if safeRemaining < 50 {
 		safeRemaining = 50
 	}
@@ -111,3 +118,22 @@ func (opts *GoOptions) CalcTimeLimit(whiteToMove bool) (optimalTime int, maxTime
 
 	return optimalTime, maxTime
 }
+
+// ParseSetOption parses a UCI "setoption name <id> [value <x>]" command.
+// Option names and values may contain spaces.
+func ParseSetOption(fields []string) (name string, value string) {
+	nameParts := []string{}
+	valueParts := []string{}
+	target := &nameParts
+	for i := 1; i < len(fields); i++ {
+		switch {
+		case fields[i] == "name" && target == &nameParts && len(nameParts) == 0:
+			continue
+		case fields[i] == "value" && target == &nameParts:
+			target = &valueParts
+		default:
+			*target = append(*target, fields[i])
+		}
+	}
+	return strings.Join(nameParts, " "), strings.Join(valueParts, " ")
+}
@@ -16,6 +16,20 @@ func MathMinByte(a byte, b byte) byte {
 	return a
 }
 
+func MathMinInt(a int, b int) int {
+	if a > b {
+		return b
+	}
+	return a
+}
+
+func MathMaxInt(a int, b int) int {
+	if a < b {
+		return b
+	}
+	return a
+}
+
 // SquareIndexToName converts a square index (0-63) to its algebraic name (e.g., 0 -> "a8").
 func SquareIndexToName(idx byte) (string, bool) {
 	if idx >= 64 {
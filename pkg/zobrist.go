@@ -157,3 +157,15 @@ func (board *Board) ZobristHashWasm() uint64 {
 	}
 	return hash
 }
+
+// PawnHash returns the Zobrist key of the pawns only, used by the pawn hash table
+func (board *Board) PawnHash() uint64 {
+	var hash uint64 = 0
+	for b := board.WhitePawns; b != 0; b &= b - 1 {
+		hash ^= zobristPieceTable[bits.TrailingZeros64(b)][WhitePawn]
+	}
+	for b := board.BlackPawns; b != 0; b &= b - 1 {
+		hash ^= zobristPieceTable[bits.TrailingZeros64(b)][BlackPawn]
+	}
+	return hash
+}
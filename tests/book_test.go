@@ -0,0 +1,139 @@
+package libra_test
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	. "github.com/eugenioenko/libra-chess/pkg"
+)
+
+func TestInternalBook(t *testing.T) {
+	book, err := InternalBook()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if book.Len() < 10_000 {
+		t.Errorf("Expected the compiled-in book to have more than 10000 positions, got %d", book.Len())
+	}
+	board := NewBoard()
+	board.LoadInitial()
+	moves := book.Moves(board)
+	if len(moves) != 5 || moves[0].Move.ToUCI() != "e2e4" || moves[0].Weight != 243109 {
+		t.Fatalf("Expected 5 moves from the start, e2e4 first, got %v", moves)
+	}
+	// An en passant square no pawn can take on does not change the key
+	board.Move(*board.ParseUCIMove("d2d4"))
+	if moves := book.Moves(board); len(moves) == 0 || moves[0].Move.ToUCI() != "g8f6" {
+		t.Errorf("Expected g8f6 first after d2d4, got %v", moves)
+	}
+}
+
+func TestBookPickMove(t *testing.T) {
+	book, err := InternalBook()
+	if err != nil {
+		t.Fatal(err)
+	}
+	book.Rand = rand.New(rand.NewSource(1))
+	board := NewBoard()
+	board.LoadInitial()
+	book.Randomness = 0
+	for i := 0; i < 10; i++ {
+		if move := book.PickMove(board); move == nil || move.ToUCI() != "e2e4" {
+			t.Fatalf("Expected the most played move without randomness, got %v", move)
+		}
+	}
+
+	// e2e4 was played in about 54% of the games and d2d4 in 33%
+	book.Randomness = 100
+	picks := map[string]int{}
+	for i := 0; i < 2000; i++ {
+		picks[book.PickMove(board).ToUCI()]++
+	}
+	if picks["e2e4"] < 950 || picks["e2e4"] > 1200 || picks["d2d4"] < 550 || picks["d2d4"] > 780 {
+		t.Errorf("Expected moves picked in proportion to their counts, got %v", picks)
+	}
+	book.Randomness = MaxBookRandomness
+	flatter := map[string]int{}
+	for i := 0; i < 2000; i++ {
+		flatter[book.PickMove(board).ToUCI()]++
+	}
+	if flatter["f2f4"] <= picks["f2f4"] || flatter["e2e4"] >= picks["e2e4"] {
+		t.Errorf("Expected more rare moves with more randomness, got %v and %v", flatter, picks)
+	}
+
+	book.Depth = 1
+	board.FullMoveCounter = 2
+	if move := book.PickMove(board); move != nil {
+		t.Errorf("Expected no book move past the book depth, got %v", move)
+	}
+	board.FromFEN("8/8/8/4k3/8/8/8/R3K2R w KQ - 0 1")
+	if move := book.PickMove(board); move != nil {
+		t.Errorf("Expected no book move out of the book, got %v", move)
+	}
+}
+
+func TestLoadBookErrors(t *testing.T) {
+	for _, text := range []string{
+		"e2e4 10\n",
+		"pos rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq -\ne2e5 10\n",
+		"pos rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq -\ne2e4 many\n",
+	} {
+		if _, err := LoadBook(strings.NewReader(text)); !errors.Is(err, ErrInvalidBook) {
+			t.Errorf("Expected ErrInvalidBook for %q, got %v", text, err)
+		}
+	}
+}
+
+func TestEngineOwnBook(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "book.txt")
+	text := "# Only the Sicilian\npos rnbqkbnr/pppppppp/8/8/4P3/8/PPPP1PPP/RNBQKBNR b KQkq -\nc7c5 3\n"
+	if err := os.WriteFile(path, []byte(text), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	engine := NewEngine()
+	if err := engine.SetOption("BookFile", path); err != nil {
+		t.Fatal(err)
+	}
+	board := NewBoard()
+	board.LoadInitial()
+	board.Move(*board.ParseUCIMove("e2e4"))
+	bestMove := func(limits GoOptions) (*Move, int) {
+		infos, err := engine.Analyze(context.Background(), board, limits)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var final SearchInfo
+		for info := range infos {
+			final = info
+		}
+		return final.BestMove, final.Depth
+	}
+	if move, depth := bestMove(GoOptions{WTime: 60_000, BTime: 60_000}); move == nil || move.ToUCI() != "c7c5" || depth != 0 {
+		t.Errorf("Expected the book move c7c5 without searching, got %v at depth %d", move, depth)
+	}
+	if _, depth := bestMove(GoOptions{Depth: 2}); depth != 2 {
+		t.Errorf("Expected a fixed depth to search, got depth %d", depth)
+	}
+	if err := engine.SetOption("OwnBook", "false"); err != nil {
+		t.Fatal(err)
+	}
+	if _, depth := bestMove(GoOptions{MoveTime: 100}); depth == 0 {
+		t.Error("Expected a search without the book")
+	}
+
+	if err := engine.SetOption("BookFile", filepath.Join(dir, "missing.txt")); err == nil || engine.Options.BookFile != path {
+		t.Errorf("Expected a missing book to be rejected, got %v", err)
+	}
+	if err := engine.SetOption("BookRandomness", "201"); !errors.Is(err, ErrInvalidOption) {
+		t.Errorf("Expected a randomness of 201 to be rejected, got %v", err)
+	}
+	if err := engine.SetOption("BookFile", DefaultBookFile); err != nil || engine.Options.BookFile != DefaultBookFile {
+		t.Errorf("Expected the compiled-in book, got %v", err)
+	}
+}
@@ -0,0 +1,138 @@
+package libra_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+
+	. "github.com/eugenioenko/libra-chess/pkg"
+)
+
+const bookBuildPGN = `[WhiteElo "2400"]
+[BlackElo "2200"]
+[Result "1-0"]
+
+1. e4 e5 2. Nf3 Nc6 1-0
+
+[WhiteElo "2600"]
+[Result "1/2-1/2"]
+
+1. e4 c5 2. Nf3 d6 1/2-1/2
+
+[Result "0-1"]
+
+1. d4 d5 2. c4 e6 0-1
+
+[Result "1-0"]
+
+1. Nf3 c5 2. e4 d6 1-0
+
+[Result "1-0"]
+
+1. e4 e5 2. Bz9 1-0
+`
+
+func buildBook(t *testing.T, maxPly int) (*BookBuilder, int) {
+	t.Helper()
+	builder := NewBookBuilder(maxPly)
+	reader := NewPGNReader(strings.NewReader(bookBuildPGN))
+	skipped := 0
+	for {
+		game, err := reader.Next()
+		if err != nil {
+			break
+		}
+		if err := builder.AddGame(game); err != nil {
+			skipped++
+		}
+	}
+	return builder, skipped
+}
+
+func TestBookBuilder(t *testing.T) {
+	builder, skipped := buildBook(t, 3)
+	if builder.Games != 4 || skipped != 1 {
+		t.Fatalf("Expected 4 games and the one with an illegal move skipped, got %d and %d", builder.Games, skipped)
+	}
+	positions := builder.Positions(BookFilter{})
+	start := positions[0]
+	if !strings.HasPrefix(start.FEN, "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq") || len(start.Moves) != 3 {
+		t.Fatalf("Expected the start position with 3 moves first, got %+v", start)
+	}
+	e4 := start.Moves[0]
+	if e4.Move.ToUCI() != "e2e4" || e4.Games != 2 || e4.Wins != 1 || e4.Draws != 1 || e4.Losses != 0 || e4.AverageRating() != 2500 || e4.Score() != 0.75 {
+		t.Errorf("Expected e2e4 played twice for 1.5 points at 2500, got %+v", e4)
+	}
+	// Past -max-ply 3, 2... Nc6 is not recorded
+	for _, position := range positions {
+		for _, stats := range position.Moves {
+			if uci := stats.Move.ToUCI(); uci == "b8c6" || uci == "d7d6" {
+				t.Errorf("Expected no move past the third ply, got %s", uci)
+			}
+		}
+	}
+
+	// 1. e4 c5 2. Nf3 and 1. Nf3 c5 2. e4 transpose
+	builder, _ = buildBook(t, 0)
+	filtered := builder.Positions(BookFilter{MinGames: 2})
+	if len(filtered) != 2 || len(filtered[0].Moves) != 1 || filtered[0].Moves[0].Move.ToUCI() != "e2e4" {
+		t.Fatalf("Expected e2e4 from the start and d7d6 after the transposition only, got %+v", filtered)
+	}
+	if d6 := filtered[1].Moves[0]; d6.Move.ToUCI() != "d7d6" || d6.Games != 2 || d6.Draws != 1 || d6.Losses != 1 || d6.Score() != 0.25 {
+		t.Errorf("Expected d7d6 played twice for half a point, got %+v", d6)
+	}
+	// e2e4 scored 75% and g1f3 100%, d2d4 lost
+	if scored := builder.Positions(BookFilter{MinScore: 0.6}); len(scored[0].Moves) != 2 || scored[0].Moves[0].Move.ToUCI() != "e2e4" {
+		t.Errorf("Expected the moves scoring 60%% for white, got %+v", scored[0].Moves)
+	}
+	// Only the first game is rated on both sides, the second for white
+	if rated := builder.Positions(BookFilter{MinRating: 2000}); len(rated) != 5 || len(rated[0].Moves) != 1 {
+		t.Errorf("Expected the moves of the rated players only, got %+v", rated)
+	}
+}
+
+func TestBookBuilderOutputs(t *testing.T) {
+	builder, _ := buildBook(t, 4)
+	positions := builder.Positions(BookFilter{})
+
+	var text bytes.Buffer
+	if err := SaveBookText(&text, positions); err != nil {
+		t.Fatal(err)
+	}
+	book, err := LoadBook(&text)
+	if err != nil {
+		t.Fatal(err)
+	}
+	board := NewBoard()
+	board.LoadInitial()
+	if moves := book.Moves(board); len(moves) != 3 || moves[0].Move.ToUCI() != "e2e4" || moves[0].Weight != 2 {
+		t.Errorf("Expected the text book to load with e2e4 played twice, got %v", moves)
+	}
+
+	var polyglot bytes.Buffer
+	fromPositions, err := NewBookFromPositions(positions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := fromPositions.SavePolyglot(&polyglot); err != nil {
+		t.Fatal(err)
+	}
+	if loaded, err := LoadPolyglot(&polyglot); err != nil || loaded.Len() != book.Len() {
+		t.Errorf("Expected the Polyglot book to have the %d positions, got %v", book.Len(), err)
+	}
+
+	var out bytes.Buffer
+	if err := SaveBookJSON(&out, positions); err != nil {
+		t.Fatal(err)
+	}
+	var decoded map[string][]string
+	if err := json.Unmarshal(out.Bytes(), &decoded); err != nil {
+		t.Fatal(err)
+	}
+	start := decoded[fmt.Sprintf("%#x", board.ZobristHashWasm())]
+	if len(start) != 3 || start[0] != "e2e4" {
+		t.Errorf("Expected the start position keyed like books/book.json with e2e4 first, got %v", start)
+	}
+}
@@ -0,0 +1,75 @@
+package libra_test
+
+import (
+	"testing"
+
+	. "github.com/eugenioenko/libra-chess/pkg"
+)
+
+func TestDrawScorePerspective(t *testing.T) {
+	if score := DrawScore(30, true); score != -30 {
+		t.Errorf("Expected draw to score -30 for white at the root, got %d", score)
+	}
+	if score := DrawScore(30, false); score != 30 {
+		t.Errorf("Expected draw to score 30 for black at the root, got %d", score)
+	}
+}
+
+func TestDynamicContempt(t *testing.T) {
+	if c := DynamicContempt(10, 1800, 1500); c != 40 {
+		t.Errorf("Expected 40, got %d", c)
+	}
+	if c := DynamicContempt(10, 1800, 3000); c != 10-DynamicContemptMax {
+		t.Errorf("Expected %d, got %d", 10-DynamicContemptMax, c)
+	}
+}
+
+func TestParseUCIOpponent(t *testing.T) {
+	if rating, ok := ParseUCIOpponent("GM 2800 human Gary Kasparov"); !ok || rating != 2800 {
+		t.Errorf("Expected 2800, got %d %v", rating, ok)
+	}
+	if _, ok := ParseUCIOpponent("none none computer Shredder"); ok {
+		t.Errorf("Expected no rating")
+	}
+}
+
+func TestMateOrDrawScore(t *testing.T) {
+	board := NewBoard()
+	// Black to move is stalemated
+	board.FromFEN("k7/2Q5/1K6/8/8/8/8/8 b - - 0 1")
+	if score := board.MateOrDrawScore(false, 25); score != 25 {
+		t.Errorf("Expected stalemate to score the draw score 25, got %d", score)
+	}
+	if score := board.MateOrStalemateScore(false); score != 0 {
+		t.Errorf("Expected stalemate to score 0, got %d", score)
+	}
+}
+
+func TestContemptAvoidsStalemate(t *testing.T) {
+	board := NewBoard()
+	// White is up a queen, Qc7 stalemates while any other sensible move keeps winning
+	board.FromFEN("k7/8/1K6/8/8/8/8/2Q5 w - - 0 1")
+	move := board.IterativeDeepeningSearch(SearchOptions{MaxDepth: 3, Contempt: 50})
+	if move == nil || move.ToUCI() == "c1c7" {
+		t.Errorf("Expected a move other than the stalemating c1c7, got %v", move)
+	}
+}
+
+func TestDrawScoreClearsTranspositionTable(t *testing.T) {
+	tt := NewTranspositionTableMB(1)
+	tt.Set(42, 5, 100, Move{}, BoundExact, NoStaticEval, false)
+	tt.SetDrawScore(0)
+	if _, ok := tt.Probe(42); !ok {
+		t.Errorf("Expected the table to be kept for the same draw score")
+	}
+	// The root side changed with a contempt of 20
+	tt.SetDrawScore(DrawScore(20, false))
+	if _, ok := tt.Probe(42); ok {
+		t.Errorf("Expected the table to be cleared for another draw score")
+	}
+	tt.Set(42, 5, 100, Move{}, BoundExact, NoStaticEval, false)
+	tt.SetDrawScore(DrawScore(20, false))
+	if _, ok := tt.Probe(42); !ok {
+		t.Errorf("Expected the table to be kept for the same draw score")
+	}
+}
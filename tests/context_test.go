@@ -0,0 +1,84 @@
+package libra_test
+
+import (
+	"testing"
+
+	. "github.com/eugenioenko/libra-chess/pkg"
+)
+
+func TestHistoryGravityStaysBounded(t *testing.T) {
+	board := NewBoard()
+	board.LoadInitial()
+	move := *board.ParseUCIMove("g1f3")
+	ctx := &SearchContext{}
+	for i := 0; i < 1000; i++ {
+		ctx.UpdateQuietHistory(move, nil, true, 20, 1)
+	}
+	score := ctx.ButterflyHistory[0][move.From][move.To]
+	if score <= 0 || score > HistoryMax {
+		t.Errorf("Expected history within (0, %d], got %d", HistoryMax, score)
+	}
+}
+
+func TestHistoryPenalizesSearchedQuiets(t *testing.T) {
+	board := NewBoard()
+	board.LoadInitial()
+	best := *board.ParseUCIMove("g1f3")
+	other := *board.ParseUCIMove("a2a3")
+	ctx := &SearchContext{}
+	ctx.UpdateQuietHistory(best, []Move{other, best}, true, 4, 1)
+	if ctx.QuietHistory(best, true, 1) <= 0 {
+		t.Errorf("Expected positive history for the cutoff move")
+	}
+	if ctx.QuietHistory(other, true, 1) >= 0 {
+		t.Errorf("Expected negative history for the searched quiet move")
+	}
+	if ctx.QuietHistory(best, false, 1) != 0 {
+		t.Errorf("Expected history to be indexed by side to move")
+	}
+}
+
+func TestCounterMove(t *testing.T) {
+	board := NewBoard()
+	board.LoadInitial()
+	prev := *board.ParseUCIMove("e2e4")
+	board.Move(prev)
+	reply := *board.ParseUCIMove("c7c5")
+	ctx := &SearchContext{}
+	ctx.MoveStack[0] = prev
+	ctx.UpdateQuietHistory(reply, nil, false, 3, 1)
+	counter, ok := ctx.CounterMove(1)
+	if !ok || counter != reply {
+		t.Errorf("Expected counter move c7c5, got %s", counter.ToUCI())
+	}
+	if ctx.QuietHistory(reply, false, 1) <= ctx.ButterflyHistory[1][reply.From][reply.To] {
+		t.Errorf("Expected continuation history to contribute to the quiet history")
+	}
+}
+
+func TestSearchThreadsAge(t *testing.T) {
+	board := NewBoard()
+	board.LoadInitial()
+	move := *board.ParseUCIMove("d2d4")
+	threads := NewSearchThreads(2)
+	ctx := threads.Contexts[0]
+	ctx.UpdateQuietHistory(move, nil, true, 10, 1)
+	before := ctx.ButterflyHistory[0][move.From][move.To]
+	threads.Age()
+	after := ctx.ButterflyHistory[0][move.From][move.To]
+	if after != before/2 {
+		t.Errorf("Expected aged history %d, got %d", before/2, after)
+	}
+	if ctx.IsKillerMove(move, 1) {
+		t.Errorf("Expected killer moves to be forgotten after aging")
+	}
+	ctx.DrawScore = 25
+	pawnTable := ctx.PawnTable
+	threads.Clear()
+	if ctx.ButterflyHistory[0][move.From][move.To] != 0 {
+		t.Errorf("Expected history to be cleared")
+	}
+	if ctx.DrawScore != 25 || ctx.PawnTable != pawnTable {
+		t.Errorf("Expected the search settings of the context to be kept by Clear")
+	}
+}
@@ -0,0 +1,91 @@
+package libra_test
+
+import (
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	. "github.com/eugenioenko/libra-chess/pkg"
+)
+
+func TestTrainingPositionRoundTrip(t *testing.T) {
+	position := TrainingPosition{FEN: "4k3/8/8/8/8/8/8/3QK3 b - - 3 40", Score: 910, Result: 1}
+	line := position.String()
+	if line != "4k3/8/8/8/8/8/8/3QK3 b - - 3 40 | 910 | 1.0" {
+		t.Errorf("Unexpected line %q", line)
+	}
+	entry, err := ParseTuneEntry(line)
+	if err != nil || entry.Result != 1 || entry.Score != 910 || entry.Scored {
+		t.Errorf("Expected the result label to be parsed, got %+v (%v)", entry, err)
+	}
+}
+
+func TestDatagenFilter(t *testing.T) {
+	filter := DefaultDatagenFilter()
+	board := NewBoard()
+	board.FromFEN("4k3/8/8/3p4/4P3/8/8/4K3 w - - 0 1")
+	quiet := *board.ParseUCIMove("e1e2")
+	capture := *board.ParseUCIMove("e4d5")
+	if !filter.Accept(board, quiet, 50, DatagenMinPly) {
+		t.Error("Expected a quiet position to be kept")
+	}
+	if filter.Accept(board, capture, 50, DatagenMinPly) {
+		t.Error("Expected a capture as best move to be rejected")
+	}
+	if filter.Accept(board, quiet, 50, DatagenMinPly-1) || filter.Accept(board, quiet, -DatagenMaxScore-1, DatagenMinPly) {
+		t.Error("Expected early plies and large scores to be rejected")
+	}
+	board.FromFEN("4k3/8/8/1B6/8/8/8/4K3 b - - 0 1")
+	if filter.Accept(board, *board.ParseUCIMove("e8f7"), 300, DatagenMinPly) {
+		t.Error("Expected a position in check to be rejected")
+	}
+}
+
+func TestGenerateTrainingGameLabelsResult(t *testing.T) {
+	board := NewBoard()
+	board.FromFEN("6k1/5ppp/8/8/8/8/5PPP/R5K1 w - - 0 1")
+	white, black := NewSelfPlayer(DefaultSearchParams()), NewSelfPlayer(DefaultSearchParams())
+	positions := GenerateTrainingGame(white, black, board, SelfPlayOptions{Nodes: 2_000, MaxPlies: 40}, DatagenFilter{MinPly: 0, MaxScore: MaxEvaluationScore})
+	if len(positions) == 0 {
+		t.Fatal("Expected positions to be recorded")
+	}
+	for _, position := range positions {
+		if position.Result != 1 {
+			t.Errorf("Expected white to win the game, got %v", position.Result)
+		}
+	}
+}
+
+func TestRandomOpeningAndLoadOpenings(t *testing.T) {
+	boards, err := LoadOpenings(strings.NewReader("# openings\n" + BoardInitialFEN + ";\n\nrnbqkbnr/pppppppp/8/8/4P3/8/PPPP1PPP/RNBQKBNR b KQkq - 0 1;\n"))
+	if err != nil || len(boards) != 2 {
+		t.Fatalf("Expected 2 openings, got %d (%v)", len(boards), err)
+	}
+	opening := RandomOpening(boards[0], 6, rand.New(rand.NewSource(1)))
+	if opening == nil || opening.FullMoveCounter != 4 || !opening.WhiteToMove {
+		t.Errorf("Expected 6 random plies to be played, got %v", opening)
+	}
+	if boards[0].ToFEN() != BoardInitialFEN {
+		t.Error("Expected the opening to be left unchanged")
+	}
+}
+
+func TestLoadOpeningsFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "openings.epd")
+	if err := os.WriteFile(path, []byte(BoardInitialFEN+"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if boards, err := LoadOpeningsFile(path); err != nil || len(boards) != 1 {
+		t.Errorf("Expected 1 opening, got %d (%v)", len(boards), err)
+	}
+	empty := filepath.Join(dir, "empty.epd")
+	if err := os.WriteFile(empty, []byte("# nothing\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadOpeningsFile(empty); err == nil {
+		t.Error("Expected an error for a file without openings")
+	}
+}
@@ -0,0 +1,157 @@
+package libra_test
+
+import (
+	"testing"
+
+	. "github.com/eugenioenko/libra-chess/pkg"
+)
+
+func TestKPKBitbase(t *testing.T) {
+	cases := []struct {
+		fen string
+		win bool
+	}{
+		{"4k3/8/4K3/4P3/8/8/8/8 w - - 0 1", true},  // King on the 6th in front of its pawn
+		{"4k3/8/4K3/4P3/8/8/8/8 b - - 0 1", true},  // ... wins with either side to move
+		{"8/8/8/8/8/4k3/4P3/4K3 w - - 0 1", false}, // Opposition in front of the pawn
+		{"k7/8/8/8/8/8/P7/7K w - - 0 1", false},    // Rook pawn with the king in the corner
+		{"8/8/8/8/P4k2/8/8/K7 w - - 0 1", true},    // The king is outside the square
+		{"8/8/8/8/P4k2/8/8/K7 b - - 0 1", false},   // ... unless it moves first
+		{"8/8/8/8/8/8/3kP3/7K b - - 0 1", false},   // The pawn is lost
+		{"4k3/4p3/4K3/8/8/8/8/8 b - - 0 1", false}, // Black pawn, opposition
+		{"8/8/8/8/8/3k4/4p3/7K b - - 0 1", true},   // Black pawn, promotes
+	}
+	for _, c := range cases {
+		board := NewBoard()
+		board.FromFEN(c.fen)
+		score := board.Evaluate()
+		switch {
+		case c.win && abs(score) < EndgameKnownWin:
+			t.Errorf("Expected %s to be a known win, got %d", c.fen, score)
+		case !c.win && score != 0:
+			t.Errorf("Expected %s to be a draw, got %d", c.fen, score)
+		}
+	}
+	if score := evaluateFEN("8/8/8/8/8/3k4/4p3/7K b - - 0 1"); score > -EndgameKnownWin {
+		t.Errorf("Expected black to win, got %d", score)
+	}
+}
+
+func TestKBNKDrivesToBishopCorner(t *testing.T) {
+	// Dark-squared bishop: the mating corners are a1 and h8
+	right, wrong := evaluateFEN("7k/8/5K2/8/8/8/8/3NB3 w - - 0 1"), evaluateFEN("k7/8/2K5/8/8/8/8/3NB3 w - - 0 1")
+	if right < EndgameKnownWin || right <= wrong {
+		t.Errorf("Expected the king in the bishop's corner to score more, got %d and %d", right, wrong)
+	}
+	if mirrored := evaluateFEN(mirrorFEN("7k/8/5K2/8/8/8/8/3NB3 w - - 0 1")); mirrored != -right {
+		t.Errorf("Expected the mirrored position to score %d, got %d", -right, mirrored)
+	}
+}
+
+func TestLoneKingEndgames(t *testing.T) {
+	edge, center := evaluateFEN("7k/8/8/8/8/8/8/R3K3 w - - 0 1"), evaluateFEN("8/8/8/4k3/8/8/8/R3K3 w - - 0 1")
+	if center < EndgameKnownWin || edge <= center {
+		t.Errorf("Expected KRK to be a known win, better with the king on the edge, got %d and %d", edge, center)
+	}
+	if score := evaluateFEN("8/8/8/4k3/8/8/8/3QK3 b - - 0 1"); score < EndgameKnownWin {
+		t.Errorf("Expected KQK to be a known win, got %d", score)
+	}
+	if score := evaluateFEN("7k/5Q2/6K1/8/8/8/8/8 b - - 0 1"); score != 0 {
+		t.Errorf("Expected stalemate to be a draw, got %d", score)
+	}
+	for _, fen := range []string{
+		"8/8/8/4k3/8/8/8/2N1K3 w - - 0 1",   // KNK
+		"8/8/8/4k3/8/8/8/2B1K3 w - - 0 1",   // KBK
+		"8/8/8/4k3/8/8/8/1NN1K3 w - - 0 1",  // KNNK
+		"8/8/8/4k3/8/8/3B4/2B1K3 w - - 0 1", // Bishops on the same color
+	} {
+		if score := evaluateFEN(fen); score != 0 {
+			t.Errorf("Expected %s to be a draw, got %d", fen, score)
+		}
+	}
+	if score := evaluateFEN("8/8/8/4k3/8/8/8/2BBK3 w - - 0 1"); score < EndgameKnownWin {
+		t.Errorf("Expected bishops of both colors to win, got %d", score)
+	}
+}
+
+func TestEndgameScaleFactors(t *testing.T) {
+	cases := []struct {
+		fen  string
+		rule string
+	}{
+		{"1k6/8/8/P7/8/8/8/2B1K3 w - - 0 1", "wrong bishop"},
+		{"4k3/8/3b4/5p2/5P2/2P2B2/8/4K3 w - - 0 1", "opposite bishops"},
+		{"4k3/8/8/8/8/8/2b5/R3K3 w - - 0 1", "pawnless minor"},
+	}
+	for _, c := range cases {
+		board := NewBoard()
+		board.FromFEN(c.fen)
+		trace := board.EvaluateTrace()
+		if trace.Endgame != c.rule || abs(trace.Score) >= abs(trace.Classical) {
+			t.Errorf("Expected %s to be scaled down by %q, got %q: %d from %d", c.fen, c.rule, trace.Endgame, trace.Score, trace.Classical)
+		}
+	}
+	if score := evaluateFEN("1k6/8/8/P7/8/8/8/2B1K3 w - - 0 1"); score != 0 {
+		t.Errorf("Expected the wrong bishop to draw, got %d", score)
+	}
+	if score := evaluateFEN("1k6/8/8/P7/8/8/8/3BK3 w - - 0 1"); score <= 0 {
+		t.Errorf("Expected the right bishop to keep its advantage, got %d", score)
+	}
+}
+
+func TestEndgameScaleFactorsFromEvalParams(t *testing.T) {
+	defer ApplyEvalParams(DefaultEvalParams())
+	fen := "4k3/8/3b4/5p2/5P2/2P2B2/8/4K3 w - - 0 1"
+	params := DefaultEvalParams()
+	params.PureOppositeBishopsScale = ScaleNormal
+	if err := ApplyEvalParams(params); err != nil {
+		t.Fatalf("Apply failed: %v", err)
+	}
+	board := NewBoard()
+	board.FromFEN(fen)
+	if trace := board.EvaluateTrace(); trace.Score != trace.Classical {
+		t.Errorf("Expected a scale of ScaleNormal to keep the evaluation, got %d from %d", trace.Score, trace.Classical)
+	}
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
+
+func TestEvaluateKeepsMaterialThroughMoves(t *testing.T) {
+	lines := []struct {
+		fen   string
+		moves []string
+	}{
+		// Captures, en passant and promotions on both sides
+		{"r3k3/1P4p1/8/3pP3/8/8/6p1/4K2R w K d6 0 1", []string{"e5d6", "g2h1q", "e1e2", "h1h6", "b7a8n", "h6d6"}},
+		// A capture into a drawn KNK, then a promotion into a won KQK
+		{"4k3/8/8/3r4/8/2N5/8/4K3 w - - 0 1", []string{"c3d5", "e8d7", "d5c3"}},
+		{"8/P7/8/8/8/8/k7/4K3 w - - 0 1", []string{"a7a8q", "a2b2"}},
+	}
+	for _, line := range lines {
+		board := NewBoard()
+		board.FromFEN(line.fen)
+		board.Evaluate()
+		var played []MoveState
+		for _, uci := range line.moves {
+			played = append(played, board.Move(*board.ParseUCIMove(uci)))
+			fresh := NewBoard()
+			fresh.FromFEN(board.ToFEN())
+			if board.Evaluate() != fresh.Evaluate() {
+				t.Fatalf("After %s, expected the evaluation of %s, got %d instead of %d", uci, board.ToFEN(), board.Evaluate(), fresh.Evaluate())
+			}
+		}
+		for i := len(played) - 1; i >= 0; i-- {
+			board.UndoMove(played[i])
+		}
+		fresh := NewBoard()
+		fresh.FromFEN(line.fen)
+		if board.Evaluate() != fresh.Evaluate() {
+			t.Errorf("Expected the evaluation of %s to be restored by UndoMove, got %d instead of %d", line.fen, board.Evaluate(), fresh.Evaluate())
+		}
+	}
+}
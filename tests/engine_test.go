@@ -0,0 +1,157 @@
+package libra_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	. "github.com/eugenioenko/libra-chess/pkg"
+)
+
+func TestEngineAnalyzeStreamsIterations(t *testing.T) {
+	engine := NewEngine()
+	board := NewBoard()
+	board.LoadInitial()
+	infos, err := engine.Analyze(context.Background(), board, GoOptions{Depth: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	depths := []int{}
+	var final SearchInfo
+	for info := range infos {
+		if info.Final {
+			final = info
+			continue
+		}
+		depths = append(depths, info.Depth)
+		if len(info.PV) == 0 || len(info.PV) > info.Depth {
+			t.Errorf("Expected a PV of 1 to %d moves, got %d", info.Depth, len(info.PV))
+		}
+	}
+	if len(depths) != 3 || depths[0] != 1 || depths[2] != 3 {
+		t.Errorf("Expected iterations 1 to 3, got %v", depths)
+	}
+	if !final.Final || final.BestMove == nil || final.Nodes == 0 {
+		t.Errorf("Expected a final report with a best move, got %+v", final)
+	}
+}
+
+func TestEngineAnalyzeCancel(t *testing.T) {
+	engine := NewEngine()
+	board := NewBoard()
+	board.LoadInitial()
+	ctx, cancel := context.WithCancel(context.Background())
+	infos, err := engine.Analyze(ctx, board, GoOptions{Infinite: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := engine.Analyze(context.Background(), board, GoOptions{Depth: 1}); !errors.Is(err, ErrSearchInProgress) {
+		t.Errorf("Expected ErrSearchInProgress, got %v", err)
+	}
+	if err := engine.NewGame(); !errors.Is(err, ErrSearchInProgress) {
+		t.Errorf("Expected ErrSearchInProgress from NewGame, got %v", err)
+	}
+	if err := engine.SetOption("Hash", "1"); !errors.Is(err, ErrSearchInProgress) || engine.Options.HashMB != DefaultHashMB {
+		t.Errorf("Expected the Hash option to keep %d MB during the search, got %d (%v)", DefaultHashMB, engine.Options.HashMB, err)
+	}
+	time.Sleep(100 * time.Millisecond)
+	cancel()
+	var final SearchInfo
+	for info := range infos {
+		final = info
+	}
+	if !final.Final || final.BestMove == nil {
+		t.Errorf("Expected a final report with a best move after cancel, got %+v", final)
+	}
+	if err := engine.NewGame(); err != nil {
+		t.Errorf("Expected NewGame to succeed after the final report, got %v", err)
+	}
+}
+
+func TestEngineAnalyzeMate(t *testing.T) {
+	engine := NewEngine()
+	board := NewBoard()
+	// Scholar's mate in one: Qxf7#
+	board.FromFEN("r1bqkb1r/pppp1ppp/2n2n2/4p2Q/2B1P3/8/PPPP1PPP/RNB1K1NR w KQkq - 4 4")
+	infos, err := engine.Analyze(context.Background(), board, GoOptions{Depth: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var final SearchInfo
+	for info := range infos {
+		final = info
+	}
+	if final.BestMove == nil || final.BestMove.ToUCI() != "h5f7" || final.Mate != 1 {
+		t.Errorf("Expected h5f7 mate 1, got %v mate %d", final.BestMove, final.Mate)
+	}
+
+	// Searched deeper than the mate, with principal variations cut short by the table
+	for _, test := range []struct {
+		fen  string
+		mate int
+	}{
+		{"8/8/1R6/8/8/k2K4/8/8 w - - 0 1", 4},
+		{"7R/1k1K4/8/8/8/8/8/8 w - - 0 1", 4},
+		{"k7/8/1K6/8/8/8/8/7R b - - 0 1", -1},
+	} {
+		board.FromFEN(test.fen)
+		engine := NewEngine()
+		engine.SetOption("Hash", "1")
+		infos, err := engine.Analyze(context.Background(), board, GoOptions{Depth: 9})
+		if err != nil {
+			t.Fatal(err)
+		}
+		for info := range infos {
+			final = info
+		}
+		if final.Mate != test.mate {
+			t.Errorf("Expected mate %d in %s, got mate %d with %v", test.mate, test.fen, final.Mate, final.PV)
+		}
+	}
+}
+
+func TestEngineSetOption(t *testing.T) {
+	engine := NewEngine()
+	if err := engine.SetOption("contempt", "25"); err != nil || engine.Options.Contempt != 25 {
+		t.Errorf("Expected contempt 25, got %d (%v)", engine.Options.Contempt, err)
+	}
+	if err := engine.SetOption("Contempt", "500"); !errors.Is(err, ErrInvalidOption) {
+		t.Errorf("Expected ErrInvalidOption, got %v", err)
+	}
+	if err := engine.SetOption("Ponder", "true"); !errors.Is(err, ErrUnknownOption) {
+		t.Errorf("Expected ErrUnknownOption, got %v", err)
+	}
+}
+
+func TestEngineSharedStateWhileAnotherSearches(t *testing.T) {
+	searching, other := NewEngine(), NewEngine()
+	board := NewBoard()
+	board.LoadInitial()
+	ctx, cancel := context.WithCancel(context.Background())
+	infos, err := searching.Analyze(ctx, board, GoOptions{Infinite: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := other.SetOption("EvalFile", DefaultEvalFile); !errors.Is(err, ErrSearchInProgress) {
+		t.Errorf("Expected ErrSearchInProgress from another engine's EvalFile, got %v", err)
+	}
+	if err := other.SetOption("SyzygyPath", DefaultSyzygyPath); !errors.Is(err, ErrSearchInProgress) {
+		t.Errorf("Expected ErrSearchInProgress from another engine's SyzygyPath, got %v", err)
+	}
+	if err := other.SetOption("SyzygyProbeDepth", "4"); !errors.Is(err, ErrSearchInProgress) || other.Options.SyzygyProbeDepth != DefaultSyzygyProbeDepth {
+		t.Errorf("Expected SyzygyProbeDepth to stay %d, got %d (%v)", DefaultSyzygyProbeDepth, other.Options.SyzygyProbeDepth, err)
+	}
+	if err := other.SetOption("Syzygy50MoveRule", "false"); !errors.Is(err, ErrSearchInProgress) || !other.Options.Syzygy50MoveRule {
+		t.Errorf("Expected Syzygy50MoveRule to stay on, got %v", err)
+	}
+	if err := other.SetOption("Hash", "1"); err != nil {
+		t.Errorf("Expected the table of another engine to stay its own, got %v", err)
+	}
+	cancel()
+	for range infos {
+	}
+	if err := other.SetOption("EvalFile", DefaultEvalFile); err != nil {
+		t.Errorf("Expected EvalFile to load once no engine is searching, got %v", err)
+	}
+}
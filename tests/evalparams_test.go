@@ -0,0 +1,107 @@
+package libra_test
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	. "github.com/eugenioenko/libra-chess/pkg"
+)
+
+func TestDefaultEvalParamsArePeSTO(t *testing.T) {
+	params := DefaultEvalParams()
+	if params.PieceValues[4] != (TaperedScore{Mg: 1025, Eg: 936}) {
+		t.Errorf("Expected the PeSTO queen value, got %+v", params.PieceValues[4])
+	}
+	// a7 pawn, stored without the material
+	if params.PSTMg[0][8] != 98 || params.PSTEg[0][8] != 178 {
+		t.Errorf("Expected the PeSTO a7 pawn square, got %d %d", params.PSTMg[0][8], params.PSTEg[0][8])
+	}
+	if params.PhaseWeights != [6]int{PawnPhase, KnightPhase, BishopPhase, RookPhase, QueenPhase, 0} {
+		t.Errorf("Unexpected phase weights %v", params.PhaseWeights)
+	}
+	if *CurrentEvalParams() != *params {
+		t.Errorf("Expected the default parameters to be in use")
+	}
+}
+
+func TestEvalParamsSaveLoadRoundTrip(t *testing.T) {
+	params := DefaultEvalParams()
+	params.BishopPair = TaperedScore{Mg: 11, Eg: 22}
+	params.PSTEg[5][60] = -7
+	var buf bytes.Buffer
+	if err := params.Save(&buf); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	loaded, err := LoadEvalParams(&buf)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if *loaded != *params {
+		t.Errorf("Expected the loaded parameters to match the saved ones")
+	}
+}
+
+func TestLoadEvalParamsPartialAndInvalid(t *testing.T) {
+	loaded, err := LoadEvalParams(strings.NewReader(`{"bishopPair": {"mg": 1, "eg": 2}}`))
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	expected := DefaultEvalParams()
+	expected.BishopPair = TaperedScore{Mg: 1, Eg: 2}
+	if *loaded != *expected {
+		t.Errorf("Expected missing parameters to keep their default")
+	}
+	invalid := []string{
+		`{"bishopPairs": {"mg": 1}}`,
+		`{"kingDangerDivisor": 0}`,
+		`{"phaseWeights": [0, 0, 0, 0, 0, 0]}`,
+		`not json`,
+	}
+	for _, data := range invalid {
+		if _, err := LoadEvalParams(strings.NewReader(data)); !errors.Is(err, ErrInvalidEvalParams) {
+			t.Errorf("Expected %s to be rejected, got %v", data, err)
+		}
+	}
+}
+
+func TestApplyEvalParamsChangesEvaluation(t *testing.T) {
+	defer ApplyEvalParams(DefaultEvalParams())
+	fen := "4k3/8/8/8/8/8/8/3QK3 w - - 0 1"
+	before := evaluateFEN(fen)
+	params := DefaultEvalParams()
+	params.PieceValues[4] = params.PieceValues[4].Add(TaperedScore{Mg: 100, Eg: 100})
+	if err := ApplyEvalParams(params); err != nil {
+		t.Fatalf("Apply failed: %v", err)
+	}
+	if after := evaluateFEN(fen); after != before+100 {
+		t.Errorf("Expected a queen worth 100 more to add 100, got %d and %d", before, after)
+	}
+}
+
+func TestEngineEvalFileOption(t *testing.T) {
+	engine := NewEngine()
+	defer engine.SetOption("EvalFile", DefaultEvalFile)
+	fen := "4k3/8/8/8/8/8/8/3QK3 w - - 0 1"
+	before := evaluateFEN(fen)
+
+	path := filepath.Join(t.TempDir(), "eval.json")
+	if err := os.WriteFile(path, []byte(`{"pieceValues": [{"mg":82,"eg":94},{"mg":337,"eg":281},{"mg":365,"eg":297},{"mg":477,"eg":512},{"mg":1125,"eg":1036},{"mg":0,"eg":0}]}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := engine.SetOption("EvalFile", path); err != nil {
+		t.Fatalf("SetOption failed: %v", err)
+	}
+	if engine.Options.EvalFile != path || evaluateFEN(fen) != before+100 {
+		t.Errorf("Expected the parameters of %s to be in use", path)
+	}
+	if err := engine.SetOption("EvalFile", filepath.Join(t.TempDir(), "missing.json")); err == nil {
+		t.Errorf("Expected a missing file to fail")
+	}
+	if err := engine.SetOption("EvalFile", DefaultEvalFile); err != nil || evaluateFEN(fen) != before {
+		t.Errorf("Expected the default parameters to be restored, got %v", err)
+	}
+}
@@ -0,0 +1,57 @@
+package libra_test
+
+import (
+	"strings"
+	"testing"
+
+	. "github.com/eugenioenko/libra-chess/pkg"
+)
+
+func TestEvaluateTraceMatchesEvaluate(t *testing.T) {
+	fens := []string{
+		"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1",
+		"r1bqk2r/pp3ppp/2n1pn2/2pp4/1bPP4/2N1PN2/PP1B1PPP/R2QKB1R w KQkq - 0 1",
+		"8/5k2/8/1p6/1P1K4/8/8/8 b - - 0 1",
+		"6k1/8/8/8/8/8/8/4K2Q w - - 0 1",
+		"8/8/8/4k3/8/8/2B5/4K3 w - - 0 1",
+	}
+	for _, fen := range fens {
+		board := NewBoard()
+		board.FromFEN(fen)
+		trace := board.EvaluateTrace()
+		if trace.Score != board.Evaluate() {
+			t.Errorf("Expected the trace score of %s to be %d, got %d", fen, board.Evaluate(), trace.Score)
+		}
+		sum := 0
+		for _, term := range trace.Terms {
+			sum += term.Total().Taper(trace.Phase)
+		}
+		// Every term is tapered on its own, allow one centipawn of rounding per term
+		if diff := sum - trace.Classical; diff > len(trace.Terms) || -diff > len(trace.Terms) {
+			t.Errorf("Expected the terms of %s to add up to %d, got %d", fen, trace.Classical, sum)
+		}
+		if trace.Endgame == "" && trace.Score != trace.Classical {
+			t.Errorf("Expected the score of %s to be the classical evaluation %d, got %d", fen, trace.Classical, trace.Score)
+		}
+	}
+}
+
+func TestEvaluateTraceInitialPosition(t *testing.T) {
+	board := NewBoard()
+	board.LoadInitial()
+	trace := board.EvaluateTrace()
+	if trace.Phase != TotalPhase {
+		t.Errorf("Expected phase %d, got %d", TotalPhase, trace.Phase)
+	}
+	material := trace.Terms[0]
+	// 8 pawns, 2 knights, 2 bishops, 2 rooks and a queen
+	if material.Name != "Material" || material.White.Mg != 4039 || material.White != material.Black {
+		t.Errorf("Unexpected material term %+v", material)
+	}
+	table := trace.String()
+	for _, name := range []string{"Material", "PST", "Pawns", "Mobility", "King safety", "Final evaluation: 0 cp"} {
+		if !strings.Contains(table, name) {
+			t.Errorf("Expected the table to contain %q:\n%s", name, table)
+		}
+	}
+}
@@ -28,8 +28,8 @@ func TestEvaluate_BlackNoQueen(t *testing.T) {
 	board := NewBoard()
 	board.FromFEN("rnb1kbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1")
 	score := board.Evaluate()
-	if score != 1011 {
-		t.Errorf("Expected 1011, got %d", score)
+	if score != 996 {
+		t.Errorf("Expected 996, got %d", score)
 	}
 }
 
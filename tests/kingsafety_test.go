@@ -0,0 +1,71 @@
+package libra_test
+
+import (
+	"math/bits"
+	"testing"
+
+	. "github.com/eugenioenko/libra-chess/pkg"
+)
+
+func TestSliderAttacksStopAtBlockers(t *testing.T) {
+	// Rook on a1 blocked by a piece on a4: a2, a3, a4 and the first rank
+	if attacks := RookAttacks(56, 1<<32); attacks != 1<<48|1<<40|1<<32|0xFE<<56 {
+		t.Errorf("Unexpected rook attacks %x", attacks)
+	}
+	// Bishop on d4 of an empty board
+	if count := bits.OnesCount64(BishopAttacks(35, 0)); count != 13 {
+		t.Errorf("Expected 13 bishop attacks from d4, got %d", count)
+	}
+	// Bishop on c1 blocked by a piece on e3
+	if attacks := BishopAttacks(58, 1<<44); attacks != 1<<49|1<<51|1<<44|1<<40 {
+		t.Errorf("Unexpected bishop attacks %x", attacks)
+	}
+	if KnightAttacks[0] != 1<<10|1<<17 || bits.OnesCount64(KnightAttacks[27]) != 8 {
+		t.Errorf("Unexpected knight attacks")
+	}
+	if bits.OnesCount64(KingAttacks[63]) != 3 || bits.OnesCount64(KingAttacks[36]) != 8 {
+		t.Errorf("Unexpected king attacks")
+	}
+}
+
+func kingSafetyFEN(fen string) [2]TaperedScore {
+	board := NewBoard()
+	board.FromFEN(fen)
+	return board.EvaluateKingSafety()
+}
+
+func TestKingSafetyPawnShield(t *testing.T) {
+	intact := kingSafetyFEN("6k1/5ppp/8/8/8/8/P4PPP/6K1 w - - 0 1")
+	// The g pawn has left the shield
+	broken := kingSafetyFEN("6k1/5ppp/8/8/8/8/P4P1P/6K1 w - - 0 1")
+	if intact[ColorWhite].Mg <= broken[ColorWhite].Mg {
+		t.Errorf("Expected an intact shield to score more, got %d and %d", intact[ColorWhite].Mg, broken[ColorWhite].Mg)
+	}
+}
+
+func TestKingSafetyPawnStorm(t *testing.T) {
+	quiet := kingSafetyFEN("6k1/5pp1/7p/8/8/8/5PPP/6K1 w - - 0 1")
+	// The black h pawn stands next to the white shield
+	storm := kingSafetyFEN("6k1/5pp1/8/8/8/7p/5PP1/6K1 w - - 0 1")
+	if quiet[ColorWhite].Mg <= storm[ColorWhite].Mg {
+		t.Errorf("Expected a pawn storm to be penalized, got %d and %d", quiet[ColorWhite].Mg, storm[ColorWhite].Mg)
+	}
+}
+
+func TestKingSafetyAttackUnits(t *testing.T) {
+	// Black queen and knight far from the white king
+	calm := kingSafetyFEN("qn4k1/5ppp/8/8/8/8/5PPP/6K1 w - - 0 1")
+	// Black queen and knight attacking the white king zone
+	attacked := kingSafetyFEN("6k1/5ppp/8/8/6nq/8/5PPP/6K1 w - - 0 1")
+	if attacked[ColorWhite].Mg >= calm[ColorWhite].Mg {
+		t.Errorf("Expected the attacked king to score less, got %d and %d", attacked[ColorWhite].Mg, calm[ColorWhite].Mg)
+	}
+	if attacked[ColorWhite].Eg < attacked[ColorWhite].Mg {
+		t.Errorf("Expected king danger to weigh more in the middlegame, got %+v", attacked[ColorWhite])
+	}
+	// A lone attacker does not count as an attack
+	single := kingSafetyFEN("n5k1/5ppp/8/8/7q/8/5PPP/6K1 w - - 0 1")
+	if single[ColorWhite] != calm[ColorWhite] {
+		t.Errorf("Expected a single attacker to be ignored, got %+v and %+v", single[ColorWhite], calm[ColorWhite])
+	}
+}
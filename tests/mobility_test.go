@@ -0,0 +1,65 @@
+package libra_test
+
+import (
+	"testing"
+
+	. "github.com/eugenioenko/libra-chess/pkg"
+)
+
+func piecesFEN(fen string) [2]TaperedScore {
+	board := NewBoard()
+	board.FromFEN(fen)
+	return board.EvaluatePieces()
+}
+
+func TestEvaluatePiecesMobility(t *testing.T) {
+	corner := piecesFEN("4k3/8/8/8/8/8/8/N3K3 w - - 0 1")
+	center := piecesFEN("4k3/8/8/8/3N4/8/8/4K3 w - - 0 1")
+	if center[ColorWhite].Mg <= corner[ColorWhite].Mg {
+		t.Errorf("Expected a central knight to be more mobile, got %d and %d", center[ColorWhite].Mg, corner[ColorWhite].Mg)
+	}
+}
+
+func TestEvaluatePiecesBishopPair(t *testing.T) {
+	pair := piecesFEN("4k3/8/8/8/8/8/8/2B1KB2 w - - 0 1")
+	single := piecesFEN("4k3/8/8/8/8/8/8/2N1KB2 w - - 0 1")
+	if pair[ColorWhite].Eg <= single[ColorWhite].Eg {
+		t.Errorf("Expected the bishop pair bonus, got %d and %d", pair[ColorWhite].Eg, single[ColorWhite].Eg)
+	}
+}
+
+func TestEvaluatePiecesRookOpenFile(t *testing.T) {
+	open := piecesFEN("4k3/pp4pp/8/8/8/8/PP4PP/3RK3 w - - 0 1")
+	closed := piecesFEN("4k3/pp1p2pp/8/8/8/8/PP1P2PP/2R1K3 w - - 0 1")
+	if open[ColorWhite].Mg <= closed[ColorWhite].Mg {
+		t.Errorf("Expected a rook on an open file to score more, got %d and %d", open[ColorWhite].Mg, closed[ColorWhite].Mg)
+	}
+}
+
+func TestEvaluatePiecesKnightOutpost(t *testing.T) {
+	// The d5 knight is defended by e4 and no black pawn can attack it
+	outpost := piecesFEN("4k3/pp4pp/8/3N4/4P3/8/PP4PP/4K3 w - - 0 1")
+	// The c6 pawn can drive the knight away
+	chased := piecesFEN("4k3/pp4pp/2p5/3N4/4P3/8/PP4PP/4K3 w - - 0 1")
+	if outpost[ColorWhite].Mg <= chased[ColorWhite].Mg {
+		t.Errorf("Expected the outpost bonus, got %d and %d", outpost[ColorWhite].Mg, chased[ColorWhite].Mg)
+	}
+}
+
+func TestEvaluatePiecesTrappedRook(t *testing.T) {
+	trapped := piecesFEN("4k3/pp4pp/8/8/8/8/PP3PPP/5K1R w - - 0 1")
+	castled := piecesFEN("4k3/pp4pp/8/8/8/8/PP3PPP/5RK1 w - - 0 1")
+	if trapped[ColorWhite].Mg >= castled[ColorWhite].Mg {
+		t.Errorf("Expected the trapped rook penalty, got %d and %d", trapped[ColorWhite].Mg, castled[ColorWhite].Mg)
+	}
+}
+
+func TestEvaluatePiecesHangingThreat(t *testing.T) {
+	// The black knight on d5 is attacked by the c4 bishop and not defended
+	hanging := piecesFEN("4k3/8/8/3n4/2B5/8/8/4K3 w - - 0 1")
+	// The e6 pawn defends it
+	defended := piecesFEN("4k3/8/4p3/3n4/2B5/8/8/4K3 w - - 0 1")
+	if hanging[ColorWhite].Mg-defended[ColorWhite].Mg <= 0 {
+		t.Errorf("Expected a threat on the hanging knight, got %d and %d", hanging[ColorWhite].Mg, defended[ColorWhite].Mg)
+	}
+}
@@ -0,0 +1,149 @@
+package libra_test
+
+import (
+	"bytes"
+	"errors"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "github.com/eugenioenko/libra-chess/pkg"
+)
+
+// randomNetwork returns a small network with weights large enough to use the whole activation range
+func randomNetwork(hidden int, seed int64) *Network {
+	rng := rand.New(rand.NewSource(seed))
+	network := NewNetwork(hidden)
+	for i := range network.FeatureWeights {
+		network.FeatureWeights[i] = int16(rng.Intn(121) - 60)
+	}
+	for i := range network.FeatureBias {
+		network.FeatureBias[i] = int16(rng.Intn(201))
+	}
+	for i := range network.OutputWeights {
+		network.OutputWeights[i] = int16(rng.Intn(257) - 128)
+	}
+	network.OutputBias = int16(rng.Intn(2001) - 1000)
+	return network
+}
+
+// checkIncrementalNetwork compares the incremental evaluation with a fresh one at every node
+func checkIncrementalNetwork(t *testing.T, board *Board, depth int) {
+	if score, fresh := board.Evaluate(), board.Clone().Evaluate(); score != fresh {
+		t.Fatalf("Incremental evaluation %d differs from refreshed %d at %s", score, fresh, board.ToFEN())
+	}
+	if depth == 0 {
+		return
+	}
+	for _, move := range board.GenerateLegalMoves() {
+		prev := board.Move(move)
+		checkIncrementalNetwork(t, board, depth-1)
+		board.UndoMove(prev)
+	}
+}
+
+func TestNetworkIncrementalMatchesRefresh(t *testing.T) {
+	SetNetwork(randomNetwork(16, 1))
+	defer SetNetwork(nil)
+	fens := []string{
+		"r3k2r/p1ppqpb1/bn2pnp1/3PN3/1p2P3/2N2Q1p/PPPBBPPP/R3K2R w KQkq - 0 1", // castling, en passant
+		"n1n5/PPPk4/8/8/8/8/4Kppp/5N1N b - - 0 1",                              // promotions and promotion captures
+	}
+	for _, fen := range fens {
+		board := NewBoard()
+		board.FromFEN(fen)
+		checkIncrementalNetwork(t, board, 3)
+	}
+}
+
+func TestNetworkIsColorSymmetric(t *testing.T) {
+	SetNetwork(randomNetwork(16, 2))
+	defer SetNetwork(nil)
+	for _, fen := range []string{
+		"r1bqkbnr/pppp1ppp/2n5/4p3/4P3/5N2/PPPP1PPP/RNBQKB1R w KQkq - 2 3",
+		"8/5pk1/6p1/8/3P4/5K2/8/8 b - - 0 1",
+	} {
+		if score, mirrored := evaluateFEN(fen), evaluateFEN(mirrorFEN(fen)); score != -mirrored {
+			t.Errorf("Expected %s to evaluate to the opposite of its mirror, got %d and %d", fen, score, mirrored)
+		}
+	}
+}
+
+func TestNetworkLargeOutputWeights(t *testing.T) {
+	// Every hidden value saturates, the sums go far past 32 bits before the clamp
+	network := NewNetwork(256)
+	for i := range network.FeatureBias {
+		network.FeatureBias[i] = NNUEQA
+	}
+	SetNetwork(network)
+	defer SetNetwork(nil)
+	board := NewBoard()
+	board.LoadInitial()
+	for _, weight := range []int16{127, 32767, -127, -32767} {
+		for i := range network.OutputWeights {
+			network.OutputWeights[i] = weight
+		}
+		expected := 30_000
+		if weight < 0 {
+			expected = -expected
+		}
+		if score := board.Clone().Evaluate(); score != expected {
+			t.Errorf("Expected output weights of %d to evaluate to %d, got %d", weight, expected, score)
+		}
+	}
+}
+
+func TestEvaluateWithoutNetworkIsClassical(t *testing.T) {
+	board := NewBoard()
+	board.FromFEN("r1bqkbnr/pppp1ppp/2n5/4p3/4P3/5N2/PPPP1PPP/RNBQKB1R w KQkq - 2 3")
+	if ActiveNetwork() != nil || board.Evaluate() != board.EvaluateClassical() {
+		t.Error("Expected the classical evaluation without a network")
+	}
+}
+
+func TestNetworkSaveLoadRoundTrip(t *testing.T) {
+	network := randomNetwork(8, 3)
+	var buf bytes.Buffer
+	if err := network.Save(&buf); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	data := buf.Bytes()
+	if len(data) != 12+2*(768*8+8+2*8+1) {
+		t.Errorf("Unexpected file size %d", len(data))
+	}
+	loaded, err := LoadNetwork(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if loaded.Hidden != 8 || loaded.OutputBias != network.OutputBias ||
+		loaded.FeatureWeights[1000] != network.FeatureWeights[1000] || loaded.OutputWeights[15] != network.OutputWeights[15] {
+		t.Error("Expected the loaded network to match the saved one")
+	}
+	for _, bad := range [][]byte{data[:len(data)-1], append(append([]byte{}, data...), 0), []byte("{}")} {
+		if _, err := LoadNetwork(bytes.NewReader(bad)); !errors.Is(err, ErrInvalidNetwork) {
+			t.Errorf("Expected an invalid network error, got %v", err)
+		}
+	}
+}
+
+func TestEngineEvalFileNetwork(t *testing.T) {
+	engine := NewEngine()
+	defer engine.SetOption("EvalFile", DefaultEvalFile)
+	path := filepath.Join(t.TempDir(), "libra.nnue")
+	if err := randomNetwork(8, 4).SaveFile(path); err != nil {
+		t.Fatal(err)
+	}
+	if err := engine.SetOption("EvalFile", path); err != nil || ActiveNetwork() == nil {
+		t.Fatalf("Expected the network to be in use, got %v", err)
+	}
+	if err := os.WriteFile(path, []byte("LBNN broken"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := engine.SetOption("EvalFile", path); !errors.Is(err, ErrInvalidNetwork) {
+		t.Errorf("Expected a broken network to fail, got %v", err)
+	}
+	if err := engine.SetOption("EvalFile", DefaultEvalFile); err != nil || ActiveNetwork() != nil {
+		t.Errorf("Expected the classical evaluation to be restored, got %v", err)
+	}
+}
@@ -0,0 +1,105 @@
+package libra_test
+
+import (
+	"strings"
+	"testing"
+
+	. "github.com/eugenioenko/libra-chess/pkg"
+)
+
+// mirrorFEN flips the board vertically and swaps the colors
+func mirrorFEN(fen string) string {
+	fields := strings.Fields(fen)
+	ranks := strings.Split(fields[0], "/")
+	for i, j := 0, len(ranks)-1; i < j; i, j = i+1, j-1 {
+		ranks[i], ranks[j] = ranks[j], ranks[i]
+	}
+	swapCase := func(s string) string {
+		return strings.Map(func(r rune) rune {
+			if r >= 'a' && r <= 'z' {
+				return r - 'a' + 'A'
+			}
+			if r >= 'A' && r <= 'Z' {
+				return r - 'A' + 'a'
+			}
+			return r
+		}, s)
+	}
+	side := "w"
+	if fields[1] == "w" {
+		side = "b"
+	}
+	return swapCase(strings.Join(ranks, "/")) + " " + side + " - - 0 1"
+}
+
+func evaluateFEN(fen string) int {
+	board := NewBoard()
+	board.FromFEN(fen)
+	return board.Evaluate()
+}
+
+func TestEvaluateIsColorSymmetric(t *testing.T) {
+	fens := []string{
+		"4k3/8/8/3P4/8/8/8/4K3 w - - 0 1",
+		"4k3/pp4p1/8/2P1p3/8/1P6/P1P2PPP/4K3 w - - 0 1",
+		"r1bqk2r/pp3ppp/2n1pn2/2pp4/1bPP4/2N1PN2/PP1B1PPP/R2QKB1R w KQkq - 0 1",
+		"8/5k2/8/1p6/1P1K4/8/8/8 b - - 0 1",
+		"6k1/5pp1/8/8/6nq/7p/5PP1/2R3K1 w - - 0 1",
+		"r3k2r/1P4pp/2p5/3N4/4P3/2b5/PP3PPP/5K1R b - - 0 1",
+	}
+	for _, fen := range fens {
+		if score, mirrored := evaluateFEN(fen), evaluateFEN(mirrorFEN(fen)); score != -mirrored {
+			t.Errorf("Expected %s to evaluate to the opposite of its mirror, got %d and %d", fen, score, mirrored)
+		}
+	}
+}
+
+func TestEvaluatePawnsPassedPawn(t *testing.T) {
+	board := NewBoard()
+	// White d5 is passed, black h7 is not
+	board.FromFEN("4k3/7p/8/3P4/8/8/6P1/4K3 w - - 0 1")
+	pawns := board.EvaluatePawns()
+	if pawns[ColorWhite].Eg <= pawns[ColorBlack].Eg {
+		t.Errorf("Expected the passed pawn to score, got %+v vs %+v", pawns[ColorWhite], pawns[ColorBlack])
+	}
+	// A more advanced passer is worth more
+	board.FromFEN("4k3/3P3p/8/8/8/8/6P1/4K3 w - - 0 1")
+	advanced := board.EvaluatePawns()
+	if advanced[ColorWhite].Eg <= pawns[ColorWhite].Eg {
+		t.Errorf("Expected the advanced passer to score more, got %d <= %d", advanced[ColorWhite].Eg, pawns[ColorWhite].Eg)
+	}
+}
+
+func TestEvaluatePawnsStructurePenalties(t *testing.T) {
+	board := NewBoard()
+	board.FromFEN("4k3/8/8/8/8/8/3PP3/4K3 w - - 0 1")
+	healthy := board.EvaluatePawns()[ColorWhite]
+	// Doubled and isolated
+	board.FromFEN("4k3/8/8/8/8/3P4/3P4/4K3 w - - 0 1")
+	weak := board.EvaluatePawns()[ColorWhite]
+	if weak.Mg >= healthy.Mg || weak.Eg >= healthy.Eg {
+		t.Errorf("Expected doubled isolated pawns to score less than connected ones, got %+v vs %+v", weak, healthy)
+	}
+}
+
+func TestEvaluatePawnsBlockedPasserKingDistance(t *testing.T) {
+	board := NewBoard()
+	// Same passer, defending king in front of it or far away
+	board.FromFEN("8/8/3k4/8/3P4/8/8/3K4 w - - 0 1")
+	near := board.EvaluatePawns()[ColorWhite]
+	board.FromFEN("k7/8/8/8/3P4/8/8/3K4 w - - 0 1")
+	far := board.EvaluatePawns()[ColorWhite]
+	if far.Eg <= near.Eg {
+		t.Errorf("Expected the passer to score more with the enemy king far away, got %d <= %d", far.Eg, near.Eg)
+	}
+}
+
+func TestPawnHashTableMatchesUncached(t *testing.T) {
+	board := NewBoard()
+	board.FromFEN("r1bqk2r/pp3ppp/2n1pn2/2pp4/1bPP4/2N1PN2/PP1B1PPP/R2QKB1R w KQkq - 0 1")
+	uncached := board.Evaluate()
+	board.SetPawnHashTable(NewPawnHashTable())
+	if first, second := board.Evaluate(), board.Evaluate(); first != uncached || second != uncached {
+		t.Errorf("Expected cached evaluations to match %d, got %d and %d", uncached, first, second)
+	}
+}
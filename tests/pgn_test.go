@@ -0,0 +1,105 @@
+package libra_test
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	. "github.com/eugenioenko/libra-chess/pkg"
+)
+
+func TestParseSAN(t *testing.T) {
+	tests := []struct {
+		fen string
+		san string
+		uci string
+	}{
+		{"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1", "Nf3", "g1f3"},
+		{"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1", "e4!?", "e2e4"},
+		{"rnbqkbnr/ppp1pppp/8/3p4/4P3/8/PPPP1PPP/RNBQKBNR w KQkq d6 0 2", "exd5", "e4d5"},
+		{"r3k2r/8/8/8/8/8/8/R3K2R w KQkq - 0 1", "O-O", "e1g1"},
+		{"r3k2r/8/8/8/8/8/8/R3K2R b KQkq - 0 1", "0-0-0", "e8c8"},
+		{"8/1P6/8/8/8/8/6k1/K7 w - - 0 1", "b8=N", "b7b8n"},
+		{"8/1P6/8/8/8/8/6k1/K7 w - - 0 1", "b8Q+", "b7b8q"},
+		{"8/8/8/8/8/8/6k1/R3K2R w - - 0 1", "Rad1", "a1d1"},
+		{"8/8/8/8/8/R5k1/8/R3K3 w - - 0 1", "R1a2", "a1a2"},
+		{"8/8/8/8/8/R5k1/8/R3K3 w - - 0 1", "R3a2", "a3a2"},
+		{"8/8/8/8/3pP3/8/6k1/K7 b - e3 0 1", "dxe3", "d4e3"},
+	}
+	board := NewBoard()
+	for _, test := range tests {
+		board.FromFEN(test.fen)
+		move, err := board.ParseSAN(test.san)
+		if err != nil || move.ToUCI() != test.uci {
+			t.Errorf("Expected %s to be %s in %s, got %v (%v)", test.san, test.uci, test.fen, move, err)
+		}
+	}
+
+	// Ra2 is ambiguous
+	board.FromFEN("8/8/8/8/8/R5k1/8/R3K3 w - - 0 1")
+	for _, san := range []string{"Ra2", "Nf3", "e5", "O-O", "Rz9", ""} {
+		if _, err := board.ParseSAN(san); !errors.Is(err, ErrInvalidSAN) {
+			t.Errorf("Expected %q to be rejected, got %v", san, err)
+		}
+	}
+}
+
+func TestPGNReader(t *testing.T) {
+	pgn := `[Event "Casual"]
+[White "Morphy, Paul"]
+[Black "Duke Karl \"and\" Count Isouard"]
+[Result "1-0"]
+
+1. e4 e5 2. Nf3 d6 {Philidor} 3. d4 Bg4 (3... exd4 4. Nxd4) 4.dxe5 $2 Bxf3
+5. Qxf3 dxe5 6. Bc4 Nf6 7. Qb3 Qe7 8. Nc3 c6 9. Bg5 b5 ; the rest is known
+10. Nxb5 cxb5 11. Bxb5+ Nbd7 12. O-O-O Rd8 13. Rxd7 Rxd7 14. Rd1 Qe6 15. Bxd7+
+Nxd7 16. Qb8+ Nxb8 17. Rd8# 1-0
+
+% An escaped line
+[Event "No result in the movetext"]
+[Result "1/2-1/2"]
+
+1. d4 d5 2. c4 {a comment
+over two lines} e6
+[Event "Last"]
+[FEN "8/8/8/8/8/8/6k1/R3K2R w - - 0 1"]
+
+1. 0-0 Kg3 *
+`
+	reader := NewPGNReader(strings.NewReader(pgn))
+	first, err := reader.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(first.Moves) != 33 || first.Moves[6] != "dxe5" || first.Moves[32] != "Rd8#" || first.Result != "1-0" || first.Line != 1 {
+		t.Errorf("Expected the 33 plies of the Opera Game, got %d plies %v, %s at line %d", len(first.Moves), first.Moves, first.Result, first.Line)
+	}
+	if first.Tags["Black"] != `Duke Karl "and" Count Isouard` {
+		t.Errorf("Expected the escaped tag to be read, got %q", first.Tags["Black"])
+	}
+	second, err := reader.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(second.Moves, " ") != "d4 d5 c4 e6" || second.Result != "1/2-1/2" || second.Line != 12 {
+		t.Errorf("Expected the second game with the result of its tag, got %v, %s at line %d", second.Moves, second.Result, second.Line)
+	}
+	third, err := reader.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	board, err := third.Start()
+	if err != nil || strings.Join(third.Moves, " ") != "0-0 Kg3" || third.Result != "*" || board.CountPieces() != 4 {
+		t.Errorf("Expected the last game from its FEN, got %v, %s (%v)", third.Moves, third.Result, err)
+	}
+	if _, err := reader.Next(); err != io.EOF {
+		t.Errorf("Expected io.EOF after the last game, got %v", err)
+	}
+
+	for _, bad := range []string{"[Event Casual]\n1. e4 *\n", "1. e4 ) e5 *\n", "1. e4 {unterminated\n"} {
+		if _, err := NewPGNReader(strings.NewReader(bad)).Next(); !errors.Is(err, ErrInvalidPGN) {
+			t.Errorf("Expected %q to be rejected, got %v", bad, err)
+		}
+	}
+}
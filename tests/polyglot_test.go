@@ -0,0 +1,118 @@
+package libra_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "github.com/eugenioenko/libra-chess/pkg"
+)
+
+func TestPolyglotKey(t *testing.T) {
+	// The examples of the Polyglot book format specification
+	tests := []struct {
+		moves []string
+		key   uint64
+	}{
+		{nil, 0x463b96181691fc9c},
+		{[]string{"e2e4"}, 0x823c9b50fd114196},
+		{[]string{"e2e4", "d7d5"}, 0x0756b94461c50fb0},
+		{[]string{"e2e4", "d7d5", "e4e5"}, 0x662fafb965db29d4},
+		{[]string{"e2e4", "d7d5", "e4e5", "f7f5"}, 0x22a48b5a8e47ff78},
+		{[]string{"e2e4", "d7d5", "e4e5", "f7f5", "e1e2"}, 0x652a607ca3f242c1},
+		{[]string{"e2e4", "d7d5", "e4e5", "f7f5", "e1e2", "e8f7"}, 0x00fdd303c946bdd9},
+		{[]string{"a2a4", "b7b5", "h2h4", "b5b4", "c2c4"}, 0x3c8123ea7b067637},
+		{[]string{"a2a4", "b7b5", "h2h4", "b5b4", "c2c4", "b4c3", "a1a3"}, 0x5c3f9b829b279560},
+	}
+	for _, test := range tests {
+		board := NewBoard()
+		board.LoadInitial()
+		for _, uci := range test.moves {
+			move := board.ParseUCIMove(uci)
+			if move == nil {
+				t.Fatalf("Expected %s to be legal after %v", uci, test.moves)
+			}
+			board.Move(*move)
+		}
+		if key := board.PolyglotKey(); key != test.key {
+			t.Errorf("Expected the key %#016x after %v, got %#016x", test.key, test.moves, key)
+		}
+	}
+}
+
+func TestPolyglotRoundTrip(t *testing.T) {
+	book := NewBook()
+	board := NewBoard()
+	board.FromFEN("r3k2r/pppq1ppp/2n2n2/8/8/2N2N2/PPPQ1PPP/R3K2R w KQkq - 0 1")
+	castle := board.ParseUCIMove("e1g1")
+	book.Add(board, *castle, 100_000)
+	book.Add(board, *board.ParseUCIMove("e1c1"), 50_000)
+	book.Add(board, *board.ParseUCIMove("a2a3"), 1)
+	promotion := NewBoard()
+	promotion.FromFEN("8/1P6/8/8/8/8/6k1/K7 w - - 0 1")
+	book.Add(promotion, *promotion.ParseUCIMove("b7b8n"), 3)
+
+	var buf bytes.Buffer
+	if err := book.SavePolyglot(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if buf.Len() != 4*16 {
+		t.Fatalf("Expected 4 entries of 16 bytes, got %d bytes", buf.Len())
+	}
+	// Castling is stored as the king taking its rook
+	if !bytes.Contains(buf.Bytes(), []byte{0x01, 0x07}) {
+		t.Error("Expected e1g1 to be stored as e1h1")
+	}
+	loaded, err := LoadPolyglot(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	moves := loaded.Moves(board)
+	if len(moves) != 3 || moves[0].Move != *castle || moves[0].Weight != 0xFFFF || moves[1].Weight != 0x7FFF || moves[2].Weight != 1 {
+		t.Errorf("Expected the castling moves with scaled weights, got %v", moves)
+	}
+	if moves := loaded.Moves(promotion); len(moves) != 1 || moves[0].Move.ToUCI() != "b7b8n" || moves[0].Weight != 3 {
+		t.Errorf("Expected the knight promotion, got %v", moves)
+	}
+
+	if _, err := LoadPolyglot(bytes.NewReader(make([]byte, 20))); !errors.Is(err, ErrInvalidBook) {
+		t.Errorf("Expected a truncated book to be rejected, got %v", err)
+	}
+}
+
+func TestEnginePolyglotBook(t *testing.T) {
+	book, err := InternalBook()
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "book.bin")
+	var buf bytes.Buffer
+	if err := book.SavePolyglot(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, buf.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	engine := NewEngine()
+	if err := engine.SetOption("BookFile", path); err != nil {
+		t.Fatal(err)
+	}
+	engine.SetOption("BookRandomness", "0")
+	board := NewBoard()
+	board.LoadInitial()
+	board.Move(*board.ParseUCIMove("d2d4"))
+	infos, err := engine.Analyze(context.Background(), board, GoOptions{MoveTime: 1000})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var final SearchInfo
+	for info := range infos {
+		final = info
+	}
+	if final.BestMove == nil || final.BestMove.ToUCI() != "g8f6" || final.Depth != 0 {
+		t.Errorf("Expected the most played reply g8f6 from the Polyglot book, got %v", final.BestMove)
+	}
+}
@@ -0,0 +1,50 @@
+package libra_test
+
+import (
+	"errors"
+	"math"
+	"testing"
+
+	. "github.com/eugenioenko/libra-chess/pkg"
+)
+
+func TestFitRatingsRecoversElo(t *testing.T) {
+	elo := []float64{800, 1000, 1250, 1300}
+	expected := func(a, b int) float64 {
+		return 1 / (1 + math.Pow(10, (elo[b]-elo[a])/400))
+	}
+	var results []RatingResult
+	for _, pair := range [][2]int{{0, 1}, {1, 2}, {2, 3}, {0, 2}} {
+		games := 10_000
+		results = append(results, RatingResult{A: pair[0], B: pair[1], Score: float64(games) * expected(pair[0], pair[1]), Games: games})
+	}
+	ratings, errs, err := FitRatings(len(elo), results, 0, 800)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range elo {
+		if math.Abs(ratings[i]-elo[i]) > 2 {
+			t.Errorf("Expected player %d at %.0f, got %.1f", i, elo[i], ratings[i])
+		}
+	}
+	if errs[0] != 0 || errs[3] <= errs[1] || errs[3] > 20 {
+		t.Errorf("Unexpected standard errors %v", errs)
+	}
+}
+
+func TestFitRatingsOneSided(t *testing.T) {
+	ratings, _, err := FitRatings(2, []RatingResult{{A: 0, B: 1, Score: 0, Games: 10}}, 0, 800)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if math.IsInf(ratings[1], 0) || math.IsNaN(ratings[1]) || ratings[1] <= 1000 {
+		t.Errorf("Expected a large finite rating for the winner, got %.1f", ratings[1])
+	}
+}
+
+func TestFitRatingsUnconnected(t *testing.T) {
+	results := []RatingResult{{A: 0, B: 1, Score: 5, Games: 10}, {A: 2, B: 3, Score: 5, Games: 10}}
+	if _, _, err := FitRatings(4, results, 0, 800); !errors.Is(err, ErrRatingUnconnected) {
+		t.Errorf("Expected ErrRatingUnconnected, got %v", err)
+	}
+}
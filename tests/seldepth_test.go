@@ -0,0 +1,40 @@
+package libra_test
+
+import (
+	"testing"
+
+	. "github.com/eugenioenko/libra-chess/pkg"
+)
+
+func TestSelDepthIncludesQuiescence(t *testing.T) {
+	board := NewBoard()
+	// Open middlegame with many pending captures
+	board.FromFEN("r1bqkb1r/pppp1ppp/2n2n2/4p3/2B1P3/5N2/PPPP1PPP/RNBQK2R w KQkq - 4 4")
+	result := board.Search(3, NewTranspositionTable(), 0, nil, nil)
+	if result.SelDepth <= 3 {
+		t.Errorf("Expected quiescence search to reach past depth 3, got seldepth %d", result.SelDepth)
+	}
+	if len(result.NodesAtPly) != result.SelDepth+1 {
+		t.Fatalf("Expected %d plies in the node distribution, got %d", result.SelDepth+1, len(result.NodesAtPly))
+	}
+	for ply := 1; ply <= 3; ply++ {
+		if result.NodesAtPly[ply] == 0 {
+			t.Errorf("Expected nodes at ply %d", ply)
+		}
+	}
+	if result.NodesAtPly[result.SelDepth] == 0 {
+		t.Errorf("Expected nodes at the selective depth %d", result.SelDepth)
+	}
+}
+
+func TestSelDepthResetsEachIteration(t *testing.T) {
+	board := NewBoard()
+	board.LoadInitial()
+	threads := NewSearchThreads(2)
+	tt := NewTranspositionTable()
+	deep := board.SearchWithThreads(4, tt, threads, 0, nil, nil)
+	shallow := board.SearchWithThreads(1, tt, threads, 0, nil, nil)
+	if shallow.SelDepth >= deep.SelDepth {
+		t.Errorf("Expected a shallower seldepth at depth 1, got %d >= %d", shallow.SelDepth, deep.SelDepth)
+	}
+}
@@ -0,0 +1,93 @@
+package libra_test
+
+import (
+	"math/rand"
+	"testing"
+
+	. "github.com/eugenioenko/libra-chess/pkg"
+)
+
+func TestSkillFromElo(t *testing.T) {
+	if NewSkill(0).Elo() != MinSkillElo || NewSkill(MaxSkillLevel-1).Elo() != MaxSkillElo || NewSkill(MaxSkillLevel).Elo() != MaxSkillElo {
+		t.Errorf("Expected the Elo range to be the measured ratings of levels 0 and %d", MaxSkillLevel-1)
+	}
+	for level := 0; level < MaxSkillLevel; level++ {
+		elo := NewSkill(level).Elo()
+		if level > 0 && elo <= NewSkill(level-1).Elo() {
+			t.Errorf("Expected level %d to be rated above level %d, got %d", level, level-1, elo)
+		}
+		if skill := NewSkillFromElo(elo); skill.Level != level {
+			t.Errorf("Expected elo %d to map to level %d, got %d", elo, level, skill.Level)
+		}
+	}
+	// Between two levels, the closest one
+	low, high := NewSkill(9).Elo(), NewSkill(10).Elo()
+	cases := map[int]int{low + (high-low)/3: 9, high - (high-low)/3: 10, 400: 0, 3000: MaxSkillLevel - 1}
+	for elo, level := range cases {
+		if skill := NewSkillFromElo(elo); skill.Level != level {
+			t.Errorf("Expected elo %d to map to level %d, got %d", elo, level, skill.Level)
+		}
+	}
+	if NewSkill(MaxSkillLevel).Enabled() {
+		t.Errorf("Expected full strength skill to be disabled")
+	}
+}
+
+func TestSkillPickMoveAvoidsBlunders(t *testing.T) {
+	board := NewBoard()
+	board.LoadInitial()
+	moves := board.GenerateLegalMoves()
+	rootMoves := []RootMove{
+		{Move: moves[0], Score: -30},
+		{Move: moves[1], Score: -20},
+		{Move: moves[2], Score: -MaxEvaluationScore},
+		{Move: moves[3], Score: -900},
+	}
+	skill := NewSkill(0)
+	skill.Rand = rand.New(rand.NewSource(1))
+	picked := map[Move]int{}
+	for i := 0; i < 200; i++ {
+		// Black to move: lower scores are better for black
+		picked[*skill.PickMove(rootMoves, false)]++
+	}
+	if picked[moves[0]] > 0 || picked[moves[1]] > 0 {
+		t.Errorf("Expected black to never pick moves scored in white's favor, got %v", picked)
+	}
+	if picked[moves[2]] == 0 {
+		t.Errorf("Expected black to pick the mating move at least once")
+	}
+}
+
+func TestSkillPickMoveVaries(t *testing.T) {
+	board := NewBoard()
+	board.LoadInitial()
+	moves := board.GenerateLegalMoves()
+	rootMoves := []RootMove{
+		{Move: moves[0], Score: 40},
+		{Move: moves[1], Score: 30},
+		{Move: moves[2], Score: 20},
+		{Move: moves[3], Score: 10},
+	}
+	skill := NewSkill(0)
+	skill.Rand = rand.New(rand.NewSource(1))
+	picked := map[Move]bool{}
+	for i := 0; i < 100; i++ {
+		picked[*skill.PickMove(rootMoves, true)] = true
+	}
+	if len(picked) < 2 {
+		t.Errorf("Expected the weakest level to vary its moves, got %d distinct", len(picked))
+	}
+}
+
+func TestSearchNodeLimit(t *testing.T) {
+	board := NewBoard()
+	board.LoadInitial()
+	move := board.IterativeDeepeningSearch(SearchOptions{MaxNodes: 2_000})
+	if move == nil {
+		t.Fatalf("Expected a move with a node limit")
+	}
+	weak := board.IterativeDeepeningSearch(SearchOptions{Skill: NewSkillFromElo(MinSkillElo)})
+	if weak == nil || board.ParseUCIMove(weak.ToUCI()) == nil {
+		t.Errorf("Expected a legal move at the weakest level, got %v", weak)
+	}
+}
@@ -0,0 +1,86 @@
+package libra_test
+
+import (
+	"errors"
+	"math/rand"
+	"testing"
+
+	. "github.com/eugenioenko/libra-chess/pkg"
+)
+
+func TestDefaultSearchParamsMatchSpecs(t *testing.T) {
+	params := DefaultSearchParams()
+	for _, spec := range SearchParamSpecs {
+		if value := *spec.Value(&params); value != spec.Default {
+			t.Errorf("Expected %s to default to %d, got %d", spec.Name, spec.Default, value)
+		}
+		if spec.Default < spec.Min || spec.Default > spec.Max || spec.Step <= 0 {
+			t.Errorf("Invalid spec %+v", spec)
+		}
+	}
+}
+
+func TestSearchParamsClamp(t *testing.T) {
+	params := DefaultSearchParams()
+	params.KillerBonus = -5
+	params.QuietHistoryDivisor = 0
+	params.HistoryBonusMax = 1 << 30
+	params.Clamp()
+	if params.KillerBonus != 0 || params.QuietHistoryDivisor != 1 || params.HistoryBonusMax != HistoryMax {
+		t.Errorf("Unexpected clamped parameters: %s", params)
+	}
+}
+
+func TestEngineSearchParamOptions(t *testing.T) {
+	engine := NewEngine()
+	announced := false
+	for _, option := range engine.UCIOptions() {
+		if option.Name == "KillerBonus" && option.Type == "spin" {
+			announced = true
+		}
+	}
+	if !announced {
+		t.Error("Expected KillerBonus to be announced as a spin option")
+	}
+	if err := engine.SetOption("killerbonus", "12000"); err != nil || engine.Options.Search.KillerBonus != 12000 {
+		t.Errorf("Expected KillerBonus 12000, got %d (%v)", engine.Options.Search.KillerBonus, err)
+	}
+	if err := engine.SetOption("QuietHistoryDivisor", "0"); !errors.Is(err, ErrInvalidOption) {
+		t.Errorf("Expected out of range divisor to be rejected, got %v", err)
+	}
+}
+
+func TestPlayGameDetectsMate(t *testing.T) {
+	board := NewBoard()
+	board.FromFEN("6k1/5ppp/8/8/8/8/8/R5K1 w - - 0 1")
+	white := NewSelfPlayer(DefaultSearchParams())
+	black := NewSelfPlayer(DefaultSearchParams())
+	if result := PlayGame(white, black, board, 5_000); result != 1 {
+		t.Errorf("Expected white to mate, got %v", result)
+	}
+	if board.FullMoveCounter != 1 || !board.WhiteToMove {
+		t.Error("Expected the starting board to be left unchanged")
+	}
+	board.FromFEN("8/8/4k3/8/8/3K4/8/8 w - - 0 1")
+	if result := PlayGame(white, black, board, 5_000); result != 0.5 {
+		t.Errorf("Expected a draw by insufficient material, got %v", result)
+	}
+}
+
+func TestSPSAStepsTowardsWinner(t *testing.T) {
+	spsa := NewSPSA(DefaultSearchParams(), 100)
+	plus, minus, delta := spsa.Perturb(rand.New(rand.NewSource(1)))
+	if plus == minus {
+		t.Fatal("Expected the perturbed configurations to differ")
+	}
+	before := append([]float64{}, spsa.Theta...)
+	spsa.Update(delta, 2)
+	for i, theta := range spsa.Theta {
+		if (theta-before[i])*delta[i] <= 0 {
+			t.Errorf("Expected %s to move along the winning direction, %v to %v", SearchParamSpecs[i].Name, before[i], theta)
+		}
+	}
+	if spsa.Iteration != 1 {
+		t.Errorf("Expected 1 completed iteration, got %d", spsa.Iteration)
+	}
+}
@@ -0,0 +1,174 @@
+package libra_test
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	. "github.com/eugenioenko/libra-chess/pkg"
+)
+
+// syzygyFixtures returns the directory of the official Syzygy test tables, see
+// testdata/syzygy/README.md. The tests are skipped while a table is missing.
+func syzygyFixtures(t *testing.T) string {
+	dir := filepath.Join("testdata", "syzygy")
+	for _, name := range []string{"KPvK", "KQvK", "KRvK"} {
+		for _, extension := range []string{SyzygyWDLExtension, SyzygyDTZExtension} {
+			if _, err := os.Stat(filepath.Join(dir, name+extension)); err != nil {
+				t.Skipf("Syzygy table missing from testdata/syzygy, see its README: %v", err)
+			}
+		}
+	}
+	return dir
+}
+
+func loadSyzygyFixtures(t *testing.T) *SyzygyTablebase {
+	syzygy, err := LoadSyzygy(syzygyFixtures(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { syzygy.Close() })
+	return syzygy
+}
+
+func TestSyzygyMatchesTablebase(t *testing.T) {
+	syzygy := loadSyzygyFixtures(t)
+	if tables := syzygy.Tables(); !reflect.DeepEqual(tables, []string{"KPvK", "KQvK", "KRvK"}) || syzygy.MaxPieces() != 3 {
+		t.Fatalf("Expected the KPvK, KQvK and KRvK tables, got %v", tables)
+	}
+	tb := generateThreeMan(t)
+	rng := rand.New(rand.NewSource(1))
+	board := NewBoard()
+	pieces := []byte{WhiteQueen, WhiteRook, WhitePawn, BlackQueen, BlackRook, BlackPawn}
+	checked := map[bool]int{}
+	for checked[true]+checked[false] < 5_000 {
+		board.Reset()
+		squares := rng.Perm(64)[:3]
+		piece := pieces[rng.Intn(len(pieces))]
+		pawn := piece == WhitePawn || piece == BlackPawn
+		if pawn && (squares[2] < 8 || squares[2] >= 56) {
+			continue
+		}
+		board.SetPiece(byte(squares[0]), WhiteKing)
+		board.SetPiece(byte(squares[1]), BlackKing)
+		board.SetPiece(byte(squares[2]), piece)
+		board.WhiteToMove = rng.Intn(2) == 0
+		if board.IsSquareAttacked(board.PassiveKingSquare(), !board.WhiteToMove) {
+			continue
+		}
+		expected, ok := tb.Probe(board)
+		if !ok {
+			t.Fatalf("Expected %s in the tablebase", board.ToFEN())
+		}
+		wdl, ok := syzygy.ProbeWDL(board)
+		if !ok || wdl != 2*expected.WDL {
+			t.Fatalf("Expected %s to probe %d, got %d (%v)", board.ToFEN(), 2*expected.WDL, wdl, ok)
+		}
+		dtz, ok := syzygy.ProbeDTZ(board)
+		if !ok || (dtz > 0) != (wdl > 0) || (dtz < 0) != (wdl < 0) {
+			t.Fatalf("Expected %s to have a DTZ of the sign of %d, got %d (%v)", board.ToFEN(), wdl, dtz, ok)
+		}
+		// Without pawns nothing but mate zeroes the counter, the DTZ is the distance to mate
+		if distance := dtz*expected.WDL - expected.DTM; !pawn && wdl != 0 && (distance < 0 || distance > 1) {
+			t.Fatalf("Expected %s to have a DTZ of %d plies, got %d", board.ToFEN(), expected.DTM, dtz)
+		}
+		checked[expected.WDL != 0]++
+	}
+	if checked[true] == 0 || checked[false] == 0 {
+		t.Errorf("Expected decisive and drawn positions, got %v", checked)
+	}
+
+	for _, test := range []struct {
+		fen      string
+		wdl, dtz int
+	}{
+		{"7k/8/6K1/8/8/8/8/1Q6 w - - 0 1", 2, 1},
+		{"8/8/8/8/P4k2/8/8/K7 w - - 0 1", 2, 1},
+		{"8/8/8/8/P4k2/8/8/K7 b - - 0 1", 0, 0},
+		{"8/8/8/8/8/2k5/1Q6/7K b - - 0 1", 0, 0},
+		{"6k1/8/6K1/8/8/8/8/R7 w - - 0 1", 2, 1},
+		{"k7/2Q5/1K6/8/8/8/8/8 b - - 0 1", 0, 0},
+	} {
+		board.FromFEN(test.fen)
+		wdl, okWDL := syzygy.ProbeWDL(board)
+		dtz, okDTZ := syzygy.ProbeDTZ(board)
+		if !okWDL || !okDTZ || wdl != test.wdl || dtz != test.dtz {
+			t.Errorf("Expected %s to probe %d with a DTZ of %d, got %d and %d", test.fen, test.wdl, test.dtz, wdl, dtz)
+		}
+	}
+	board.FromFEN("8/8/8/4k3/8/8/8/R3K2R w KQ - 0 1")
+	if _, ok := syzygy.ProbeWDL(board); ok {
+		t.Error("Expected positions with castling rights not to be found")
+	}
+}
+
+func TestSyzygyRootMoves(t *testing.T) {
+	syzygy := loadSyzygyFixtures(t)
+	SetSyzygy(syzygy)
+	defer SetSyzygy(nil)
+
+	// Only the pawn push keeps the win, the king is outside the square
+	board := NewBoard()
+	board.FromFEN("8/8/8/8/P4k2/8/8/K7 w - - 0 1")
+	for i := 0; i < 3; i++ {
+		move := board.IterativeDeepeningSearch(SearchOptions{MaxDepth: 1})
+		if move == nil || move.ToUCI() != "a4a5" {
+			t.Fatalf("Expected a4a5 to keep the win, got %v", move)
+		}
+	}
+
+}
+
+func TestEngineSyzygyOptions(t *testing.T) {
+	dir := syzygyFixtures(t)
+	engine := NewEngine()
+	defer engine.SetOption("SyzygyPath", DefaultSyzygyPath)
+	if err := engine.SetOption("SyzygyProbeDepth", "0"); err == nil {
+		t.Error("Expected a probe depth of 0 to be rejected")
+	}
+	if err := engine.SetOption("Syzygy50MoveRule", "false"); err != nil || engine.Options.Syzygy50MoveRule {
+		t.Errorf("Expected the 50-move rule to be disabled, got %v", err)
+	}
+	if err := engine.SetOption("SyzygyPath", dir); err != nil || ActiveSyzygy() == nil || ActiveSyzygy().Rule50 {
+		t.Fatalf("Expected the tables to be loaded with the options, got %v", err)
+	}
+	if err := engine.SetOption("SyzygyProbeDepth", "4"); err != nil || ActiveSyzygy().ProbeDepth != 4 {
+		t.Errorf("Expected the probe depth to be applied, got %v", err)
+	}
+
+	// Capturing the queen leads to a 3-man table
+	board := NewBoard()
+	board.FromFEN("8/8/8/3k4/8/8/2q5/K1R5 w - - 0 1")
+	infos, err := engine.Analyze(context.Background(), board, GoOptions{Depth: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var final SearchInfo
+	for info := range infos {
+		final = info
+	}
+	if final.TBHits == 0 || len(final.PV) == 0 || final.PV[0].ToUCI() != "c1c2" {
+		t.Errorf("Expected c1c2 with tablebase hits, got %v, %d hits", final.PV, final.TBHits)
+	}
+
+	if err := engine.SetOption("SyzygyPath", DefaultSyzygyPath); err != nil || ActiveSyzygy() != nil {
+		t.Errorf("Expected the tables to be unloaded, got %v", err)
+	}
+	if err := engine.SetOption("SyzygyPath", filepath.Join(dir, "missing")); err == nil {
+		t.Error("Expected a missing directory to be rejected")
+	}
+}
+
+func TestSyzygyBadFile(t *testing.T) {
+	bad := t.TempDir()
+	if err := os.WriteFile(filepath.Join(bad, "KQvK.rtbw"), make([]byte, 80), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadSyzygy(bad); !errors.Is(err, ErrSyzygyMagic) {
+		t.Errorf("Expected ErrSyzygyMagic, got %v", err)
+	}
+}
@@ -0,0 +1,246 @@
+package libra_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	. "github.com/eugenioenko/libra-chess/pkg"
+)
+
+var (
+	threeManTablebase     *Tablebase
+	threeManTablebaseOnce sync.Once
+)
+
+// generateThreeMan generates the 3-man tables once for the tests
+func generateThreeMan(t *testing.T) *Tablebase {
+	threeManTablebaseOnce.Do(func() {
+		tb := NewTablebase()
+		for _, name := range TablebaseMaterials(3) {
+			table, err := GenerateTablebaseTable(name, tb, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			tb.Add(table)
+		}
+		threeManTablebase = tb
+	})
+	if threeManTablebase == nil {
+		t.Fatal("Expected the 3-man tables to be generated")
+	}
+	return threeManTablebase
+}
+
+func probeFEN(t *testing.T, tb *Tablebase, fen string) TablebaseResult {
+	board := NewBoard()
+	board.FromFEN(fen)
+	result, ok := tb.Probe(board)
+	if !ok {
+		t.Fatalf("Expected %s to be found", fen)
+	}
+	return result
+}
+
+func TestTablebaseMaterials(t *testing.T) {
+	names := TablebaseMaterials(4)
+	if len(names) != 35 {
+		t.Errorf("Expected 35 tables up to 4 pieces, got %d", len(names))
+	}
+	index := map[string]int{}
+	for i, name := range names {
+		index[name] = i
+	}
+	// Captures and promotions lead to tables generated before
+	for _, dependency := range [][2]string{{"KQK", "KQKR"}, {"KRK", "KQKR"}, {"KQKQ", "KQKP"}, {"KPK", "KPPK"}, {"KQPK", "KPPK"}} {
+		if index[dependency[0]] >= index[dependency[1]] {
+			t.Errorf("Expected %s before %s in %v", dependency[0], dependency[1], names)
+		}
+	}
+}
+
+func TestTablebaseProbe(t *testing.T) {
+	tb := generateThreeMan(t)
+	cases := []struct {
+		fen    string
+		result TablebaseResult
+	}{
+		{"7k/8/6K1/8/8/8/8/1Q6 w - - 0 1", TablebaseResult{WDL: 1, DTM: 1}},
+		{"1q6/8/8/8/8/6k1/8/7K b - - 0 1", TablebaseResult{WDL: 1, DTM: 1}}, // Colors swapped
+		{"7k/6Q1/6K1/8/8/8/8/8 b - - 0 1", TablebaseResult{WDL: -1, DTM: 0}},
+		{"7k/5Q2/6K1/8/8/8/8/8 b - - 0 1", TablebaseResult{}}, // Stalemate
+		{"8/8/8/4k3/8/8/8/2N1K3 w - - 0 1", TablebaseResult{}},
+		{"8/8/8/8/8/4k3/4P3/4K3 w - - 0 1", TablebaseResult{}},
+		{"8/8/8/4k3/8/8/8/4K3 w - - 0 1", TablebaseResult{}},
+	}
+	for _, c := range cases {
+		if result := probeFEN(t, tb, c.fen); result != c.result {
+			t.Errorf("Expected %s to be %+v, got %+v", c.fen, c.result, result)
+		}
+	}
+	if result := probeFEN(t, tb, "4k3/8/4K3/4P3/8/8/8/8 b - - 0 1"); result.WDL != -1 {
+		t.Errorf("Expected black to lose, got %+v", result)
+	}
+	board := NewBoard()
+	board.FromFEN("8/8/8/4k3/8/8/8/R3K3 w Q - 0 1")
+	if _, ok := tb.Probe(board); ok {
+		t.Error("Expected positions with castling rights not to be found")
+	}
+}
+
+func TestTablebaseLongestMates(t *testing.T) {
+	generateThreeMan(t)
+	// Mate in 10 with the queen, 16 with the rook: the side to move loses in 20 and 32 plies
+	for name, plies := range map[string]int{"KQK": 20, "KRK": 32} {
+		table, err := GenerateTablebaseTable(name, threeManTablebase, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if longest := table.Stats().LongestMate; longest != plies {
+			t.Errorf("Expected the longest %s mate to be %d plies, got %d", name, plies, longest)
+		}
+	}
+}
+
+func TestTablebaseMatchesKPKBitbase(t *testing.T) {
+	tb := generateThreeMan(t)
+	board := NewBoard()
+	checked := 0
+	for pawn := byte(8); pawn < 56; pawn++ {
+		for strongKing := byte(0); strongKing < 64; strongKing++ {
+			for weakKing := byte(0); weakKing < 64; weakKing++ {
+				if strongKing == pawn || weakKing == pawn || strongKing == weakKing || SquareDistance[strongKing][weakKing] <= 1 {
+					continue
+				}
+				for _, whiteToMove := range []bool{true, false} {
+					board.Reset()
+					board.SetPiece(strongKing, WhiteKing)
+					board.SetPiece(weakKing, BlackKing)
+					board.SetPiece(pawn, WhitePawn)
+					board.WhiteToMove = whiteToMove
+					if board.IsSquareAttacked(board.PassiveKingSquare(), !whiteToMove) {
+						continue
+					}
+					result, ok := tb.Probe(board)
+					win := ProbeKPK(ColorWhite, strongKing, pawn, weakKing, whiteToMove)
+					if !ok || (result.WDL != 0) != win {
+						t.Fatalf("Expected %s to match the bitbase (win %v), got %+v", board.ToFEN(), win, result)
+					}
+					checked++
+				}
+			}
+		}
+	}
+	if checked == 0 {
+		t.Error("Expected positions to be checked")
+	}
+}
+
+func TestTablebaseSaveLoad(t *testing.T) {
+	tb := generateThreeMan(t)
+	dir := t.TempDir()
+	for _, name := range tb.Tables() {
+		table, err := GenerateTablebaseTable(name, tb, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := table.SaveFile(dir); err != nil {
+			t.Fatal(err)
+		}
+	}
+	loaded, err := LoadTablebase(dir)
+	if err != nil || len(loaded.Tables()) != 5 || loaded.MaxPieces() != 3 {
+		t.Fatalf("Expected 5 tables of 3 pieces, got %v (%v)", loaded.Tables(), err)
+	}
+	for _, fen := range []string{"7k/8/6K1/8/8/8/8/1Q6 w - - 0 1", "8/8/3k4/8/8/8/8/R3K3 b - - 0 1", "8/8/8/8/3k4/8/1p6/6K1 w - - 0 1"} {
+		if expected, result := probeFEN(t, tb, fen), probeFEN(t, loaded, fen); result != expected {
+			t.Errorf("Expected %s to be %+v once loaded, got %+v", fen, expected, result)
+		}
+	}
+	if _, err := LoadTablebaseTable(bytes.NewReader([]byte("LIBRATT\x00"))); !errors.Is(err, ErrTablebaseMagic) {
+		t.Errorf("Expected ErrTablebaseMagic, got %v", err)
+	}
+	if _, err := GenerateTablebaseTable("KRKQ", tb, 0); !errors.Is(err, ErrTablebaseMaterial) {
+		t.Errorf("Expected a non canonical name to be rejected, got %v", err)
+	}
+}
+
+func TestSearchPlaysTablebaseMoves(t *testing.T) {
+	tb := generateThreeMan(t)
+	SetTablebase(tb)
+	defer SetTablebase(nil)
+
+	fen := "8/8/3k4/8/8/8/8/R3K3 w - - 0 1"
+	board := NewBoard()
+	board.FromFEN(fen)
+	root := probeFEN(t, tb, fen)
+	move := board.IterativeDeepeningSearch(SearchOptions{MaxDepth: 2})
+	if move == nil {
+		t.Fatal("Expected a move")
+	}
+	board.Move(*move)
+	if child, _ := tb.Probe(board); child.WDL != -1 || child.DTM != root.DTM-1 {
+		t.Errorf("Expected %s to keep the mate in %d plies, got %+v", move.ToUCI(), root.DTM, child)
+	}
+}
+
+func TestTablebaseScoresCountThePly(t *testing.T) {
+	tb := generateThreeMan(t)
+	SetTablebase(tb)
+	defer SetTablebase(nil)
+
+	// Not in the 3-man tables, Qxd4+ leads into KQK one ply from the root
+	board := NewBoard()
+	board.FromFEN("8/8/3k4/8/3n4/8/8/3QK3 w - - 0 1")
+	score := 0
+	move := board.IterativeDeepeningSearch(SearchOptions{MaxDepth: 2, OnIteration: func(result *SearchResult) {
+		score = result.BestScore
+	}})
+	if move == nil || move.ToUCI() != "d1d4" {
+		t.Fatalf("Expected Qxd4+, got %v", move)
+	}
+	board.Move(*move)
+	child := probeFEN(t, tb, board.ToFEN())
+	if expected := TablebaseWinScore - child.DTM - 1; score != expected {
+		t.Errorf("Expected the score %d of a mate in %d plies one ply from the root, got %d", expected, child.DTM, score)
+	}
+}
+
+func TestEngineTablebasePath(t *testing.T) {
+	tb := generateThreeMan(t)
+	dir := t.TempDir()
+	table, err := GenerateTablebaseTable("KRK", tb, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := table.SaveFile(dir); err != nil {
+		t.Fatal(err)
+	}
+	engine := NewEngine()
+	defer engine.SetOption("TablebasePath", DefaultTablebasePath)
+	if err := engine.SetOption("TablebasePath", dir); err != nil || ActiveTablebase() == nil {
+		t.Fatalf("Expected the tablebase to be loaded, got %v", err)
+	}
+	board := NewBoard()
+	board.FromFEN("8/8/3k4/8/8/8/8/R3K3 w - - 0 1")
+	root := probeFEN(t, ActiveTablebase(), board.ToFEN())
+	infos, err := engine.Analyze(context.Background(), board, GoOptions{Depth: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var final SearchInfo
+	for info := range infos {
+		final = info
+	}
+	if final.Mate != (root.DTM+1)/2 || final.TBHits == 0 {
+		t.Errorf("Expected mate in %d with tablebase hits, got mate %d, %d hits", (root.DTM+1)/2, final.Mate, final.TBHits)
+	}
+	if err := engine.SetOption("TablebasePath", DefaultTablebasePath); err != nil || ActiveTablebase() != nil {
+		t.Errorf("Expected the tablebase to be unloaded, got %v", err)
+	}
+	if err := engine.SetOption("TablebasePath", t.TempDir()+"/missing"); err == nil {
+		t.Error("Expected an invalid directory to be rejected")
+	}
+}
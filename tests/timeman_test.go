@@ -0,0 +1,88 @@
+package libra_test
+
+import (
+	"testing"
+
+	. "github.com/eugenioenko/libra-chess/pkg"
+)
+
+func TestCalcTimeLimitMoveOverhead(t *testing.T) {
+	opts := ParseGoOptions([]string{"go", "wtime", "10000", "btime", "10000"})
+	optimal, maxTime := opts.CalcTimeLimit(true, 100)
+	if optimal != 330 || maxTime != 1980 {
+		t.Errorf("Expected 330/1980, got %d/%d", optimal, maxTime)
+	}
+	optimal, maxTime = opts.CalcTimeLimit(true, 1000)
+	if optimal != 300 || maxTime != 1800 {
+		t.Errorf("Expected 300/1800, got %d/%d", optimal, maxTime)
+	}
+}
+
+func TestParseSetOption(t *testing.T) {
+	name, value := ParseSetOption([]string{"setoption", "name", "Move", "Overhead", "value", "250"})
+	if name != "Move Overhead" || value != "250" {
+		t.Errorf("Expected 'Move Overhead'='250', got '%s'='%s'", name, value)
+	}
+}
+
+func TestTimeManagerStableBestMove(t *testing.T) {
+	board := NewBoard()
+	board.LoadInitial()
+	move := board.ParseUCIMove("e2e4")
+
+	tm := NewTimeManager(1000, 5000)
+	tm.SetRootPosition(20, TotalPhase)
+	tm.Update(move, 20)
+	unstable := tm.SoftLimit()
+	for i := 0; i < 6; i++ {
+		tm.Update(move, 20)
+	}
+	stable := tm.SoftLimit()
+	if stable >= unstable {
+		t.Errorf("Expected stable best move to shrink soft limit, got %d >= %d", stable, unstable)
+	}
+}
+
+func TestTimeManagerScoreDrop(t *testing.T) {
+	board := NewBoard()
+	board.LoadInitial()
+	move := board.ParseUCIMove("e2e4")
+
+	tm := NewTimeManager(1000, 5000)
+	tm.SetRootPosition(20, TotalPhase)
+	tm.Update(move, 50)
+	tm.Update(move, 50)
+	steady := tm.SoftLimit()
+	tm.Update(move, -100)
+	dropped := tm.SoftLimit()
+	if dropped <= steady {
+		t.Errorf("Expected score drop to extend soft limit, got %d <= %d", dropped, steady)
+	}
+	if dropped > 5000 {
+		t.Errorf("Soft limit should never exceed the hard limit, got %d", dropped)
+	}
+}
+
+func TestTimeManagerPredictsIteration(t *testing.T) {
+	tm := NewTimeManager(1000, 1200)
+	tm.SetRootPosition(20, TotalPhase)
+	if !tm.CanStartIteration(100, 50) {
+		t.Errorf("Expected a short iteration to be started")
+	}
+	if tm.CanStartIteration(700, 400) {
+		t.Errorf("Expected an iteration predicted to exceed the hard limit not to be started")
+	}
+}
+
+func TestTimeManagerForcedMove(t *testing.T) {
+	board := NewBoard()
+	board.FromFEN("k7/8/8/8/8/8/1r6/K1r5 w - - 0 1")
+	tm := NewTimeManager(2000, 5000)
+	move := board.IterativeDeepeningSearch(SearchOptions{TimeManager: tm})
+	if move == nil || move.ToUCI() != "a1b2" {
+		t.Errorf("Expected forced move a1b2, got %v", move)
+	}
+	if tm.CanStartIteration(0, 0) {
+		t.Errorf("Expected no further iteration with a single legal move")
+	}
+}
@@ -0,0 +1,120 @@
+package libra_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	. "github.com/eugenioenko/libra-chess/pkg"
+)
+
+func TestSearchTraceRecordsTree(t *testing.T) {
+	board := NewBoard()
+	board.FromFEN("k7/8/4p3/3r4/2B1Q3/8/8/7K w - - 0 1")
+	trace := NewSearchTrace(0, 0)
+	board.IterativeDeepeningSearch(SearchOptions{MaxDepth: 2, Tracer: trace})
+
+	if len(trace.Nodes) == 0 {
+		t.Fatalf("Expected traced nodes")
+	}
+	roots := 0
+	quiescence := 0
+	for _, node := range trace.Nodes {
+		if node.Parent < 0 {
+			roots++
+			continue
+		}
+		parent := trace.Nodes[node.Parent]
+		if len(node.Path) != node.Ply || parent.Ply != node.Ply-1 {
+			t.Errorf("Node %d has inconsistent ply %d, path %v, parent ply %d", node.ID, node.Ply, node.Path, parent.Ply)
+		}
+		if node.Quiescence {
+			quiescence++
+		}
+	}
+	if roots != 2 {
+		t.Errorf("Expected one root per iteration, got %d", roots)
+	}
+	if quiescence == 0 {
+		t.Errorf("Expected quiescence nodes to be traced")
+	}
+}
+
+func TestSearchTraceLimits(t *testing.T) {
+	board := NewBoard()
+	board.LoadInitial()
+	trace := NewSearchTrace(1, 0)
+	board.IterativeDeepeningSearch(SearchOptions{MaxDepth: 3, Tracer: trace})
+	for _, node := range trace.Nodes {
+		if node.Ply > 1 {
+			t.Fatalf("Expected no nodes deeper than ply 1, got ply %d", node.Ply)
+		}
+	}
+
+	trace = NewSearchTrace(0, 50)
+	board.IterativeDeepeningSearch(SearchOptions{MaxDepth: 3, Tracer: trace})
+	if len(trace.Nodes) != 50 {
+		t.Errorf("Expected 50 nodes, got %d", len(trace.Nodes))
+	}
+}
+
+func TestSearchTraceExport(t *testing.T) {
+	board := NewBoard()
+	board.LoadInitial()
+	trace := NewSearchTrace(2, 0)
+	board.IterativeDeepeningSearch(SearchOptions{MaxDepth: 2, Tracer: trace})
+
+	var jsonOut bytes.Buffer
+	if err := trace.WriteJSON(&jsonOut); err != nil {
+		t.Fatal(err)
+	}
+	var nodes []TraceNode
+	if err := json.Unmarshal(jsonOut.Bytes(), &nodes); err != nil {
+		t.Fatal(err)
+	}
+	if len(nodes) != len(trace.Nodes) {
+		t.Errorf("Expected %d nodes in JSON, got %d", len(trace.Nodes), len(nodes))
+	}
+
+	var dotOut bytes.Buffer
+	if err := trace.WriteDOT(&dotOut); err != nil {
+		t.Fatal(err)
+	}
+	dot := dotOut.String()
+	if !strings.HasPrefix(dot, "digraph search {") || !strings.Contains(dot, "n0 -> n1;") {
+		t.Errorf("Unexpected DOT output: %.200s", dot)
+	}
+}
+
+// exitTracer records which of the nodes of its trace were exited
+type exitTracer struct {
+	*SearchTrace
+	exited map[int]TraceCutoff
+}
+
+func (tracer *exitTracer) ExitNode(id int, score int, bestMove string, cutoff TraceCutoff) {
+	tracer.exited[id] = cutoff
+	tracer.SearchTrace.ExitNode(id, score, bestMove, cutoff)
+}
+
+func TestSearchTraceInterrupted(t *testing.T) {
+	board := NewBoard()
+	board.LoadInitial()
+	tracer := &exitTracer{SearchTrace: NewSearchTrace(0, 0), exited: map[int]TraceCutoff{}}
+	board.IterativeDeepeningSearch(SearchOptions{MaxDepth: 8, MaxNodes: 3000, Threads: NewSearchThreads(1), Tracer: tracer})
+
+	interrupted := 0
+	for _, node := range tracer.Nodes {
+		cutoff, ok := tracer.exited[node.ID]
+		if !ok {
+			t.Fatalf("Expected node %d (%v) to be exited", node.ID, node.Path)
+		}
+		if cutoff == CutoffInterrupted && node.Parent >= 0 {
+			interrupted++
+		}
+	}
+	if interrupted == 0 {
+		t.Errorf("Expected the nodes stopped by the node limit to be interrupted")
+	}
+}
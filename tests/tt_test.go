@@ -0,0 +1,236 @@
+package libra_test
+
+import (
+	"math/bits"
+	"sync"
+	"testing"
+
+	. "github.com/eugenioenko/libra-chess/pkg"
+)
+
+func TestTTRoundTrip(t *testing.T) {
+	board := NewBoard()
+	board.FromFEN("r3k2r/1P6/8/8/8/8/8/R3K2R w KQkq - 0 1")
+	tt := NewTranspositionTableMB(1)
+	for i, move := range board.GenerateLegalMoves() {
+		hash := uint64(i+1) * 0x9E3779B97F4A7C15
+		score := (i - 20) * 50_000
+		tt.Set(hash, i%60+1, score, move, BoundLower, NoStaticEval, false)
+		entry, ok := tt.Get(hash, 1)
+		if !ok {
+			t.Fatalf("Expected entry for %s", move.ToUCI())
+		}
+		if entry.BestMove != move || entry.Score != score || entry.Depth != i%60+1 || entry.Bound != BoundLower {
+			t.Errorf("Expected %+v %d, got %+v", move, score, entry)
+		}
+	}
+	if _, ok := tt.Get(0x1234, 0); ok {
+		t.Errorf("Expected a miss for an unknown hash")
+	}
+}
+
+func TestTTMateScores(t *testing.T) {
+	tt := NewTranspositionTableMB(1)
+	tt.Set(1<<40, 5, MaxEvaluationScore, Move{}, BoundExact, NoStaticEval, false)
+	tt.Set(2<<40, 5, -MaxEvaluationScore, Move{}, BoundExact, NoStaticEval, false)
+	if entry, _ := tt.Get(1<<40, 0); entry.Score != MaxEvaluationScore {
+		t.Errorf("Expected %d, got %d", MaxEvaluationScore, entry.Score)
+	}
+	if entry, _ := tt.Get(2<<40, 0); entry.Score != -MaxEvaluationScore {
+		t.Errorf("Expected %d, got %d", -MaxEvaluationScore, entry.Score)
+	}
+}
+
+func TestTTDepthPreferred(t *testing.T) {
+	tt := NewTranspositionTableMB(1)
+	tt.Set(42, 8, 100, Move{}, BoundLower, NoStaticEval, false)
+	tt.Set(42, 3, 200, Move{}, BoundLower, NoStaticEval, false)
+	if entry, ok := tt.Get(42, 0); !ok || entry.Score != 100 {
+		t.Errorf("Expected the deeper entry to be kept, got %+v", entry)
+	}
+	if _, ok := tt.Get(42, 9); ok {
+		t.Errorf("Expected a miss when the entry is too shallow")
+	}
+}
+
+func TestTTSizeAndHashfull(t *testing.T) {
+	tt := NewTranspositionTableMB(3)
+	if tt.SizeMB() != 2 {
+		t.Errorf("Expected the size to round down to 2MB, got %d", tt.SizeMB())
+	}
+	if tt.Capacity() != 2*1024*1024/16 {
+		t.Errorf("Expected %d entries, got %d", 2*1024*1024/16, tt.Capacity())
+	}
+	if tt.Hashfull() != 0 {
+		t.Errorf("Expected an empty table, got hashfull %d", tt.Hashfull())
+	}
+	// Bucket index in the top bits, a different key below it for every slot
+	buckets := uint64(tt.Capacity() / TTBucketSize)
+	shift := 64 - bits.TrailingZeros64(buckets)
+	for i := uint64(0); i < uint64(tt.Capacity()); i++ {
+		tt.Set(i%buckets<<shift|(i/buckets)<<32, 1, 0, Move{}, BoundExact, NoStaticEval, false)
+	}
+	if tt.Hashfull() != 1000 {
+		t.Errorf("Expected a full table, got hashfull %d", tt.Hashfull())
+	}
+	tt.Clear()
+	if tt.Size() != 0 {
+		t.Errorf("Expected an empty table after clear, got %d entries", tt.Size())
+	}
+}
+
+func TestTTConcurrentAccess(t *testing.T) {
+	tt := NewTranspositionTableMB(1)
+	var wg sync.WaitGroup
+	for w := 0; w < 8; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < 10_000; i++ {
+				// Every worker writes its own score for the same positions
+				hash := uint64(i%64) * 0x9E3779B97F4A7C15
+				tt.Set(hash, 1+w, w, Move{}, BoundExact, NoStaticEval, false)
+				if entry, ok := tt.Get(hash, 0); ok && (entry.Score < 0 || entry.Score >= 8 || entry.Depth != entry.Score+1) {
+					t.Errorf("Torn entry read: %+v", entry)
+					return
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+}
+
+func TestEngineHashOption(t *testing.T) {
+	engine := NewEngine()
+	if err := engine.SetOption("Hash", "4"); err != nil {
+		t.Fatal(err)
+	}
+	if engine.TranspositionTable().SizeMB() != 4 {
+		t.Errorf("Expected a 4MB table, got %d", engine.TranspositionTable().SizeMB())
+	}
+	if err := engine.SetOption("Hash", "100"); err != nil {
+		t.Fatal(err)
+	}
+	if engine.Options.HashMB != 64 || engine.TranspositionTable().SizeMB() != 64 {
+		t.Errorf("Expected the Hash option to report the 64MB in use, got %d", engine.Options.HashMB)
+	}
+}
+
+func TestTTStaticEvalAndPVFlag(t *testing.T) {
+	tt := NewTranspositionTableMB(1)
+	tt.Set(7<<40, 4, 35, Move{}, BoundExact, -1234, true)
+	entry, ok := tt.Probe(7 << 40)
+	if !ok || entry.StaticEval != -1234 || !entry.IsPV || entry.Score != 35 {
+		t.Errorf("Expected static eval -1234 on a PV entry, got %+v", entry)
+	}
+	// A later non-PV store of the same position in the same search keeps the PV flag
+	tt.Set(7<<40, 5, 40, Move{}, BoundLower, 20, false)
+	if entry, _ := tt.Probe(7 << 40); !entry.IsPV || entry.StaticEval != 20 || entry.Score != 40 {
+		t.Errorf("Expected a sticky PV flag and the new eval, got %+v", entry)
+	}
+	tt.Set(8<<40, 4, 0, Move{}, BoundExact, NoStaticEval, false)
+	if entry, _ := tt.Probe(8 << 40); entry.StaticEval != NoStaticEval {
+		t.Errorf("Expected no static eval, got %d", entry.StaticEval)
+	}
+}
+
+func TestTTChecksTheWholeHash(t *testing.T) {
+	tt := NewTranspositionTableMB(4)
+	tt.Set(5, 4, 35, Move{}, BoundExact, 10, false)
+	if _, ok := tt.Probe(5); !ok {
+		t.Fatal("Expected the entry to be found")
+	}
+	// The low bits are stored, the top 16 bits pick one of the 65536 buckets
+	for _, hash := range []uint64{6, 1<<48 | 5, 1<<63 | 5} {
+		if entry, ok := tt.Probe(hash); ok {
+			t.Errorf("Expected %#x to miss the entry of 5, got %+v", hash, entry)
+		}
+	}
+}
+
+func TestTTAgingReplacesOldEntries(t *testing.T) {
+	tt := NewTranspositionTableMB(1)
+	buckets := uint64(tt.Capacity() / TTBucketSize)
+	// Fill one bucket with deep entries from an old search
+	for i := uint64(0); i < TTBucketSize; i++ {
+		tt.Set(i<<20*buckets+1, 20, 0, Move{}, BoundExact, NoStaticEval, false)
+	}
+	tt.NewSearch()
+	tt.NewSearch()
+	tt.NewSearch()
+	tt.Set(99<<20*buckets+1, 2, 0, Move{}, BoundExact, NoStaticEval, false)
+	if _, ok := tt.Get(99<<20*buckets+1, 2); !ok {
+		t.Errorf("Expected a shallow new entry to replace a deep stale one")
+	}
+	if tt.Hashfull() != 1 {
+		t.Errorf("Expected hashfull to count only the current search, got %d", tt.Hashfull())
+	}
+}
+
+func TestTTSameSearchKeepsDeeperBound(t *testing.T) {
+	tt := NewTranspositionTableMB(1)
+	buckets := uint64(tt.Capacity() / TTBucketSize)
+	for i := uint64(0); i < TTBucketSize; i++ {
+		tt.Set(i<<20*buckets+1, 20, 0, Move{}, BoundExact, NoStaticEval, false)
+	}
+	tt.Set(99<<20*buckets+1, 2, 0, Move{}, BoundExact, NoStaticEval, false)
+	deep := 0
+	for i := uint64(0); i < TTBucketSize; i++ {
+		if _, ok := tt.Get(i<<20*buckets+1, 20); ok {
+			deep++
+		}
+	}
+	if deep != TTBucketSize-1 {
+		t.Errorf("Expected only one deep entry to be replaced, %d left", deep)
+	}
+}
+
+func TestIsMovePseudoLegal(t *testing.T) {
+	fens := []string{
+		"r3k2r/p1ppqpb1/bn2pnp1/3PN3/1p2P3/2N2Q1p/PPPBBPPP/R3K2R w KQkq - 0 1",
+		"r3k2r/Pppp1ppp/1b3nbN/nP6/BBP1P3/q4N2/Pp1P2PP/R2Q1RK1 w kq - 0 1",
+		"rnbq1k1r/pp1Pbppp/2p5/8/2B5/8/PPP1NnPP/RNBQK2R w KQ - 1 8",
+		"rnbqkbnr/ppp1p1pp/8/3pPp2/8/8/PPPP1PPP/RNBQKBNR w KQkq f6 0 3",
+	}
+	// Positions one ply after the test positions, and every move of all of them,
+	// as after key collisions
+	var boards []*Board
+	var pool []Move
+	for _, fen := range fens {
+		board := NewBoard()
+		board.FromFEN(fen)
+		boards = append(boards, board)
+		for _, move := range board.GeneratePseudoLegalMoves() {
+			child := board.Clone()
+			child.Move(move)
+			boards = append(boards, child)
+		}
+	}
+	for _, board := range boards {
+		pool = append(pool, board.GeneratePseudoLegalMoves()...)
+	}
+	for _, board := range boards {
+		generated := map[Move]bool{}
+		for _, move := range board.GeneratePseudoLegalMoves() {
+			generated[move] = true
+		}
+		for _, move := range pool {
+			if board.IsMovePseudoLegal(move) != generated[move] {
+				t.Fatalf("%s: expected IsMovePseudoLegal(%s %+v) to be %v", board.ToFEN(), move.ToUCI(), move, generated[move])
+			}
+		}
+	}
+}
+
+func TestSearchStoresStaticEval(t *testing.T) {
+	board := NewBoard()
+	board.LoadInitial()
+	tt := NewTranspositionTableMB(1)
+	board.IterativeDeepeningSearch(SearchOptions{MaxDepth: 3, TranspositionTable: tt})
+	child := board.Clone()
+	child.Move(*board.ParseUCIMove("e2e4"))
+	entry, ok := tt.Probe(child.ZobristHash())
+	if !ok || entry.StaticEval != child.Evaluate() {
+		t.Errorf("Expected the static eval %d of 1.e4 to be stored, got %+v", child.Evaluate(), entry)
+	}
+}
@@ -0,0 +1,140 @@
+package libra_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "github.com/eugenioenko/libra-chess/pkg"
+)
+
+func TestTTSaveLoad(t *testing.T) {
+	board := NewBoard()
+	board.LoadInitial()
+	tt := NewTranspositionTableMB(2)
+	board.IterativeDeepeningSearch(SearchOptions{MaxDepth: 4, TranspositionTable: tt})
+	// Root moves are searched from their child positions, which are stored
+	board.Move(*board.ParseUCIMove("e2e4"))
+	hash := board.ZobristHash()
+	saved, ok := tt.Probe(hash)
+	if !ok {
+		t.Fatal("Expected the position after e2e4 in the table")
+	}
+
+	var buf bytes.Buffer
+	if err := tt.Save(&buf); err != nil {
+		t.Fatal(err)
+	}
+	loaded := NewTranspositionTableMB(1)
+	if err := loaded.Load(bytes.NewReader(buf.Bytes())); err != nil {
+		t.Fatal(err)
+	}
+	if loaded.SizeMB() != 2 || loaded.Generation() != tt.Generation() {
+		t.Errorf("Expected a 2MB table of generation %d, got %dMB of %d", tt.Generation(), loaded.SizeMB(), loaded.Generation())
+	}
+	entry, ok := loaded.Probe(hash)
+	if !ok || entry != saved {
+		t.Errorf("Expected %+v after loading, got %+v", saved, entry)
+	}
+	if loaded.Size() != tt.Size() {
+		t.Errorf("Expected %d entries, got %d", tt.Size(), loaded.Size())
+	}
+}
+
+func TestTTSaveLoadDrawScore(t *testing.T) {
+	tt := NewTranspositionTableMB(1)
+	tt.SetDrawScore(-30)
+	tt.Set(42, 5, 100, Move{}, BoundExact, NoStaticEval, true)
+	var buf bytes.Buffer
+	if err := tt.Save(&buf); err != nil {
+		t.Fatal(err)
+	}
+	loaded := NewTranspositionTableMB(1)
+	if err := loaded.Load(bytes.NewReader(buf.Bytes())); err != nil {
+		t.Fatal(err)
+	}
+	// A search with the same contempt keeps the loaded analysis
+	loaded.SetDrawScore(-30)
+	if _, ok := loaded.Probe(42); !ok {
+		t.Error("Expected the entry to be kept by a search with the saved draw score")
+	}
+	loaded.SetDrawScore(0)
+	if _, ok := loaded.Probe(42); ok {
+		t.Error("Expected another draw score to clear the loaded table")
+	}
+}
+
+func TestTTLoadRejectsCorruptedData(t *testing.T) {
+	tt := NewTranspositionTableMB(1)
+	tt.Set(42, 5, 100, Move{}, BoundExact, NoStaticEval, true)
+	var buf bytes.Buffer
+	if err := tt.Save(&buf); err != nil {
+		t.Fatal(err)
+	}
+	data := buf.Bytes()
+
+	corrupted := bytes.Clone(data)
+	corrupted[len(corrupted)/2] ^= 0xFF
+	target := NewTranspositionTableMB(1)
+	target.Set(7, 3, 50, Move{}, BoundExact, NoStaticEval, false)
+	if err := target.Load(bytes.NewReader(corrupted)); !errors.Is(err, ErrTTFileChecksum) {
+		t.Errorf("Expected ErrTTFileChecksum, got %v", err)
+	}
+	if _, ok := target.Probe(7); !ok {
+		t.Errorf("Expected the table to be unchanged after a failed load")
+	}
+	if err := target.Load(bytes.NewReader(data[:len(data)-10])); !errors.Is(err, ErrTTFileChecksum) {
+		t.Errorf("Expected ErrTTFileChecksum for truncated data, got %v", err)
+	}
+	if err := target.Load(bytes.NewReader([]byte("not a table at all, really"))); !errors.Is(err, ErrTTFileMagic) {
+		t.Errorf("Expected ErrTTFileMagic, got %v", err)
+	}
+	// A header claiming the largest table, followed by too little data
+	oversized := bytes.Clone(data[:len(data)-10])
+	binary.LittleEndian.PutUint64(oversized[12:20], uint64(MaxHashMB)*1024*1024/64)
+	if err := target.Load(bytes.NewReader(oversized)); !errors.Is(err, ErrTTFileChecksum) {
+		t.Errorf("Expected ErrTTFileChecksum for an oversized header, got %v", err)
+	}
+	path := filepath.Join(t.TempDir(), "oversized.hash")
+	if err := os.WriteFile(path, oversized, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := target.LoadFile(path); !errors.Is(err, ErrTTFileChecksum) {
+		t.Errorf("Expected ErrTTFileChecksum for an oversized file header, got %v", err)
+	}
+	badVersion := bytes.Clone(data)
+	badVersion[8] = 99
+	if err := target.Load(bytes.NewReader(badVersion)); !errors.Is(err, ErrTTFileVersion) {
+		t.Errorf("Expected ErrTTFileVersion, got %v", err)
+	}
+}
+
+func TestEngineSaveLoadHashOptions(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "analysis.hash")
+	engine := NewEngine()
+	if err := engine.SetOption("Hash", "2"); err != nil {
+		t.Fatal(err)
+	}
+	engine.TranspositionTable().Set(42, 5, 100, Move{}, BoundExact, NoStaticEval, true)
+	if err := engine.SetOption("Hash File", path); err != nil {
+		t.Fatal(err)
+	}
+	if err := engine.SetOption("Save Hash", ""); err != nil {
+		t.Fatal(err)
+	}
+
+	resumed := NewEngine()
+	resumed.SetOption("Hash File", path)
+	if err := resumed.SetOption("Load Hash", ""); err != nil {
+		t.Fatal(err)
+	}
+	if resumed.Options.HashMB != 2 {
+		t.Errorf("Expected the Hash option to follow the loaded table, got %d", resumed.Options.HashMB)
+	}
+	if entry, ok := resumed.TranspositionTable().Probe(42); !ok || entry.Score != 100 {
+		t.Errorf("Expected the saved entry, got %+v", entry)
+	}
+}
@@ -0,0 +1,108 @@
+package libra_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	. "github.com/eugenioenko/libra-chess/pkg"
+)
+
+func TestParseTuneEntryLabels(t *testing.T) {
+	cases := []struct {
+		line   string
+		result float64
+		score  int
+		scored bool
+	}{
+		{"4k3/8/8/8/8/8/8/3QK3 w - - 0 1 [1.0]", 1, 0, false},
+		{"4k3/8/8/8/8/8/8/3QK3 w - - c9 \"1/2-1/2\";", 0.5, 0, false},
+		{"4k3/8/8/8/8/8/8/3QK3 w - - 12 40 0-1", 0, 0, false},
+		{"4k3/8/8/8/8/8/8/3QK3 w - - [0.5]", 0.5, 0, false},
+		{"4k3/8/8/8/8/8/8/3QK3 b - - ce 250;", 0, -250, true},
+	}
+	for _, c := range cases {
+		entry, err := ParseTuneEntry(c.line)
+		if err != nil {
+			t.Errorf("Failed to parse %q: %v", c.line, err)
+			continue
+		}
+		if entry.Result != c.result || entry.Score != c.score || entry.Scored != c.scored {
+			t.Errorf("Unexpected label of %q: %+v", c.line, entry)
+		}
+	}
+	for _, line := range []string{"4k3/8/8/8/8/8/8/3QK3 w - -", "8/8/8/8 w - - 1-0", "8/8/8/8/8/8/8/3QK3 w - - 1-0"} {
+		if _, err := ParseTuneEntry(line); !errors.Is(err, ErrInvalidTuneEntry) {
+			t.Errorf("Expected %q to be rejected, got %v", line, err)
+		}
+	}
+}
+
+func TestResolveQuietPlaysWinningCapture(t *testing.T) {
+	board := NewBoard()
+	// The black queen on d5 hangs to the e4 pawn
+	board.FromFEN("4k3/8/8/3q4/4P3/8/8/4K3 w - - 0 1")
+	board.ResolveQuiet()
+	if board.BlackQueens != 0 || board.WhiteToMove {
+		t.Errorf("Expected exd5 to be played, got %s", board.ToFEN())
+	}
+}
+
+func TestTunableParamsSkipsScales(t *testing.T) {
+	params := DefaultEvalParams()
+	names := map[string]bool{}
+	for _, param := range TunableParams(params) {
+		names[param.Name] = true
+	}
+	for _, name := range []string{"pstMg[1][27]", "bishopPair.eg", "kingAttackWeight[3]", "passedFreePath"} {
+		if !names[name] {
+			t.Errorf("Expected %s to be tunable", name)
+		}
+	}
+	for _, name := range []string{"phaseWeights[1]", "kingDangerDivisor", "moveOrderValues[0]"} {
+		if names[name] {
+			t.Errorf("Expected %s not to be tunable", name)
+		}
+	}
+	for _, param := range TunableParams(params) {
+		if param.Name == "bishopPair.mg" {
+			*param.Value = 123
+		}
+	}
+	if params.BishopPair.Mg != 123 {
+		t.Errorf("Expected tunable params to point into the parameters")
+	}
+}
+
+func TestTunerLowersError(t *testing.T) {
+	defer ApplyEvalParams(DefaultEvalParams())
+	data := strings.Join([]string{
+		"4k3/8/8/8/8/8/8/3QK3 w - - [1.0]",
+		"4k3/8/8/8/8/8/8/3RK3 w - - [1.0]",
+		"3qk3/8/8/8/8/8/8/4K3 w - - [0.0]",
+		"4k3/pppp4/8/8/8/8/PPPP4/4K3 w - - [0.5]",
+		"# comment",
+		"4k3/8/8/8/8/8/4PPPP/4K3 w - - [0.5]",
+	}, "\n")
+	entries, err := LoadTuneEntries(strings.NewReader(data))
+	if err != nil || len(entries) != 5 {
+		t.Fatalf("Expected 5 entries, got %d: %v", len(entries), err)
+	}
+	tuner := NewTuner(entries, 2)
+	tuner.Step = 10
+	params := DefaultEvalParams()
+	initial, err := tuner.Error(params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	final, err := tuner.Tune(params, 2, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if final >= initial {
+		t.Errorf("Expected tuning to lower the error, got %f then %f", initial, final)
+	}
+	if *CurrentEvalParams() != *params {
+		t.Errorf("Expected the tuned parameters to be in use")
+	}
+}
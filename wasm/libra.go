@@ -11,8 +11,14 @@ import (
 
 var board *Board
 
+// tt is kept between searches of the same game and cleared with a new board
+var tt *TranspositionTable
+
 func jsNewBoard(this js.Value, args []js.Value) interface{} {
 	board = NewBoard()
+	if tt != nil {
+		tt.Clear()
+	}
 	return js.ValueOf(true)
 }
 
@@ -53,10 +59,18 @@ func jsIterativeDeepeningSearch(this js.Value, args []js.Value) interface{} {
 	if len(args) > 0 {
 		ms = args[0].Int()
 	}
-	tt := NewTranspositionTable()
+	// Optional strength limit as an Elo rating, full strength by default
+	var skill *Skill
+	if len(args) > 1 && args[1].Type() == js.TypeNumber {
+		skill = NewSkillFromElo(args[1].Int())
+	}
+	if tt == nil {
+		tt = NewTranspositionTable()
+	}
 	move := board.IterativeDeepeningSearch(SearchOptions{
 		TimeLimitInMs:      ms,
 		TranspositionTable: tt,
+		Skill:              skill,
 	})
 	if move == nil {
 		return js.ValueOf("")